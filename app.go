@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"os"
+	"sync"
 
 	"github.com/kavos113/theorem-note-wails/backend"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -12,18 +15,34 @@ import (
 type App struct {
 	ctx           context.Context
 	configManager *backend.ConfigManager
+	recentFiles   backend.RecentFiles
+	previewStyles fs.FS
+
+	// watcherMu は configWatcher の入れ替えを直列化します
+	watcherMu     sync.Mutex
+	configWatcher *backend.ProjectConfigWatcher
 }
 
 // NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+func NewApp(previewStyles fs.FS) *App {
+	return &App{previewStyles: previewStyles}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	a.configManager = backend.NewConfigManager()
+	configManager, err := backend.NewConfigManager()
+	if err != nil {
+		// 設定を保存できなくてもエディタは使えるので、メモリ上の設定で続ける
+		runtime.LogWarningf(ctx, "global config is not available: %v", err)
+	}
+	a.configManager = configManager
+}
+
+// shutdown はアプリの終了時に呼ばれ、設定ファイルの監視をやめます
+func (a *App) shutdown(ctx context.Context) {
+	a.stopWatchingProjectConfig()
 }
 
 // Greet returns a greeting for the given name
@@ -36,7 +55,7 @@ func (a *App) GetNewDirectoryFileTree() ([]backend.FileItem, error) {
 	if err != nil {
 		return nil, err
 	}
-	a.configManager.SetLastOpened(path)
+	a.setLastOpened(path)
 	return items, nil
 }
 
@@ -45,16 +64,28 @@ func (a *App) GetFileTree(path string) ([]backend.FileItem, error) {
 	return backend.GetFileTree(path)
 }
 
-func (a *App) ReadFile(path string) (string, error) {
-	return backend.ReadFile(path)
+// ReadFile はノートの内容とフロントマターのメタデータを返します
+func (a *App) ReadFile(path string) (backend.NoteFile, error) {
+	note, err := backend.ReadNote(path)
+	if err != nil {
+		return backend.NoteFile{}, err
+	}
+	a.recentFiles.Touch(path)
+	return note, nil
+}
+
+// ResolveLink は [[...]] リンクの中身を、ファイルパスかエイリアスからノートのパスに解決します
+func (a *App) ResolveLink(rootDir string, target string) (backend.ResolvedLink, error) {
+	return backend.ResolveLink(rootDir, target)
 }
 
 func (a *App) WriteFile(path string, content string, rootDir string) error {
 	return backend.WriteFile(path, content, rootDir)
 }
 
-func (a *App) CreateFile(path string) error {
-	return backend.CreateFile(path)
+// CreateFile は rootDir のプロジェクトの定理環境のテンプレートで新しいノートを作ります
+func (a *App) CreateFile(path string, rootDir string) error {
+	return backend.CreateFile(path, rootDir)
 }
 
 func (a *App) CreateDirectory(path string) error {
@@ -67,8 +98,51 @@ func (a *App) GetLastOpened() string {
 }
 
 // SetLastOpened はグローバル設定に最後に開いたパスを保存します
-func (a *App) SetLastOpened(path string) {
-	a.configManager.SetLastOpened(path)
+func (a *App) SetLastOpened(path string) error {
+	return a.configManager.SetLastOpened(path)
+}
+
+// setLastOpened はプロジェクトを開いたことを記録します。記録できなくてもプロジェクトは開けるので、警告を残すだけにします
+func (a *App) setLastOpened(path string) {
+	if err := a.configManager.SetLastOpened(path); err != nil {
+		runtime.LogWarningf(a.ctx, "failed to save the last opened project: %v", err)
+	}
+}
+
+// --- Recent Projects ---
+
+func (a *App) GetRecentProjects() []backend.RecentProject {
+	return a.configManager.GetRecentProjects()
+}
+
+// OpenRecentProject はダイアログを使わずに最近のプロジェクトを開き、ファイルツリーを返します。
+// ディレクトリが存在しない場合は一覧から取り除いてエラーを返します
+func (a *App) OpenRecentProject(path string) ([]backend.FileItem, error) {
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		a.configManager.RemoveRecentProject(path)
+		if err == nil {
+			err = fmt.Errorf("%s is not a directory", path)
+		}
+		return nil, err
+	}
+	items, err := backend.GetFileTree(path)
+	if err != nil {
+		return nil, err
+	}
+	a.setLastOpened(path)
+	return items, nil
+}
+
+func (a *App) PinRecentProject(path string, pinned bool) error {
+	return a.configManager.PinRecentProject(path, pinned)
+}
+
+func (a *App) RenameRecentProject(path string, name string) error {
+	return a.configManager.RenameRecentProject(path, name)
+}
+
+func (a *App) RemoveRecentProject(path string) error {
+	return a.configManager.RemoveRecentProject(path)
 }
 
 // --- Session Management ---
@@ -81,14 +155,51 @@ func (a *App) LoadSession(rootDir string) ([]string, error) {
 	return backend.LoadSession(rootDir)
 }
 
+// SaveSessionState はタブごとのカーソルやスクロール位置、表示モードなどを含むエディタの状態を保存します
+func (a *App) SaveSessionState(rootDir string, state backend.SessionState) error {
+	return backend.SaveSessionState(rootDir, state)
+}
+
+func (a *App) LoadSessionState(rootDir string) (backend.SessionState, error) {
+	return backend.LoadSessionState(rootDir)
+}
+
 func (a *App) LoadTheorems(rootDir string) (map[string]string, error) {
 	return backend.LoadTheorems(rootDir)
 }
 
+// --- Workspaces ---
+
+func (a *App) ListWorkspaces(rootDir string) ([]backend.WorkspaceInfo, error) {
+	return backend.ListWorkspaces(rootDir)
+}
+
+// GetActiveWorkspace は最後に開いていたワークスペースの名前を返します。その状態は LoadSessionState で復元されます
+func (a *App) GetActiveWorkspace(rootDir string) (string, error) {
+	return backend.GetActiveWorkspace(rootDir)
+}
+
+func (a *App) SaveWorkspaceAs(rootDir string, name string) error {
+	return backend.SaveWorkspaceAs(rootDir, name)
+}
+
+// SwitchWorkspace は現在のタブを開いているワークスペースに書き戻し、name のワークスペースの状態を返します
+func (a *App) SwitchWorkspace(rootDir string, name string) (backend.SessionState, error) {
+	return backend.SwitchWorkspace(rootDir, name)
+}
+
+func (a *App) RenameWorkspace(rootDir string, oldName string, newName string) error {
+	return backend.RenameWorkspace(rootDir, oldName, newName)
+}
+
+func (a *App) DeleteWorkspace(rootDir string, name string) error {
+	return backend.DeleteWorkspace(rootDir, name)
+}
+
 // --- Project Settings ---
 
 func (a *App) GetFontSettings(rootDir string) (backend.FontSettings, error) {
-	config, err := backend.LoadProjectConfig(rootDir)
+	config, err := a.GetSettings(rootDir)
 	if err != nil {
 		return backend.FontSettings{}, err
 	}
@@ -96,20 +207,208 @@ func (a *App) GetFontSettings(rootDir string) (backend.FontSettings, error) {
 }
 
 func (a *App) SaveFontSettings(rootDir string, settings backend.FontSettings) error {
-	// 現在の設定を読み込み、フォント設定のみを更新して保存する
-	config, err := backend.LoadProjectConfig(rootDir)
+	_, err := a.PatchSettings(rootDir, map[string]any{
+		"font_settings": map[string]any{
+			"editor_font_family":  settings.EditorFontFamily,
+			"editor_font_size":    settings.EditorFontSize,
+			"preview_font_family": settings.PreviewFontFamily,
+			"preview_font_size":   settings.PreviewFontSize,
+		},
+	})
+	return err
+}
+
+// GetSettings はグローバル設定にプロジェクトの設定を重ねた設定を返します
+func (a *App) GetSettings(rootDir string) (backend.ProjectConfig, error) {
+	effective, err := a.GetEffectiveSettings(rootDir, "")
 	if err != nil {
-		return err
+		return backend.ProjectConfig{}, err
 	}
+	return effective.Config, nil
+}
 
-	config.FontSettings = settings
+// GetEffectiveSettings は notePath のノートを開いたときの設定と、それぞれの値を決めた層を返します。notePath は空でもかまいません
+func (a *App) GetEffectiveSettings(rootDir string, notePath string) (backend.EffectiveSettings, error) {
+	return backend.ResolveSettings(rootDir, a.configManager.GetDefaultSettings(), notePath)
+}
 
-	if err := backend.SaveProjectConfig(rootDir, config); err != nil {
-		return err
+// PatchSettings は patch に含まれる設定だけをプロジェクトで上書きし、フロントエンドに settings-updated で変更後の設定全体を通知します
+func (a *App) PatchSettings(rootDir string, patch map[string]any) (backend.ProjectConfig, error) {
+	if _, err := backend.PatchProjectConfig(rootDir, patch); err != nil {
+		return backend.ProjectConfig{}, err
 	}
+	return a.emitSettingsUpdated(rootDir)
+}
+
+// PatchDefaultSettings は patch に含まれる設定を全プロジェクトの既定値にし、rootDir のプロジェクトの設定を settings-updated で通知します
+func (a *App) PatchDefaultSettings(rootDir string, patch map[string]any) (backend.ProjectConfig, error) {
+	if err := a.configManager.PatchDefaultSettings(patch); err != nil {
+		return backend.ProjectConfig{}, err
+	}
+	return a.emitSettingsUpdated(rootDir)
+}
 
-	// フロントエンドに設定が更新されたことを通知
-	runtime.EventsEmit(a.ctx, "font-settings-updated", settings)
+// WatchProjectConfig は rootDir の config.json と macros.tex の監視を始め、それまで監視していたプロジェクトの監視をやめます。
+// アプリの外で変更された設定は検証してから settings-updated で通知し、読み込めない場合は設定を変えずに
+// settings-invalid で理由を通知します
+func (a *App) WatchProjectConfig(rootDir string) error {
+	a.stopWatchingProjectConfig()
 
+	watcher, err := backend.WatchProjectConfig(rootDir, backend.ConfigPollInterval, func(err error) {
+		if err == nil {
+			_, err = a.emitSettingsUpdated(rootDir)
+		}
+		if err != nil {
+			runtime.LogWarningf(a.ctx, "project config was not reloaded: %v", err)
+			runtime.EventsEmit(a.ctx, "settings-invalid", err.Error())
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	a.watcherMu.Lock()
+	defer a.watcherMu.Unlock()
+	if a.configWatcher != nil {
+		// 並行に呼ばれた別の WatchProjectConfig の監視は、後から始めたものに置き換える
+		a.configWatcher.Close()
+	}
+	a.configWatcher = watcher
 	return nil
 }
+
+func (a *App) stopWatchingProjectConfig() {
+	a.watcherMu.Lock()
+	defer a.watcherMu.Unlock()
+	if a.configWatcher != nil {
+		a.configWatcher.Close()
+		a.configWatcher = nil
+	}
+}
+
+func (a *App) emitSettingsUpdated(rootDir string) (backend.ProjectConfig, error) {
+	config, err := a.GetSettings(rootDir)
+	if err != nil {
+		return backend.ProjectConfig{}, err
+	}
+	runtime.EventsEmit(a.ctx, "settings-updated", config)
+	return config, nil
+}
+
+// GetDocumentOutline はノートの見出し・定理・証明を入れ子にした構造を返します
+func (a *App) GetDocumentOutline(path string, rootDir string) ([]backend.OutlineItem, error) {
+	return backend.GetDocumentOutline(path, rootDir)
+}
+
+// --- Search and Replace ---
+
+func (a *App) PreviewReplace(rootDir string, opts backend.ReplaceOptions) ([]backend.ReplacePreview, error) {
+	return backend.PreviewReplace(rootDir, opts)
+}
+
+func (a *App) ApplyReplace(rootDir string, opts backend.ReplaceOptions, paths []string) (backend.Operation, error) {
+	return backend.ApplyReplace(rootDir, opts, paths)
+}
+
+func (a *App) GetHistory(rootDir string) ([]backend.Operation, error) {
+	return backend.GetHistory(rootDir)
+}
+
+func (a *App) UndoLastOperation(rootDir string) (backend.Operation, error) {
+	return backend.UndoLastOperation(rootDir)
+}
+
+// --- Quick Open ---
+
+// QuickOpen はファイル・見出し・定理名をファジー検索します。このセッションで最近開いたファイルが優先されます
+func (a *App) QuickOpen(rootDir string, query string, limit int) ([]backend.QuickOpenItem, error) {
+	return backend.QuickOpen(rootDir, query, a.recentFiles.List(), limit)
+}
+
+// --- Tags ---
+
+func (a *App) ListTags(rootDir string) ([]backend.TagCount, error) {
+	return backend.ListTags(rootDir)
+}
+
+func (a *App) FindByTag(rootDir string, tag string) (backend.TagSearchResult, error) {
+	return backend.FindByTag(rootDir, tag)
+}
+
+func (a *App) RenameTag(rootDir string, oldTag string, newTag string) (backend.Operation, error) {
+	return backend.RenameTag(rootDir, oldTag, newTag)
+}
+
+func (a *App) RebuildIndex(rootDir string) error {
+	return backend.RebuildIndex(rootDir)
+}
+
+// --- Bibliography ---
+
+func (a *App) GetCitationCompletions(rootDir string) ([]backend.CitationCompletion, error) {
+	return backend.GetCitationCompletions(rootDir)
+}
+
+func (a *App) CheckCitations(rootDir string) ([]backend.BibIssue, error) {
+	return backend.CheckCitations(rootDir)
+}
+
+// GetNoteBibliography はプレビュー用に、編集中のノートの内容で引用された文献の一覧を返します
+func (a *App) GetNoteBibliography(rootDir string, content string) (backend.NoteBibliography, error) {
+	return backend.GetNoteBibliography(rootDir, content)
+}
+
+// --- Math Macros ---
+
+// GetMathMacros はプレビューの KaTeX と補完で使う、プロジェクトの数式のマクロを返します
+func (a *App) GetMathMacros(rootDir string) (backend.MathMacros, error) {
+	return backend.LoadMathMacros(rootDir)
+}
+
+// --- Export ---
+
+func (a *App) ExportLaTeX(rootDir string, opts backend.LaTeXExportOptions) (backend.ExportResult, error) {
+	return backend.ExportLaTeX(rootDir, opts)
+}
+
+func (a *App) ExportEPUB(rootDir string, opts backend.EPUBExportOptions) (backend.ExportResult, error) {
+	return backend.ExportEPUB(rootDir, opts)
+}
+
+func (a *App) GetTheoremCatalog(rootDir string, groupBy string) ([]backend.CatalogGroup, error) {
+	entries, err := backend.BuildTheoremCatalog(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	config, err := a.GetSettings(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return backend.GroupTheoremCatalog(entries, groupBy, config.TheoremSettings.UntaggedLabel)
+}
+
+func (a *App) ExportTheoremCatalog(rootDir string, opts backend.CatalogOptions) (backend.ExportResult, error) {
+	return backend.ExportTheoremCatalog(rootDir, opts)
+}
+
+func (a *App) ExportFlashcards(rootDir string, opts backend.FlashcardOptions) (backend.ExportResult, error) {
+	return backend.ExportFlashcards(rootDir, opts)
+}
+
+func (a *App) ExportSite(rootDir string, opts backend.SiteExportOptions) (backend.ExportResult, error) {
+	return backend.ExportSite(rootDir, opts, a.previewStyles)
+}
+
+// --- Vault archive ---
+
+func (a *App) ExportVault(rootDir string, outputPath string) (backend.ExportResult, error) {
+	return backend.ExportVault(rootDir, outputPath)
+}
+
+func (a *App) ImportVault(archivePath string, destDir string, opts backend.ImportOptions) (backend.ImportResult, error) {
+	return backend.ImportVault(archivePath, destDir, opts)
+}
+
+func (a *App) ImportObsidianVault(srcDir string, destDir string) (backend.ObsidianImportReport, error) {
+	return backend.ImportObsidianVault(srcDir, destDir)
+}
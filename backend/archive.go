@@ -0,0 +1,347 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	vaultArchiveManifestName = "theorem-note-archive.json"
+	vaultArchiveVersion      = 1
+)
+
+var (
+	ErrInvalidArchive            = errors.New("file is not a vault archive")
+	ErrUnsupportedArchiveVersion = errors.New("vault archive was created by a newer version")
+)
+
+const (
+	ImportConflictFile    = "file"
+	ImportConflictTheorem = "theorem"
+)
+
+// vaultArchiveManifest はアーカイブの先頭に置く、環境に依存しないメタデータです。
+// 定理の場所は theorems.json と異なり、ルートからの相対パス (スラッシュ区切り) で保持します
+type vaultArchiveManifest struct {
+	Version  int               `json:"version"`
+	Created  time.Time         `json:"created"`
+	Theorems map[string]string `json:"theorems"`
+}
+
+// ImportOptions はアーカイブの取り込み方を指定します。
+// Overwrite が false の場合、内容の異なる既存のファイルは上書きせずに競合として報告します
+type ImportOptions struct {
+	Overwrite bool `json:"overwrite"`
+}
+
+// ImportConflict は取り込み先の保管庫と競合した箇所です。
+// Kind が theorem の場合は、Theorem がすでに ExistingPath で定義されていることを表します
+type ImportConflict struct {
+	Kind         string `json:"kind"`
+	Path         string `json:"path"`
+	Theorem      string `json:"theorem"`
+	ExistingPath string `json:"existing_path"`
+}
+
+// ImportResult は取り込みで書き込んだファイル、同じ内容のため書き込まなかったファイル、競合を保持します
+type ImportResult struct {
+	Files     []string         `json:"files"`
+	Unchanged []string         `json:"unchanged"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// ExportVault は保管庫のノート、添付ファイル、プロジェクトの設定を 1 つのアーカイブにまとめます。
+// セッションや履歴、インデックスなどこの環境でしか意味を持たないものは含めず、取り込むときに作り直します
+func ExportVault(rootDir string, outputPath string) (ExportResult, error) {
+	if rootDir == "" {
+		return ExportResult{}, os.ErrInvalid
+	}
+	if outputPath == "" {
+		return ExportResult{}, ErrEmptyOutputPath
+	}
+
+	theorems, err := theoremLocations(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	manifest, err := json.MarshalIndent(vaultArchiveManifest{
+		Version:  vaultArchiveVersion,
+		Created:  time.Now().UTC(),
+		Theorems: theorems,
+	}, "", "  ")
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	files, err := listVaultFiles(rootDir, outputPath)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return ExportResult{}, err
+	}
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if err := writeVaultArchive(out, rootDir, manifest, files); err != nil {
+		out.Close()
+		return ExportResult{}, err
+	}
+	if err := out.Close(); err != nil {
+		return ExportResult{}, err
+	}
+	return ExportResult{Files: []string{outputPath}, Warnings: []string{}}, nil
+}
+
+// listVaultFiles はアーカイブに含めるファイルを、ルートからの相対パス (スラッシュ区切り) で返します。
+// ドットで始まるファイルとディレクトリは、プロジェクトの設定 (config.json) と数式マクロ (macros.tex) を除いて含めません
+func listVaultFiles(rootDir string, outputPath string) ([]string, error) {
+	configPath, err := getProjectConfigPath(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	configDir := filepath.Dir(configPath)
+	shared := []string{configPath, filepath.Join(configDir, macrosFileName)}
+	outputPath, _ = filepath.Abs(outputPath)
+
+	var files []string
+	err = filepath.WalkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		hidden := p != rootDir && strings.HasPrefix(d.Name(), ".")
+		if d.IsDir() {
+			if hidden && p != configDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || hidden || filepath.Dir(p) == configDir && !slices.Contains(shared, p) {
+			return nil
+		}
+		// 保管庫の中に書き出す場合に、書き出し中のアーカイブ自身を含めないようにする
+		if abs, err := filepath.Abs(p); err == nil && abs == outputPath {
+			return nil
+		}
+		rel, err := filepath.Rel(rootDir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func writeVaultArchive(w io.Writer, rootDir string, manifest []byte, files []string) error {
+	zw := zip.NewWriter(w)
+	fw, err := zw.Create(vaultArchiveManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(manifest); err != nil {
+		return err
+	}
+
+	for _, rel := range files {
+		src := filepath.Join(rootDir, filepath.FromSlash(rel))
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// theoremLocations はプロジェクト内の定理と、それを定義するノートの相対パス (スラッシュ区切り) を返します
+func theoremLocations(rootDir string) (map[string]string, error) {
+	theorems := make(map[string]string)
+	if _, err := os.Stat(rootDir); os.IsNotExist(err) {
+		return theorems, nil
+	}
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range files {
+		content, err := ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := indexKey(rootDir, p)
+		for _, theorem := range buildIndexEntry(content, settings).Theorems {
+			theorems[theorem.Name] = key
+		}
+	}
+	return theorems, nil
+}
+
+// ImportVault は ExportVault で作成したアーカイブを destDir に展開し、定理とインデックスを作り直します。
+// 既存の保管庫に取り込む場合は、内容の異なるファイルと、別のノートで定義済みの定理を競合として報告します
+func ImportVault(archivePath string, destDir string, opts ImportOptions) (ImportResult, error) {
+	if destDir == "" {
+		return ImportResult{}, os.ErrInvalid
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return ImportResult{}, err
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		if errors.Is(err, zip.ErrFormat) {
+			return ImportResult{}, ErrInvalidArchive
+		}
+		return ImportResult{}, err
+	}
+	defer r.Close()
+
+	manifest, err := readVaultArchiveManifest(&r.Reader)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	// 展開する前に、アーカイブの外に書き込むパスがないことを確かめる
+	for _, f := range r.File {
+		if f.Name != vaultArchiveManifestName && !isSafeArchivePath(f.Name) {
+			return ImportResult{}, ErrInvalidArchive
+		}
+	}
+
+	existing, err := theoremLocations(destDir)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{Files: []string{}, Unchanged: []string{}, Conflicts: []ImportConflict{}}
+	for _, f := range r.File {
+		if f.Name == vaultArchiveManifestName || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		data, err := readArchiveFile(f)
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		dst := filepath.Join(destDir, filepath.FromSlash(f.Name))
+		current, err := os.ReadFile(dst)
+		switch {
+		case err == nil && bytes.Equal(current, data):
+			result.Unchanged = append(result.Unchanged, dst)
+			continue
+		case err == nil && !opts.Overwrite:
+			result.Conflicts = append(result.Conflicts, ImportConflict{Kind: ImportConflictFile, Path: f.Name})
+			continue
+		case err != nil && !os.IsNotExist(err):
+			return ImportResult{}, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return ImportResult{}, err
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return ImportResult{}, err
+		}
+		result.Files = append(result.Files, dst)
+	}
+
+	names := make([]string, 0, len(manifest.Theorems))
+	for name := range manifest.Theorems {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if existingPath, ok := existing[name]; ok && existingPath != manifest.Theorems[name] {
+			result.Conflicts = append(result.Conflicts, ImportConflict{
+				Kind:         ImportConflictTheorem,
+				Path:         manifest.Theorems[name],
+				Theorem:      name,
+				ExistingPath: existingPath,
+			})
+		}
+	}
+
+	if err := RebuildIndex(destDir); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+func readVaultArchiveManifest(r *zip.Reader) (vaultArchiveManifest, error) {
+	for _, f := range r.File {
+		if f.Name != vaultArchiveManifestName {
+			continue
+		}
+		data, err := readArchiveFile(f)
+		if err != nil {
+			return vaultArchiveManifest{}, err
+		}
+		var manifest vaultArchiveManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return vaultArchiveManifest{}, ErrInvalidArchive
+		}
+		if manifest.Version > vaultArchiveVersion {
+			return vaultArchiveManifest{}, ErrUnsupportedArchiveVersion
+		}
+		if manifest.Theorems == nil {
+			manifest.Theorems = make(map[string]string)
+		}
+		return manifest, nil
+	}
+	return vaultArchiveManifest{}, ErrInvalidArchive
+}
+
+func readArchiveFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// isSafeArchivePath はアーカイブ内のパスが展開先のディレクトリの中を指すかを判定します
+func isSafeArchivePath(name string) bool {
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) || filepath.IsAbs(filepath.FromSlash(name)) {
+		return false
+	}
+	cleaned := path.Clean(name)
+	return cleaned != "." && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
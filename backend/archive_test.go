@@ -0,0 +1,177 @@
+package backend
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func writeTestVault(t *testing.T, rootDir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestExportImportVault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "src")
+	writeTestVault(t, srcDir, map[string]string{
+		"algebra/group.md":           "<theorem name=\"ラグランジュの定理\">\n主張\n</theorem>\n",
+		"topology.md":                "<theorem name=\"ハイネ・ボレル\">\n主張\n</theorem>\n",
+		"_images/fig.png":            "png",
+		".theorem-note/config.json":  `{"font_settings":{}}`,
+		".theorem-note/macros.tex":   `\newcommand{\R}{\mathbb{R}}`,
+		".theorem-note/session.json": `["/home/someone/src/topology.md"]`,
+		".git/HEAD":                  "ref",
+	})
+	if err := RebuildIndex(srcDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	archivePath := filepath.Join(srcDir, "vault.zip")
+	if _, err := ExportVault(srcDir, archivePath); err != nil {
+		t.Fatalf("ExportVault failed: %v", err)
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	r.Close()
+	expectedNames := []string{vaultArchiveManifestName, ".theorem-note/config.json", ".theorem-note/macros.tex", "_images/fig.png", "algebra/group.md", "topology.md"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected archive entries %v, but got %v", expectedNames, names)
+	}
+
+	// 新しいディレクトリへの取り込みでは、定理の場所が取り込み先のパスになる
+	newDir := filepath.Join(tmpDir, "new")
+	result, err := ImportVault(archivePath, newDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportVault failed: %v", err)
+	}
+	if len(result.Files) != 5 || len(result.Conflicts) != 0 {
+		t.Errorf("Expected 5 imported files and no conflicts, but got %+v", result)
+	}
+	theorems, err := LoadTheorems(newDir)
+	if err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	expectedTheorems := map[string]string{
+		"ラグランジュの定理": filepath.Join("algebra", "group.md"),
+		"ハイネ・ボレル":   "topology.md",
+	}
+	if !reflect.DeepEqual(theorems, expectedTheorems) {
+		t.Errorf("Expected theorems %v, but got %v", expectedTheorems, theorems)
+	}
+	if _, err := os.Stat(filepath.Join(newDir, ".theorem-note", "index.json")); err != nil {
+		t.Errorf("Index was not rebuilt: %v", err)
+	}
+	if content, _ := ReadFile(filepath.Join(newDir, ".theorem-note", "macros.tex")); content != `\newcommand{\R}{\mathbb{R}}` {
+		t.Errorf("Expected macros.tex to be imported, but got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(newDir, ".theorem-note", "session.json")); !os.IsNotExist(err) {
+		t.Errorf("Session should not be imported")
+	}
+
+	// 既存の保管庫への取り込みでは、異なる内容のファイルと別のノートの定理が競合になる
+	existingDir := filepath.Join(tmpDir, "existing")
+	writeTestVault(t, existingDir, map[string]string{
+		"algebra/group.md": "<theorem name=\"ラグランジュの定理\">\n主張\n</theorem>\n",
+		"topology.md":      "# 位相空間\n",
+		"analysis.md":      "<theorem name=\"ハイネ・ボレル\">\n別の主張\n</theorem>\n",
+	})
+	result, err = ImportVault(archivePath, existingDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportVault failed: %v", err)
+	}
+	expectedConflicts := []ImportConflict{
+		{Kind: ImportConflictFile, Path: "topology.md"},
+		{Kind: ImportConflictTheorem, Path: "topology.md", Theorem: "ハイネ・ボレル", ExistingPath: "analysis.md"},
+	}
+	if !reflect.DeepEqual(result.Conflicts, expectedConflicts) {
+		t.Errorf("Expected conflicts %+v, but got %+v", expectedConflicts, result.Conflicts)
+	}
+	if !slices.Equal(result.Unchanged, []string{filepath.Join(existingDir, "algebra", "group.md")}) {
+		t.Errorf("Expected algebra/group.md to be unchanged, but got %v", result.Unchanged)
+	}
+	if content, _ := ReadFile(filepath.Join(existingDir, "topology.md")); content != "# 位相空間\n" {
+		t.Errorf("Conflicting file should not be overwritten, but got %q", content)
+	}
+
+	result, err = ImportVault(archivePath, existingDir, ImportOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("ImportVault failed: %v", err)
+	}
+	if !slices.Contains(result.Files, filepath.Join(existingDir, "topology.md")) {
+		t.Errorf("Expected topology.md to be overwritten, but got %+v", result)
+	}
+}
+
+func TestImportVault_InvalidArchive(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeArchive := func(name string, entries map[string]string) string {
+		path := filepath.Join(tmpDir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Failed to create archive: %v", err)
+		}
+		zw := zip.NewWriter(f)
+		for entry, content := range entries {
+			w, err := zw.Create(entry)
+			if err != nil {
+				t.Fatalf("Failed to add entry: %v", err)
+			}
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		f.Close()
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		archive string
+		want    error
+	}{
+		{"no manifest", writeArchive("plain.zip", map[string]string{"a.md": "a"}), ErrInvalidArchive},
+		{"path traversal", writeArchive("slip.zip", map[string]string{vaultArchiveManifestName: `{"version":1}`, "../evil.md": "x"}), ErrInvalidArchive},
+		{"newer version", writeArchive("new.zip", map[string]string{vaultArchiveManifestName: `{"version":99}`}), ErrUnsupportedArchiveVersion},
+		{"not a zip", filepath.Join(tmpDir, "plain.md"), ErrInvalidArchive},
+	}
+	os.WriteFile(filepath.Join(tmpDir, "plain.md"), []byte("not a zip"), 0644)
+
+	for _, tt := range tests {
+		_, err := ImportVault(tt.archive, filepath.Join(tmpDir, "dest"), ImportOptions{})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, but got %v", tt.name, tt.want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "evil.md")); !os.IsNotExist(err) {
+		t.Errorf("Path traversal entry was extracted")
+	}
+}
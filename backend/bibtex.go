@@ -0,0 +1,559 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var ErrMalformedBibEntry = errors.New("malformed BibTeX entry")
+
+var (
+	// citationRegexp は Pandoc と同じ [@key] や [@key, p. 3; @other] の形式の引用です
+	citationRegexp    = regexp.MustCompile(`\[([^\[\]]*@[^\[\]]*)\]`)
+	citationKeyRegexp = regexp.MustCompile(`(?:^|\s)-?@([\p{L}\p{N}_][\p{L}\p{N}_:.#$%&+?<>~/-]*)`)
+	// bibAuthorSeparator は author の人名の区切りです
+	bibAuthorSeparator = regexp.MustCompile(`\s+and\s+`)
+)
+
+// bibMonths は BibTeX の月のマクロです
+var bibMonths = map[string]string{
+	"jan": "January", "feb": "February", "mar": "March", "apr": "April", "may": "May", "jun": "June",
+	"jul": "July", "aug": "August", "sep": "September", "oct": "October", "nov": "November", "dec": "December",
+}
+
+// BibEntry は .bib ファイルの 1 つの文献です。Fields のキーは小文字で、値は BibTeX の値のまま保持します。
+// Path は .bib ファイルのルートからの相対パス (スラッシュ区切り) で、Line は 1 始まりです
+type BibEntry struct {
+	Key    string            `json:"key"`
+	Type   string            `json:"type"`
+	Fields map[string]string `json:"fields"`
+	Path   string            `json:"path"`
+	Line   int               `json:"line"`
+}
+
+// BibIssue は .bib ファイルやノートの引用の問題です。Key は問題のある引用キーです
+type BibIssue struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// Bibliography はプロジェクト内のすべての .bib ファイルの文献と、読み込みで見つかった問題を保持します
+type Bibliography struct {
+	Entries []BibEntry `json:"entries"`
+	Issues  []BibIssue `json:"issues"`
+}
+
+// Lookup は引用キーの文献を返します。BibTeX と同じく大文字と小文字を区別しません
+func (b Bibliography) Lookup(key string) (BibEntry, bool) {
+	for _, entry := range b.Entries {
+		if strings.EqualFold(entry.Key, key) {
+			return entry, true
+		}
+	}
+	return BibEntry{}, false
+}
+
+// Citation はノート内の [@key] の 1 つの引用です。Locator は p. 3 のようなページなどの指定です
+type Citation struct {
+	Key     string `json:"key"`
+	Locator string `json:"locator"`
+	Line    int    `json:"line"`
+}
+
+// CitationCompletion は引用キーの補完候補です。Label は著者・年・タイトルをまとめた表示用の文字列です
+type CitationCompletion struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+// FormattedReference は参考文献一覧の 1 項目です。Text は Markdown で、Number は引用された順の番号です
+type FormattedReference struct {
+	Number int    `json:"number"`
+	Key    string `json:"key"`
+	Text   string `json:"text"`
+}
+
+// NoteBibliography はノートで引用された文献の一覧と、.bib に見つからなかった引用を保持します
+type NoteBibliography struct {
+	References []FormattedReference `json:"references"`
+	Unknown    []Citation           `json:"unknown"`
+}
+
+// LoadBibliography はプロジェクト内の .bib ファイルをすべて読み込みます。
+// 同じ引用キーが複数ある場合は最初のものを使い、残りを問題として報告します
+func LoadBibliography(rootDir string) (Bibliography, error) {
+	if rootDir == "" {
+		return Bibliography{}, os.ErrInvalid
+	}
+	files, err := listFilesByExt(rootDir, ".bib")
+	if err != nil {
+		return Bibliography{}, err
+	}
+
+	bib := Bibliography{Entries: []BibEntry{}, Issues: []BibIssue{}}
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return Bibliography{}, err
+		}
+		rel, _ := indexKey(rootDir, path)
+		entries, issues := ParseBibTeX(content)
+		for _, issue := range issues {
+			issue.Path = rel
+			bib.Issues = append(bib.Issues, issue)
+		}
+		for _, entry := range entries {
+			entry.Path = rel
+			if existing, ok := bib.Lookup(entry.Key); ok {
+				bib.Issues = append(bib.Issues, BibIssue{
+					Path:    rel,
+					Line:    entry.Line,
+					Key:     entry.Key,
+					Message: fmt.Sprintf("duplicate key; already defined in %s:%d", existing.Path, existing.Line),
+				})
+				continue
+			}
+			bib.Entries = append(bib.Entries, entry)
+		}
+	}
+	return bib, nil
+}
+
+// ParseBibTeX は .bib ファイルの内容を読み取ります。@string のマクロと # による連結を展開し、
+// @comment と @preamble は読み飛ばします。読み取れなかった文献は問題として報告し、次の文献から続けます
+func ParseBibTeX(content string) ([]BibEntry, []BibIssue) {
+	p := &bibParser{src: content, macros: make(map[string]string)}
+	entries := []BibEntry{}
+	issues := []BibIssue{}
+	for {
+		at := strings.IndexByte(p.src[p.pos:], '@')
+		if at < 0 {
+			return entries, issues
+		}
+		p.pos += at
+		start := p.pos
+		entry, err := p.parseEntry()
+		if err != nil {
+			issues = append(issues, BibIssue{Line: p.lineAt(start), Message: err.Error()})
+			p.pos = start + 1
+			continue
+		}
+		if entry != nil {
+			entry.Line = p.lineAt(start)
+			entries = append(entries, *entry)
+		}
+	}
+}
+
+type bibParser struct {
+	src    string
+	pos    int
+	macros map[string]string
+}
+
+func (p *bibParser) lineAt(pos int) int {
+	return strings.Count(p.src[:pos], "\n") + 1
+}
+
+func (p *bibParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *bibParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if unicode.IsSpace(rune(c)) || strings.IndexByte(`{}(),="#%'`, c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseEntry は @ から始まる 1 つの項目を読みます。文献でない項目の場合は nil を返します
+func (p *bibParser) parseEntry() (*BibEntry, error) {
+	p.pos++
+	kind := strings.ToLower(p.identifier())
+	p.skipSpace()
+	if kind == "" || p.pos >= len(p.src) || (p.src[p.pos] != '{' && p.src[p.pos] != '(') {
+		if kind == "comment" || kind == "" {
+			return nil, nil
+		}
+		return nil, ErrMalformedBibEntry
+	}
+	closer := byte('}')
+	if p.src[p.pos] == '(' {
+		closer = ')'
+	}
+
+	switch kind {
+	case "comment", "preamble":
+		if _, err := p.braced(p.src[p.pos], closer); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case "string":
+		p.pos++
+		p.skipSpace()
+		name := strings.ToLower(p.identifier())
+		value, err := p.fieldValue()
+		if err != nil {
+			return nil, err
+		}
+		p.macros[name] = value
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != closer {
+			return nil, ErrMalformedBibEntry
+		}
+		p.pos++
+		return nil, nil
+	}
+
+	p.pos++
+	p.skipSpace()
+	key := strings.TrimSpace(p.identifier())
+	if key == "" {
+		return nil, ErrMalformedBibEntry
+	}
+	entry := &BibEntry{Key: key, Type: kind, Fields: make(map[string]string)}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			p.skipSpace()
+		}
+		if p.pos >= len(p.src) {
+			return nil, ErrMalformedBibEntry
+		}
+		if p.src[p.pos] == closer {
+			p.pos++
+			return entry, nil
+		}
+		name := strings.ToLower(p.identifier())
+		if name == "" {
+			return nil, ErrMalformedBibEntry
+		}
+		value, err := p.fieldValue()
+		if err != nil {
+			return nil, err
+		}
+		entry.Fields[name] = value
+	}
+}
+
+// fieldValue は = に続く値を読みます。{...}、"..."、数字、マクロを # で連結できます
+func (p *bibParser) fieldValue() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return "", ErrMalformedBibEntry
+	}
+	p.pos++
+
+	var b strings.Builder
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return "", ErrMalformedBibEntry
+		}
+		switch c := p.src[p.pos]; c {
+		case '{':
+			part, err := p.braced('{', '}')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(part)
+		case '"':
+			part, err := p.braced('"', '"')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(part)
+		default:
+			name := p.identifier()
+			if name == "" {
+				return "", ErrMalformedBibEntry
+			}
+			lower := strings.ToLower(name)
+			if value, ok := p.macros[lower]; ok {
+				b.WriteString(value)
+			} else if month, ok := bibMonths[lower]; ok {
+				b.WriteString(month)
+			} else {
+				b.WriteString(name)
+			}
+		}
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '#' {
+			p.pos++
+			continue
+		}
+		return b.String(), nil
+	}
+}
+
+// braced は open から対応する closer までの中身を返します。中の {} の入れ子を数えます
+func (p *bibParser) braced(open byte, closer byte) (string, error) {
+	p.pos++
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\':
+			p.pos++
+		case c == closer && depth == 0:
+			p.pos++
+			return p.src[start : p.pos-1], nil
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	return "", ErrMalformedBibEntry
+}
+
+// ExtractCitations はノートの [@key] の引用を返します。コードや数式の中は対象外です
+func ExtractCitations(content string) []Citation {
+	citations := []Citation{}
+	line := 0
+	ParseMarkdown(content).Walk(func(node *Node) bool {
+		if node.StartLine > 0 {
+			line = node.StartLine
+		}
+		if node.Kind != NodeKindText {
+			return true
+		}
+		for _, m := range citationRegexp.FindAllStringSubmatch(node.Literal, -1) {
+			citations = append(citations, parseCitationGroup(m[1], line)...)
+		}
+		return true
+	})
+	return citations
+}
+
+// parseCitationGroup は [...] の中身を ; で区切り、それぞれの引用キーとページなどの指定を取り出します
+func parseCitationGroup(group string, line int) []Citation {
+	var citations []Citation
+	for _, item := range strings.Split(group, ";") {
+		m := citationKeyRegexp.FindStringSubmatchIndex(item)
+		if m == nil {
+			continue
+		}
+		// Pandoc と同じく、キーの末尾の句読点はキーに含めない
+		key := strings.TrimRight(item[m[2]:m[3]], ".:")
+		locator := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(item[m[1]:]), ","))
+		citations = append(citations, Citation{Key: key, Locator: locator, Line: line})
+	}
+	return citations
+}
+
+// GetCitationCompletions は引用キーの補完候補を、キーの順に返します
+func GetCitationCompletions(rootDir string) ([]CitationCompletion, error) {
+	bib, err := LoadBibliography(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	completions := make([]CitationCompletion, 0, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		label := bibText(entry.Fields["title"])
+		if authors := bibText(bibAuthors(entry)); authors != "" {
+			label = authors + " (" + bibText(entry.Fields["year"]) + ") " + label
+		}
+		completions = append(completions, CitationCompletion{Key: entry.Key, Type: entry.Type, Label: label})
+	}
+	slices.SortFunc(completions, func(a, b CitationCompletion) int { return strings.Compare(a.Key, b.Key) })
+	return completions, nil
+}
+
+// CheckCitations はプロジェクト内のノートの引用のうち、.bib に見つからないものと、.bib の問題を報告します
+func CheckCitations(rootDir string) ([]BibIssue, error) {
+	bib, err := LoadBibliography(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := bib.Issues
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := indexKey(rootDir, path)
+		for _, citation := range ExtractCitations(content) {
+			if _, ok := bib.Lookup(citation.Key); !ok {
+				issues = append(issues, BibIssue{Path: rel, Line: citation.Line, Key: citation.Key, Message: "unknown citation key"})
+			}
+		}
+	}
+	return issues, nil
+}
+
+// GetNoteBibliography はノートで引用された文献を、最初に引用された順に番号を付けて整形します
+func GetNoteBibliography(rootDir string, content string) (NoteBibliography, error) {
+	bib, err := LoadBibliography(rootDir)
+	if err != nil {
+		return NoteBibliography{}, err
+	}
+	result := NoteBibliography{References: []FormattedReference{}, Unknown: []Citation{}}
+	for _, citation := range ExtractCitations(content) {
+		entry, ok := bib.Lookup(citation.Key)
+		if !ok {
+			result.Unknown = append(result.Unknown, citation)
+			continue
+		}
+		if slices.ContainsFunc(result.References, func(r FormattedReference) bool { return r.Key == entry.Key }) {
+			continue
+		}
+		result.References = append(result.References, FormattedReference{
+			Number: len(result.References) + 1,
+			Key:    entry.Key,
+			Text:   FormatReference(entry),
+		})
+	}
+	return result, nil
+}
+
+// bibPart は整形した文献の一部です。emph が true の部分は書名や雑誌名として斜体にします
+type bibPart struct {
+	text string
+	emph bool
+}
+
+// referenceParts は文献の種類ごとに、著者、タイトル、掲載誌などの並びを決めます
+func referenceParts(entry BibEntry) [][]bibPart {
+	f := func(name string, emph bool) bibPart {
+		return bibPart{text: entry.Fields[name], emph: emph}
+	}
+	var volume bibPart
+	if v := entry.Fields["volume"]; v != "" {
+		volume.text = v
+		if n := entry.Fields["number"]; n != "" {
+			volume.text += "(" + n + ")"
+		}
+		if pages := entry.Fields["pages"]; pages != "" {
+			volume.text += ":" + pages
+		}
+	} else if pages := entry.Fields["pages"]; pages != "" {
+		volume.text = "pp. " + pages
+	}
+
+	authors := bibPart{text: bibAuthors(entry)}
+	switch entry.Type {
+	case "article":
+		return [][]bibPart{{authors}, {f("title", false)}, {f("journal", true), volume, f("year", false)}}
+	case "book":
+		return [][]bibPart{{authors}, {f("title", true)}, {f("edition", false), f("publisher", false), f("year", false)}}
+	case "inproceedings", "incollection", "conference":
+		booktitle := f("booktitle", true)
+		if booktitle.text != "" {
+			booktitle.text = "In " + booktitle.text
+		}
+		return [][]bibPart{{authors}, {f("title", false)}, {booktitle, volume, f("publisher", false), f("year", false)}}
+	case "phdthesis", "mastersthesis":
+		return [][]bibPart{{authors}, {f("title", true)}, {f("school", false), f("year", false)}}
+	}
+	return [][]bibPart{{authors}, {f("title", false)}, {f("howpublished", false), f("publisher", false), f("note", false), f("year", false)}}
+}
+
+// FormatReference は文献を「著者. タイトル. 掲載誌, 巻(号):ページ, 年.」の形の Markdown にします
+func FormatReference(entry BibEntry) string {
+	return formatReference(entry, func(part bibPart) string {
+		text := bibText(part.text)
+		if part.emph {
+			return "*" + text + "*"
+		}
+		return text
+	})
+}
+
+// formatReferenceLaTeX は文献を LaTeX の thebibliography 用に整形します。.bib の値は TeX なのでそのまま使います
+func formatReferenceLaTeX(entry BibEntry) string {
+	return formatReference(entry, func(part bibPart) string {
+		if part.emph {
+			return `\emph{` + part.text + "}"
+		}
+		return part.text
+	})
+}
+
+func formatReference(entry BibEntry, render func(bibPart) string) string {
+	var sentences []string
+	for _, sentence := range referenceParts(entry) {
+		var parts []string
+		for _, part := range sentence {
+			if strings.TrimSpace(part.text) != "" {
+				parts = append(parts, render(part))
+			}
+		}
+		if len(parts) > 0 {
+			sentences = append(sentences, strings.TrimSuffix(strings.Join(parts, ", "), ".")+".")
+		}
+	}
+	text := strings.Join(sentences, " ")
+	if url := entry.Fields["url"]; url != "" {
+		text += " " + url
+	}
+	return text
+}
+
+// bibAuthors は author (なければ editor) を「A, B and C」の形にします。「姓, 名」は「名 姓」に並べ替え、{} はそのまま残します
+func bibAuthors(entry BibEntry) string {
+	field := entry.Fields["author"]
+	if field == "" {
+		field = entry.Fields["editor"]
+	}
+	if strings.TrimSpace(field) == "" {
+		return ""
+	}
+
+	var names []string
+	for _, name := range bibAuthorSeparator.Split(strings.TrimSpace(field), -1) {
+		if last, first, ok := strings.Cut(name, ","); ok {
+			name = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		names = append(names, strings.TrimSpace(name))
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// bibText は BibTeX の値から {} を取り除き、よく使われる記号を文字にします
+func bibText(value string) string {
+	value = strings.NewReplacer(`\&`, "&", `\%`, "%", `\_`, "_", `\$`, "$", "---", "—", "--", "–", "~", " ").Replace(value)
+	value = strings.Map(func(r rune) rune {
+		if r == '{' || r == '}' {
+			return -1
+		}
+		return r
+	}, value)
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// citeCommand は [@key] の中身を \cite にします。引用キーが 1 つの場合だけ、ページなどの指定を付けます
+func citeCommand(citations []Citation) string {
+	keys := make([]string, len(citations))
+	for i, citation := range citations {
+		keys[i] = citation.Key
+	}
+	if len(citations) == 1 && citations[0].Locator != "" {
+		return `\cite[` + latexEscape(citations[0].Locator) + "]{" + keys[0] + "}"
+	}
+	return `\cite{` + strings.Join(keys, ",") + "}"
+}
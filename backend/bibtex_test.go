@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBibTeX = `% 参考文献
+@string{ams = "American Mathematical Society"}
+
+@comment{ここは読み飛ばす}
+
+@article{knuth1984,
+  author  = {Knuth, Donald E.},
+  title   = {Literate {P}rogramming},
+  journal = "The Computer Journal",
+  volume  = 27,
+  number  = {2},
+  pages   = {97--111},
+  month   = may,
+  year    = 1984,
+}
+
+@book{Rudin1976,
+  author    = {Walter Rudin},
+  title     = {Principles of Mathematical Analysis},
+  edition   = {3rd},
+  publisher = ams # " Press",
+  year      = {1976}
+}
+
+@misc{broken,
+  title = {unterminated
+`
+
+func TestParseBibTeX(t *testing.T) {
+	entries, issues := ParseBibTeX(testBibTeX)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, but got %+v", entries)
+	}
+
+	knuth := entries[0]
+	if knuth.Key != "knuth1984" || knuth.Type != "article" || knuth.Line != 6 {
+		t.Errorf("Unexpected entry: %+v", knuth)
+	}
+	expected := map[string]string{
+		"author":  "Knuth, Donald E.",
+		"title":   "Literate {P}rogramming",
+		"journal": "The Computer Journal",
+		"volume":  "27",
+		"number":  "2",
+		"pages":   "97--111",
+		"month":   "May",
+		"year":    "1984",
+	}
+	if !reflect.DeepEqual(knuth.Fields, expected) {
+		t.Errorf("Expected fields %v, but got %v", expected, knuth.Fields)
+	}
+	if got := entries[1].Fields["publisher"]; got != "American Mathematical Society Press" {
+		t.Errorf("Expected the @string macro to be expanded, but got %q", got)
+	}
+
+	if len(issues) != 1 || issues[0].Line != 25 {
+		t.Errorf("Expected an issue for the unterminated entry, but got %+v", issues)
+	}
+}
+
+func TestExtractCitations(t *testing.T) {
+	content := "# 序\n\n" +
+		"これは [@knuth1984, p. 3] と [see @Rudin1976; @other] による。\n\n" +
+		"`[@code]` や $[@math]$ や foo@example.com は引用ではない。\n\n" +
+		"最後に [@knuth1984].\n"
+
+	expected := []Citation{
+		{Key: "knuth1984", Locator: "p. 3", Line: 3},
+		{Key: "Rudin1976", Line: 3},
+		{Key: "other", Line: 3},
+		{Key: "knuth1984", Line: 7},
+	}
+	if got := ExtractCitations(content); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+}
+
+func TestFormatReference(t *testing.T) {
+	entries, _ := ParseBibTeX(testBibTeX)
+
+	tests := []struct {
+		entry    BibEntry
+		expected string
+	}{
+		{entries[0], "Donald E. Knuth. Literate Programming. *The Computer Journal*, 27(2):97–111, 1984."},
+		{entries[1], "Walter Rudin. *Principles of Mathematical Analysis*. 3rd, American Mathematical Society Press, 1976."},
+		{
+			BibEntry{Type: "misc", Fields: map[string]string{"author": "A and B and {C} D", "title": "Notes", "url": "https://example.com"}},
+			"A, B and C D. Notes. https://example.com",
+		},
+	}
+	for _, test := range tests {
+		if got := FormatReference(test.entry); got != test.expected {
+			t.Errorf("Expected %q, but got %q", test.expected, got)
+		}
+	}
+}
+
+func writeTestBibliographyVault(t *testing.T) string {
+	t.Helper()
+	rootDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	writeTestVault(t, rootDir, map[string]string{
+		"refs/main.bib": testBibTeX,
+		"refs/more.bib": "@misc{knuth1984, title = {Duplicate}}\n@misc{serre, author = {Serre}, title = {Cours}}\n",
+		"note.md":       "# ノート\n\n[@Rudin1976, Theorem 1.1] と [@missing] と [@knuth1984] と [@rudin1976]\n",
+	})
+	return rootDir
+}
+
+func TestLoadBibliography(t *testing.T) {
+	rootDir := writeTestBibliographyVault(t)
+	defer os.RemoveAll(rootDir)
+
+	bib, err := LoadBibliography(rootDir)
+	if err != nil {
+		t.Fatalf("LoadBibliography failed: %v", err)
+	}
+	if len(bib.Entries) != 3 {
+		t.Errorf("Expected 3 entries, but got %+v", bib.Entries)
+	}
+	if entry, ok := bib.Lookup("KNUTH1984"); !ok || entry.Path != "refs/main.bib" {
+		t.Errorf("Expected knuth1984 from refs/main.bib, but got %+v", entry)
+	}
+
+	var duplicate bool
+	for _, issue := range bib.Issues {
+		if issue.Key == "knuth1984" && issue.Path == "refs/more.bib" && strings.Contains(issue.Message, "refs/main.bib:6") {
+			duplicate = true
+		}
+	}
+	if !duplicate || len(bib.Issues) != 2 {
+		t.Errorf("Expected a duplicate key issue and a parse issue, but got %+v", bib.Issues)
+	}
+
+	completions, err := GetCitationCompletions(rootDir)
+	if err != nil {
+		t.Fatalf("GetCitationCompletions failed: %v", err)
+	}
+	if len(completions) != 3 || completions[0].Key != "Rudin1976" || completions[0].Label != "Walter Rudin (1976) Principles of Mathematical Analysis" {
+		t.Errorf("Unexpected completions: %+v", completions)
+	}
+}
+
+func TestCheckCitations(t *testing.T) {
+	rootDir := writeTestBibliographyVault(t)
+	defer os.RemoveAll(rootDir)
+
+	issues, err := CheckCitations(rootDir)
+	if err != nil {
+		t.Fatalf("CheckCitations failed: %v", err)
+	}
+	var unknown []BibIssue
+	for _, issue := range issues {
+		if issue.Path == "note.md" {
+			unknown = append(unknown, issue)
+		}
+	}
+	expected := []BibIssue{{Path: "note.md", Line: 3, Key: "missing", Message: "unknown citation key"}}
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, unknown)
+	}
+}
+
+func TestGetNoteBibliography(t *testing.T) {
+	rootDir := writeTestBibliographyVault(t)
+	defer os.RemoveAll(rootDir)
+
+	content, err := ReadFile(filepath.Join(rootDir, "note.md"))
+	if err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	bib, err := GetNoteBibliography(rootDir, content)
+	if err != nil {
+		t.Fatalf("GetNoteBibliography failed: %v", err)
+	}
+	var keys []string
+	for _, ref := range bib.References {
+		keys = append(keys, ref.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"Rudin1976", "knuth1984"}) || bib.References[1].Number != 2 {
+		t.Errorf("Unexpected references: %+v", bib.References)
+	}
+	if len(bib.Unknown) != 1 || bib.Unknown[0].Key != "missing" {
+		t.Errorf("Expected missing to be unknown, but got %+v", bib.Unknown)
+	}
+}
+
+func TestExportLaTeXCitations(t *testing.T) {
+	rootDir := writeTestBibliographyVault(t)
+	defer os.RemoveAll(rootDir)
+	outDir := filepath.Join(rootDir, "out")
+
+	result, err := ExportLaTeX(rootDir, LaTeXExportOptions{OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("ExportLaTeX failed: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "missing") {
+		t.Errorf("Expected a warning for the unknown key, but got %v", result.Warnings)
+	}
+
+	note, err := ReadFile(filepath.Join(outDir, "note.tex"))
+	if err != nil {
+		t.Fatalf("Failed to read note.tex: %v", err)
+	}
+	for _, want := range []string{`\cite[Theorem 1.1]{Rudin1976}`, `[@missing]`, `\cite{knuth1984}`} {
+		if !strings.Contains(note, want) {
+			t.Errorf("Expected note.tex to contain %q, but got:\n%s", want, note)
+		}
+	}
+
+	main, err := ReadFile(filepath.Join(outDir, latexMainFileName))
+	if err != nil {
+		t.Fatalf("Failed to read main.tex: %v", err)
+	}
+	want := "\\begin{thebibliography}{99}\n" +
+		"\\bibitem{Rudin1976} Walter Rudin. \\emph{Principles of Mathematical Analysis}. 3rd, American Mathematical Society Press, 1976.\n" +
+		"\\bibitem{knuth1984} Donald E. Knuth. Literate {P}rogramming. \\emph{The Computer Journal}, 27(2):97--111, 1984.\n" +
+		"\\end{thebibliography}\n"
+	if !strings.Contains(main, want) {
+		t.Errorf("Expected main.tex to contain %q, but got:\n%s", want, main)
+	}
+}
@@ -0,0 +1,307 @@
+package backend
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	CatalogGroupByFile = "file"
+	CatalogGroupByTag  = "tag"
+
+	CatalogFormatMarkdown = "markdown"
+	CatalogFormatCSV      = "csv"
+	CatalogFormatJSON     = "json"
+)
+
+var (
+	ErrUnknownCatalogFormat  = errors.New("unknown catalog format")
+	ErrUnknownCatalogGroupBy = errors.New("unknown catalog grouping")
+)
+
+// CatalogEntry は定理一覧の 1 つの定理です。Number はプロジェクト全体での通し番号で、
+// Link はこのアプリの [[...]] の形式で定理を指します
+type CatalogEntry struct {
+	Number     int      `json:"number"`
+	Name       string   `json:"name"`
+	Path       string   `json:"path"`
+	Line       int      `json:"line"`
+	Link       string   `json:"link"`
+	Tags       []string `json:"tags"`
+	Conditions string   `json:"conditions"`
+	Statement  string   `json:"statement"`
+}
+
+// CatalogGroup はファイルまたはタグごとにまとめた定理です
+type CatalogGroup struct {
+	Name    string         `json:"name"`
+	Entries []CatalogEntry `json:"entries"`
+}
+
+// CatalogOptions は定理一覧のまとめ方と形式、出力先を指定します
+type CatalogOptions struct {
+	GroupBy    string `json:"group_by"`
+	Format     string `json:"format"`
+	OutputPath string `json:"output_path"`
+}
+
+// BuildTheoremCatalog はインデックスにある定理を、ファイルのパス順・ファイル内の順に番号を付けて返します。
+// 主張と変数・条件は、定理ブロック内の、その環境のテンプレートと同じ見出しの下に書かれた Markdown です
+func BuildTheoremCatalog(rootDir string) ([]CatalogEntry, error) {
+	index, err := loadNoteIndex(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(index.Files))
+	for key, entry := range index.Files {
+		if len(entry.Theorems) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []CatalogEntry{}
+	for _, key := range keys {
+		content, err := ReadFile(filepath.Join(rootDir, filepath.FromSlash(key)))
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+		indexed := index.Files[key].Theorems
+		i := 0
+		parseMarkdown(content, theorems).Walk(func(node *Node) bool {
+			if node.Kind != NodeKindTheorem || node.Title == "" {
+				return true
+			}
+			tags := []string{}
+			if i < len(indexed) {
+				tags = indexed[i].Tags
+			}
+			i++
+			env, _ := theorems.environment(node.Environment)
+			conditions, statement := env.sectionHeadings()
+			if statement == "" {
+				// 見出しのないテンプレートの環境でも、既定のテンプレートで書いた定理からは取り出せるようにする
+				conditions, statement = getDefaultTheoremSettings().Environments[0].sectionHeadings()
+			}
+			entries = append(entries, CatalogEntry{
+				Number:     len(entries) + 1,
+				Name:       node.Title,
+				Path:       key,
+				Line:       node.StartLine,
+				Link:       "[[" + strings.TrimSuffix(key, path.Ext(key)) + "#" + node.Title + "]]",
+				Tags:       tags,
+				Conditions: theoremSection(node, lines, conditions, false),
+				Statement:  theoremSection(node, lines, statement, true),
+			})
+			return true
+		})
+	}
+	return entries, nil
+}
+
+// theoremSection は定理ブロック内の見出し heading から、次の同じ階層以上の見出しまでの本文を返します。
+// 見出しのない定理ブロックでは、証明の折りたたみを除いた本文全体を主張 (statement が true の場合) とします
+func theoremSection(theorem *Node, lines []string, heading string, statement bool) string {
+	hasHeading := slices.ContainsFunc(theorem.Children, func(child *Node) bool { return child.Kind == NodeKindHeading })
+	if !hasHeading {
+		if !statement {
+			return ""
+		}
+		body := slices.DeleteFunc(slices.Clone(theorem.Children), func(child *Node) bool { return child.Kind == NodeKindDetails })
+		return nodeSource(body, lines)
+	}
+
+	if heading == "" {
+		return ""
+	}
+	start := -1
+	level := 0
+	var body []*Node
+	for i, child := range theorem.Children {
+		if child.Kind == NodeKindHeading {
+			if start >= 0 && child.Level <= level {
+				break
+			}
+			if start < 0 && strings.TrimSpace(child.PlainText()) == heading {
+				start, level = i, child.Level
+				continue
+			}
+		}
+		if start >= 0 {
+			body = append(body, child)
+		}
+	}
+	return nodeSource(body, lines)
+}
+
+// nodeSource は連続するノードの元の Markdown を返します
+func nodeSource(nodes []*Node, lines []string) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	from, to := nodes[0].StartLine, nodes[len(nodes)-1].EndLine
+	if from < 1 || to > len(lines) || from > to {
+		return ""
+	}
+	return strings.TrimSpace(strings.Join(lines[from-1:to], "\n"))
+}
+
+// GroupTheoremCatalog は定理をファイルごと、またはタグごとにまとめます。
+// タグでまとめる場合、複数のタグを持つ定理はそれぞれのタグに現れ、タグのない定理は最後に untaggedLabel の名前でまとめます
+func GroupTheoremCatalog(entries []CatalogEntry, groupBy string, untaggedLabel string) ([]CatalogGroup, error) {
+	groups := []CatalogGroup{}
+	find := func(name string) *CatalogGroup {
+		for i := range groups {
+			if groups[i].Name == name {
+				return &groups[i]
+			}
+		}
+		groups = append(groups, CatalogGroup{Name: name, Entries: []CatalogEntry{}})
+		return &groups[len(groups)-1]
+	}
+
+	switch groupBy {
+	case CatalogGroupByFile, "":
+		for _, entry := range entries {
+			group := find(entry.Path)
+			group.Entries = append(group.Entries, entry)
+		}
+	case CatalogGroupByTag:
+		var untagged []CatalogEntry
+		for _, entry := range entries {
+			if len(entry.Tags) == 0 {
+				untagged = append(untagged, entry)
+			}
+			for _, tag := range entry.Tags {
+				group := find(tag)
+				group.Entries = append(group.Entries, entry)
+			}
+		}
+		slices.SortFunc(groups, func(a, b CatalogGroup) int { return strings.Compare(a.Name, b.Name) })
+		if len(untagged) > 0 {
+			groups = append(groups, CatalogGroup{Name: untaggedLabel, Entries: untagged})
+		}
+	default:
+		return nil, ErrUnknownCatalogGroupBy
+	}
+	return groups, nil
+}
+
+// FormatTheoremCatalog はまとめた定理一覧を Markdown、CSV、JSON のいずれかの文字列にします。
+// Markdown の題と、変数・条件と主張に付ける見出しは theorems の設定から決めます
+func FormatTheoremCatalog(groups []CatalogGroup, format string, theorems TheoremSettings) (string, error) {
+	switch format {
+	case CatalogFormatMarkdown, "":
+		return formatCatalogMarkdown(groups, theorems), nil
+	case CatalogFormatCSV:
+		return formatCatalogCSV(groups)
+	case CatalogFormatJSON:
+		data, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+	return "", ErrUnknownCatalogFormat
+}
+
+// formatCatalogMarkdown は定理一覧を Markdown にします。変数・条件と主張には先頭の環境のテンプレートの見出しを付け、
+// テンプレートに見出しがない場合は本文だけを書きます
+func formatCatalogMarkdown(groups []CatalogGroup, theorems TheoremSettings) string {
+	var conditionsHeading, statementHeading string
+	if len(theorems.Environments) > 0 {
+		conditionsHeading, statementHeading = theorems.Environments[0].sectionHeadings()
+	}
+	var b strings.Builder
+	b.WriteString("# " + theorems.CatalogTitle + "\n")
+	for _, group := range groups {
+		b.WriteString("\n## " + group.Name + "\n")
+		for _, entry := range group.Entries {
+			fmt.Fprintf(&b, "\n### %d. %s\n\n", entry.Number, entry.Name)
+			b.WriteString(entry.Link)
+			for _, tag := range entry.Tags {
+				b.WriteString(" #" + tag)
+			}
+			b.WriteString("\n")
+			for _, section := range [][2]string{{conditionsHeading, entry.Conditions}, {statementHeading, entry.Statement}} {
+				heading, body := section[0], section[1]
+				if body == "" {
+					continue
+				}
+				if heading != "" {
+					b.WriteString("\n**" + heading + "**\n")
+				}
+				b.WriteString("\n" + body + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+func formatCatalogCSV(groups []CatalogGroup) (string, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	records := [][]string{{"group", "number", "name", "path", "line", "link", "tags", "conditions", "statement"}}
+	for _, group := range groups {
+		for _, entry := range group.Entries {
+			records = append(records, []string{
+				group.Name,
+				fmt.Sprint(entry.Number),
+				entry.Name,
+				entry.Path,
+				fmt.Sprint(entry.Line),
+				entry.Link,
+				strings.Join(entry.Tags, " "),
+				entry.Conditions,
+				entry.Statement,
+			})
+		}
+	}
+	if err := w.WriteAll(records); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ExportTheoremCatalog は定理一覧を作り、opts.OutputPath に書き出します
+func ExportTheoremCatalog(rootDir string, opts CatalogOptions) (ExportResult, error) {
+	if opts.OutputPath == "" {
+		return ExportResult{}, ErrEmptyOutputPath
+	}
+	entries, err := BuildTheoremCatalog(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	groups, err := GroupTheoremCatalog(entries, opts.GroupBy, theorems.UntaggedLabel)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	content, err := FormatTheoremCatalog(groups, opts.Format, theorems)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0755); err != nil {
+		return ExportResult{}, err
+	}
+	if err := os.WriteFile(opts.OutputPath, []byte(content), 0644); err != nil {
+		return ExportResult{}, err
+	}
+	return ExportResult{Files: []string{opts.OutputPath}, Warnings: []string{}}, nil
+}
@@ -0,0 +1,133 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTheoremCatalog(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeTestVault(t, tmpDir, map[string]string{
+		"analysis/limit.md": "#analysis\n\n<theorem name=\"はさみうちの原理\">\n### 変数・条件\n\n- $a_n \\le b_n \\le c_n$\n- $a_n, c_n \\to L$\n\n### 主張\n\n$b_n \\to L$\n\n### 例\n\n省略\n</theorem>\n",
+		"algebra.md":        "<theorem name=\"ラグランジュの定理\">\n#exam\n### 主張\n\n$|H|$ は $|G|$ を割り切る\n</theorem>\n\n<theorem name=\"補題\">\n本文のみ\n</theorem>\n",
+	})
+	if err := RebuildIndex(tmpDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	entries, err := BuildTheoremCatalog(tmpDir)
+	if err != nil {
+		t.Fatalf("BuildTheoremCatalog failed: %v", err)
+	}
+	expected := []CatalogEntry{
+		{Number: 1, Name: "ラグランジュの定理", Path: "algebra.md", Line: 1, Link: "[[algebra#ラグランジュの定理]]", Tags: []string{"exam"}, Statement: "$|H|$ は $|G|$ を割り切る"},
+		{Number: 2, Name: "補題", Path: "algebra.md", Line: 8, Link: "[[algebra#補題]]", Tags: []string{}, Statement: "本文のみ"},
+		{Number: 3, Name: "はさみうちの原理", Path: "analysis/limit.md", Line: 3, Link: "[[analysis/limit#はさみうちの原理]]", Tags: []string{"analysis"},
+			Conditions: "- $a_n \\le b_n \\le c_n$\n- $a_n, c_n \\to L$", Statement: "$b_n \\to L$"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Unexpected catalog.\nGot:  %+v\nWant: %+v", entries, expected)
+	}
+
+	groups, err := GroupTheoremCatalog(entries, CatalogGroupByTag, "タグなし")
+	if err != nil {
+		t.Fatalf("GroupTheoremCatalog failed: %v", err)
+	}
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"analysis", "exam", "タグなし"}) {
+		t.Errorf("Unexpected tag groups: %v", names)
+	}
+
+	byFile, _ := GroupTheoremCatalog(entries, CatalogGroupByFile, "タグなし")
+	markdown, err := FormatTheoremCatalog(byFile, CatalogFormatMarkdown, getDefaultTheoremSettings())
+	if err != nil {
+		t.Fatalf("FormatTheoremCatalog failed: %v", err)
+	}
+	wantMarkdown := "# 定理一覧\n\n## algebra.md\n\n### 1. ラグランジュの定理\n\n[[algebra#ラグランジュの定理]] #exam\n\n**主張**\n\n$|H|$ は $|G|$ を割り切る\n"
+	if !strings.HasPrefix(markdown, wantMarkdown) {
+		t.Errorf("Unexpected markdown catalog:\n%s", markdown)
+	}
+
+	outPath := filepath.Join(tmpDir, "out", "catalog.csv")
+	if _, err := ExportTheoremCatalog(tmpDir, CatalogOptions{GroupBy: CatalogGroupByFile, Format: CatalogFormatCSV, OutputPath: outPath}); err != nil {
+		t.Fatalf("ExportTheoremCatalog failed: %v", err)
+	}
+	csvContent, err := ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read catalog: %v", err)
+	}
+	wantCSV := "group,number,name,path,line,link,tags,conditions,statement\n" +
+		"algebra.md,1,ラグランジュの定理,algebra.md,1,[[algebra#ラグランジュの定理]],exam,,$|H|$ は $|G|$ を割り切る\n"
+	if !strings.HasPrefix(csvContent, wantCSV) {
+		t.Errorf("Unexpected CSV catalog:\n%s", csvContent)
+	}
+
+	if _, err := FormatTheoremCatalog(byFile, "pdf", getDefaultTheoremSettings()); err != ErrUnknownCatalogFormat {
+		t.Errorf("Expected ErrUnknownCatalogFormat, but got %v", err)
+	}
+}
+
+func TestTheoremCatalog_EnvironmentTemplates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"theorem_settings": map[string]any{"environments": []any{
+		map[string]any{"tag": "theorem", "label": "Theorem", "counter": "thm", "template": "### Assumptions\n\n### Statement\n"},
+		map[string]any{"tag": "lemma", "label": "Lemma", "counter": "thm", "template": "### Statement\n"},
+		map[string]any{"tag": "remark", "label": "Remark", "counter": "", "template": ""},
+	}, "catalog_title": "Theorems", "untagged_label": "Untagged"}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	writeTestVault(t, tmpDir, map[string]string{
+		"notes.md": "<theorem name=\"A\">\n### Assumptions\n\n$x > 0$\n\n### Statement\n\n$x^2 > 0$\n</theorem>\n\n" +
+			"<lemma name=\"B\">\n### Statement\n\n$y = y$\n\n### Note\n\nobvious\n</lemma>\n\n" +
+			"<remark name=\"C\">\n### 変数・条件\n\n$z$\n\n### 主張\n\n$z = z$\n</remark>\n",
+	})
+	if err := RebuildIndex(tmpDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	entries, err := BuildTheoremCatalog(tmpDir)
+	if err != nil {
+		t.Fatalf("BuildTheoremCatalog failed: %v", err)
+	}
+	var got [][2]string
+	for _, entry := range entries {
+		got = append(got, [2]string{entry.Conditions, entry.Statement})
+	}
+	expected := [][2]string{{"$x > 0$", "$x^2 > 0$"}, {"", "$y = y$"}, {"$z$", "$z = z$"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+
+	// 題とタグのないまとまりの名前は設定から、本文の見出しは環境のテンプレートから決まる
+	outPath := filepath.Join(tmpDir, "out", "catalog.md")
+	if _, err := ExportTheoremCatalog(tmpDir, CatalogOptions{GroupBy: CatalogGroupByTag, Format: CatalogFormatMarkdown, OutputPath: outPath}); err != nil {
+		t.Fatalf("ExportTheoremCatalog failed: %v", err)
+	}
+	markdown, err := ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read catalog: %v", err)
+	}
+	wantMarkdown := "# Theorems\n\n## Untagged\n\n" +
+		"### 1. A\n\n[[notes#A]]\n\n**Assumptions**\n\n$x > 0$\n\n**Statement**\n\n$x^2 > 0$\n\n" +
+		"### 2. B\n\n[[notes#B]]\n\n**Statement**\n\n$y = y$\n\n" +
+		"### 3. C\n\n[[notes#C]]\n\n**Assumptions**\n\n$z$\n\n**Statement**\n\n$z = z$\n"
+	if markdown != wantMarkdown {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", wantMarkdown, markdown)
+	}
+}
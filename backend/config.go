@@ -2,8 +2,15 @@ package backend
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 // =================================================================
@@ -13,67 +20,264 @@ import (
 const (
 	globalConfigDirName  = "theorem-note-wails"
 	globalConfigFileName = "global_config.json"
+	// recentProjectsLimit はピン留めしていない最近のプロジェクトを残す数です
+	recentProjectsLimit = 20
 )
 
-// GlobalConfig はアプリケーション全体のグローバル設定を保持します
+var (
+	ErrRecentProjectNotFound = errors.New("project is not in the recent list")
+	ErrCorruptGlobalConfig   = errors.New("global config file is corrupt")
+)
+
+// RecentProject は最近開いたプロジェクトです。Name は表示名で、空の場合はディレクトリ名を表示します
+type RecentProject struct {
+	Path       string    `json:"path"`
+	Name       string    `json:"name"`
+	LastOpened time.Time `json:"last_opened"`
+	Pinned     bool      `json:"pinned"`
+}
+
+// GlobalConfig はアプリケーション全体のグローバル設定を保持します。
+// RecentProjects は新しく開いた順に並び、LastOpenedPath はその先頭と同じです
 type GlobalConfig struct {
-	LastOpenedPath string `json:"last_opened_path"`
+	LastOpenedPath string          `json:"last_opened_path"`
+	RecentProjects []RecentProject `json:"recent_projects"`
+	// Settings はすべてのプロジェクトに共通する設定で、プロジェクトの config.json と同じ形の一部のキーだけを持ちます
+	Settings map[string]any `json:"settings,omitempty"`
 }
 
-// ConfigManager はグローバル設定を管理します
+// ConfigManager はグローバル設定を管理します。Wails はバインドしたメソッドを並行に呼ぶので、すべての操作は mu で直列化します。
+// path が空の場合は設定ファイルを使えない環境で、設定はメモリ上にだけ保持します
 type ConfigManager struct {
+	mu     sync.Mutex
 	config GlobalConfig
 	path   string
 }
 
-// NewConfigManager は新しいConfigManagerを初期化します
-func NewConfigManager() *ConfigManager {
+// NewConfigManager はユーザーの設定ディレクトリにあるグローバル設定を読み込みます。
+// 設定ディレクトリや設定ファイルを使えない場合もメモリ上の設定で動く ConfigManager を返し、その原因をエラーとして返します
+func NewConfigManager() (*ConfigManager, error) {
+	path, err := getGlobalConfigPath()
+	if err != nil {
+		return &ConfigManager{}, err
+	}
+	return newConfigManager(path)
+}
+
+func getGlobalConfigPath() (string, error) {
 	appDataDir, err := os.UserConfigDir()
 	if err != nil {
-		panic("Failed to get user config directory: " + err.Error())
+		return "", err
+	}
+	return filepath.Join(appDataDir, globalConfigDirName, globalConfigFileName), nil
+}
+
+// loadGlobalSettingsLayer はグローバル設定ファイルから全プロジェクトの既定値を読み込みます。
+// ファイルがない場合や読めない場合は既定値だけを使うように nil を返し、壊れたファイルの扱いは ConfigManager に任せます
+func loadGlobalSettingsLayer() map[string]any {
+	path, err := getGlobalConfigPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
 	}
+	var config GlobalConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil
+	}
+	return config.Settings
+}
 
-	path := filepath.Join(appDataDir, globalConfigDirName, globalConfigFileName)
+func newConfigManager(path string) (*ConfigManager, error) {
 	cm := &ConfigManager{path: path}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		cm.config = GlobalConfig{LastOpenedPath: ""}
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			panic("Failed to create global config directory: " + err.Error())
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		if err := cm.save(); err != nil {
+			cm.path = ""
+			return cm, err
 		}
-		cm.save()
-	} else {
-		file, err := os.ReadFile(path)
-		if err != nil {
-			panic("Failed to read global config file: " + err.Error())
+		return cm, nil
+	case err != nil:
+		// 読めない設定ファイルを上書きしないように、メモリ上の設定だけで動かす
+		cm.path = ""
+		return cm, err
+	}
+
+	if err := json.Unmarshal(data, &cm.config); err != nil {
+		// 壊れた設定ファイルは調べられるように残してから、既定の設定で作り直す
+		cm.config = GlobalConfig{}
+		backup := path + ".corrupt-" + time.Now().Format("20060102-150405")
+		if renameErr := os.Rename(path, backup); renameErr != nil {
+			cm.path = ""
+			return cm, renameErr
 		}
-		if json.Unmarshal(file, &cm.config) != nil {
-			// ファイルが壊れている場合などはデフォルトで初期化
-			cm.config = GlobalConfig{LastOpenedPath: ""}
+		if saveErr := cm.save(); saveErr != nil {
+			return cm, saveErr
 		}
+		return cm, fmt.Errorf("%w: backed up to %s: %v", ErrCorruptGlobalConfig, backup, err)
+	}
+
+	// 最近のプロジェクトの一覧がなかった頃の設定では、最後に開いたプロジェクトだけを一覧に入れる
+	if cm.config.RecentProjects == nil && cm.config.LastOpenedPath != "" {
+		cm.config.RecentProjects = []RecentProject{{Path: cm.config.LastOpenedPath, LastOpened: time.Now()}}
 	}
-	return cm
+	return cm, nil
 }
 
-func (cm *ConfigManager) save() {
+// save は設定をファイルに書き込みます。呼び出し側で mu を保持している必要があります。
+// 書き込みに失敗しても、メモリ上の設定は変更後のまま残ります
+func (cm *ConfigManager) save() error {
+	if cm.path == "" {
+		return nil
+	}
 	b, err := json.MarshalIndent(cm.config, "", "  ")
 	if err != nil {
-		panic("Failed to marshal global config: " + err.Error())
+		return err
 	}
-	if err := os.WriteFile(cm.path, b, 0644); err != nil {
-		panic("Failed to write global config: " + err.Error())
+	if err := os.MkdirAll(filepath.Dir(cm.path), 0755); err != nil {
+		return err
 	}
+	return writeFileAtomic(cm.path, b, 0644)
 }
 
 // GetLastOpened は最後に開いたディレクトリのパスを返します
 func (cm *ConfigManager) GetLastOpened() string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	return cm.config.LastOpenedPath
 }
 
-// SetLastOpened は最後に開いたディレクトリのパスを設定します
-func (cm *ConfigManager) SetLastOpened(path string) {
+// SetLastOpened は最後に開いたディレクトリのパスを設定し、最近のプロジェクトの先頭に移動します。
+// 表示名とピン留めは引き継ぎます
+func (cm *ConfigManager) SetLastOpened(path string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
 	cm.config.LastOpenedPath = path
-	cm.save()
+	if path != "" {
+		project := RecentProject{Path: path}
+		if i := cm.findRecentProject(path); i >= 0 {
+			project = cm.config.RecentProjects[i]
+			cm.config.RecentProjects = slices.Delete(cm.config.RecentProjects, i, i+1)
+		}
+		project.LastOpened = time.Now()
+		cm.config.RecentProjects = slices.Insert(cm.config.RecentProjects, 0, project)
+		cm.trimRecentProjects()
+	}
+	return cm.save()
+}
+
+func (cm *ConfigManager) findRecentProject(path string) int {
+	return slices.IndexFunc(cm.config.RecentProjects, func(p RecentProject) bool { return p.Path == path })
+}
+
+// trimRecentProjects はピン留めしていないプロジェクトを、新しいものから recentProjectsLimit 個だけ残します
+func (cm *ConfigManager) trimRecentProjects() {
+	unpinned := 0
+	cm.config.RecentProjects = slices.DeleteFunc(cm.config.RecentProjects, func(p RecentProject) bool {
+		if p.Pinned {
+			return false
+		}
+		unpinned++
+		return unpinned > recentProjectsLimit
+	})
+}
+
+// GetRecentProjects は最近開いたプロジェクトを、ピン留めしたもの、新しく開いたものの順に返します。
+// 削除や移動で存在しなくなったディレクトリは一覧から取り除きます
+func (cm *ConfigManager) GetRecentProjects() []RecentProject {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	before := len(cm.config.RecentProjects)
+	cm.config.RecentProjects = slices.DeleteFunc(cm.config.RecentProjects, func(p RecentProject) bool {
+		info, err := os.Stat(p.Path)
+		return err != nil || !info.IsDir()
+	})
+	if len(cm.config.RecentProjects) != before {
+		// 取り除いた結果を保存できなくても、次に一覧を取得したときにまた取り除かれるだけなので無視する
+		_ = cm.save()
+	}
+
+	projects := slices.Clone(cm.config.RecentProjects)
+	for i := range projects {
+		if projects[i].Name == "" {
+			projects[i].Name = filepath.Base(projects[i].Path)
+		}
+	}
+	slices.SortStableFunc(projects, func(a, b RecentProject) int {
+		switch {
+		case a.Pinned && !b.Pinned:
+			return -1
+		case !a.Pinned && b.Pinned:
+			return 1
+		}
+		return 0
+	})
+	return projects
+}
+
+// PinRecentProject は最近のプロジェクトをピン留めします。ピン留めしたプロジェクトは件数の上限を超えても残ります
+func (cm *ConfigManager) PinRecentProject(path string, pinned bool) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	i := cm.findRecentProject(path)
+	if i < 0 {
+		return ErrRecentProjectNotFound
+	}
+	cm.config.RecentProjects[i].Pinned = pinned
+	cm.trimRecentProjects()
+	return cm.save()
+}
+
+// RenameRecentProject は最近のプロジェクトの表示名を設定します。空文字列の場合はディレクトリ名の表示に戻します
+func (cm *ConfigManager) RenameRecentProject(path string, name string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	i := cm.findRecentProject(path)
+	if i < 0 {
+		return ErrRecentProjectNotFound
+	}
+	cm.config.RecentProjects[i].Name = strings.TrimSpace(name)
+	return cm.save()
+}
+
+// RemoveRecentProject は最近のプロジェクトの一覧から取り除きます。ディレクトリは削除しません
+func (cm *ConfigManager) RemoveRecentProject(path string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	i := cm.findRecentProject(path)
+	if i < 0 {
+		return ErrRecentProjectNotFound
+	}
+	cm.config.RecentProjects = slices.Delete(cm.config.RecentProjects, i, i+1)
+	return cm.save()
+}
+
+// GetDefaultSettings はユーザーが全プロジェクトの既定値として設定した値を返します
+func (cm *ConfigManager) GetDefaultSettings() map[string]any {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cloneSettings(cm.config.Settings)
+}
+
+// PatchDefaultSettings は全プロジェクトの既定値を変更します。patch の形は PatchProjectConfig と同じです
+func (cm *ConfigManager) PatchDefaultSettings(patch map[string]any) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	settings, err := patchSettingsLayer(cm.config.Settings, patch)
+	if err != nil {
+		return err
+	}
+	cm.config.Settings = settings
+	return cm.save()
 }
 
 // =================================================================
@@ -95,7 +299,9 @@ type FontSettings struct {
 
 // ProjectConfig はプロジェクトごとの設定を保持します
 type ProjectConfig struct {
-	FontSettings FontSettings `json:"font_settings"`
+	FontSettings    FontSettings    `json:"font_settings"`
+	EditorSettings  EditorSettings  `json:"editor_settings"`
+	TheoremSettings TheoremSettings `json:"theorem_settings"`
 }
 
 func getProjectConfigPath(rootDir string) (string, error) {
@@ -113,57 +319,48 @@ func getDefaultProjectConfig() ProjectConfig {
 			PreviewFontFamily: "-apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Helvetica, Arial, sans-serif",
 			PreviewFontSize:   14,
 		},
+		EditorSettings:  getDefaultEditorSettings(),
+		TheoremSettings: getDefaultTheoremSettings(),
 	}
 }
 
-// LoadProjectConfig はプロジェクトの設定を読み込みます
+// LoadProjectConfig はグローバル設定にプロジェクトの config.json を重ねた設定を読み込みます。
+// どちらにもない設定は既定値になります
 func LoadProjectConfig(rootDir string) (ProjectConfig, error) {
 	defaultConfig := getDefaultProjectConfig()
 	if rootDir == "" {
 		return defaultConfig, nil
 	}
 
-	path, err := getProjectConfigPath(rootDir)
-	if err != nil {
-		return defaultConfig, err
-	}
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return defaultConfig, nil
-	}
-
-	data, err := os.ReadFile(path)
+	effective, err := ResolveSettings(rootDir, loadGlobalSettingsLayer(), "")
 	if err != nil {
 		return defaultConfig, err
 	}
-
-	var config ProjectConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return defaultConfig, err
-	}
-	return config, nil
+	return effective.Config, nil
 }
 
-// SaveProjectConfig はプロジェクトの設定を保存します
+// SaveProjectConfig はプロジェクトの設定を保存します。"macros" など ProjectConfig にないキーはそのまま残します。
+// 読み直せない設定を書かないように、範囲外の値を含む場合は何も保存せずに ErrInvalidSettings を返します
 func SaveProjectConfig(rootDir string, config ProjectConfig) error {
 	if rootDir == "" {
 		return os.ErrInvalid
 	}
-
-	// ディレクトリが存在することを確認
-	if err := os.MkdirAll(filepath.Join(rootDir, projectConfigDir), 0755); err != nil {
+	if err := ValidateProjectConfig(config); err != nil {
 		return err
 	}
 
-	path, err := getProjectConfigPath(rootDir)
+	layer, err := loadProjectSettingsLayer(rootDir)
 	if err != nil {
 		return err
 	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
-
-	return os.WriteFile(path, data, 0644)
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	maps.Copy(layer, values)
+	return saveProjectSettingsLayer(rootDir, layer)
 }
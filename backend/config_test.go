@@ -1,12 +1,41 @@
 package backend
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
+// TestMain は開発者のグローバル設定がテストの結果を変えないように、ユーザーの設定ディレクトリを一時ディレクトリにします
+func TestMain(m *testing.M) {
+	code, err := runWithUserConfigDir(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up the user config dir: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func runWithUserConfigDir(m *testing.M) (int, error) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+	// os.UserConfigDir は Linux では XDG_CONFIG_HOME、macOS では HOME、Windows では AppData を使う
+	for _, key := range []string{"XDG_CONFIG_HOME", "HOME", "AppData"} {
+		if err := os.Setenv(key, tmpDir); err != nil {
+			return 0, err
+		}
+	}
+	return m.Run(), nil
+}
+
 func TestLoadAndSaveProjectConfig(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "testdir")
@@ -25,13 +54,12 @@ func TestLoadAndSaveProjectConfig(t *testing.T) {
 	}
 
 	// Define the config data to be saved
-	expectedConfig := ProjectConfig{
-		FontSettings: FontSettings{
-			EditorFontFamily:  "test-font",
-			EditorFontSize:    20,
-			PreviewFontFamily: "test-preview-font",
-			PreviewFontSize:   22,
-		},
+	expectedConfig := getDefaultProjectConfig()
+	expectedConfig.FontSettings = FontSettings{
+		EditorFontFamily:  "test-font",
+		EditorFontSize:    20,
+		PreviewFontFamily: "test-preview-font",
+		PreviewFontSize:   22,
 	}
 
 	// Test saving the config
@@ -76,3 +104,257 @@ func TestGetProjectConfigPath(t *testing.T) {
 		t.Errorf("Expected os.ErrInvalid for empty rootDir, but got %v", err)
 	}
 }
+
+func TestRecentProjects(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configPath := filepath.Join(tmpDir, "config", globalConfigFileName)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	vaults := make(map[string]string)
+	for _, name := range []string{"lecture", "paper", "exam"} {
+		vaults[name] = filepath.Join(tmpDir, name)
+		if err := os.Mkdir(vaults[name], 0755); err != nil {
+			t.Fatalf("Failed to create vault: %v", err)
+		}
+	}
+
+	// 一覧がなかった頃の設定ファイルでも、最後に開いたプロジェクトが一覧に入る
+	if err := os.WriteFile(configPath, []byte(`{"last_opened_path": "`+vaults["lecture"]+`"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cm, err := newConfigManager(configPath)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	if projects := cm.GetRecentProjects(); len(projects) != 1 || projects[0].Path != vaults["lecture"] || projects[0].Name != "lecture" {
+		t.Fatalf("Expected the migrated last opened project, but got %+v", projects)
+	}
+
+	for _, name := range []string{"paper", "exam"} {
+		if err := cm.SetLastOpened(vaults[name]); err != nil {
+			t.Fatalf("SetLastOpened failed: %v", err)
+		}
+	}
+	if err := cm.PinRecentProject(vaults["lecture"], true); err != nil {
+		t.Fatalf("PinRecentProject failed: %v", err)
+	}
+	if err := cm.RenameRecentProject(vaults["paper"], " 論文 "); err != nil {
+		t.Fatalf("RenameRecentProject failed: %v", err)
+	}
+	if err := cm.RenameRecentProject(filepath.Join(tmpDir, "none"), "x"); err != ErrRecentProjectNotFound {
+		t.Errorf("Expected ErrRecentProjectNotFound, but got %v", err)
+	}
+
+	// 設定ファイルから読み直しても同じ一覧になり、存在しないディレクトリは取り除かれる
+	if err := os.Remove(vaults["exam"]); err != nil {
+		t.Fatalf("Failed to remove vault: %v", err)
+	}
+	cm, err = newConfigManager(configPath)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	var got []string
+	for _, project := range cm.GetRecentProjects() {
+		got = append(got, project.Name)
+	}
+	if !reflect.DeepEqual(got, []string{"lecture", "論文"}) {
+		t.Errorf("Expected [lecture 論文], but got %v", got)
+	}
+	if cm.GetLastOpened() != vaults["exam"] {
+		t.Errorf("Expected the last opened path to be kept, but got %s", cm.GetLastOpened())
+	}
+
+	if err := cm.RemoveRecentProject(vaults["lecture"]); err != nil {
+		t.Fatalf("RemoveRecentProject failed: %v", err)
+	}
+	if projects := cm.GetRecentProjects(); len(projects) != 1 || projects[0].Path != vaults["paper"] {
+		t.Errorf("Unexpected projects after removal: %+v", projects)
+	}
+}
+
+func TestRecentProjectsLimit(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cm, err := newConfigManager(filepath.Join(tmpDir, globalConfigFileName))
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	pinned := filepath.Join(tmpDir, "pinned")
+	if err := cm.SetLastOpened(pinned); err != nil {
+		t.Fatalf("SetLastOpened failed: %v", err)
+	}
+	if err := cm.PinRecentProject(pinned, true); err != nil {
+		t.Fatalf("PinRecentProject failed: %v", err)
+	}
+	for i := 0; i < recentProjectsLimit+5; i++ {
+		if err := cm.SetLastOpened(filepath.Join(tmpDir, "vault", string(rune('a'+i)))); err != nil {
+			t.Fatalf("SetLastOpened failed: %v", err)
+		}
+	}
+	if len(cm.config.RecentProjects) != recentProjectsLimit+1 || cm.findRecentProject(pinned) < 0 {
+		t.Errorf("Expected %d unpinned projects and the pinned one, but got %d", recentProjectsLimit, len(cm.config.RecentProjects))
+	}
+}
+
+func TestConfigManager_CorruptFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configPath := filepath.Join(tmpDir, globalConfigFileName)
+	if err := os.WriteFile(configPath, []byte(`{"last_opened_path": `), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cm, err := newConfigManager(configPath)
+	if !errors.Is(err, ErrCorruptGlobalConfig) {
+		t.Fatalf("Expected ErrCorruptGlobalConfig, but got %v", err)
+	}
+	if cm.GetLastOpened() != "" {
+		t.Errorf("Expected the default config, but got %q", cm.GetLastOpened())
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), globalConfigFileName+".corrupt-") {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup of the corrupt file, but got %v", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, backups[0])); string(data) != `{"last_opened_path": ` {
+		t.Errorf("Expected the backup to keep the corrupt content, but got %q", data)
+	}
+	if _, err := newConfigManager(configPath); err != nil {
+		t.Errorf("Expected the recreated config to load, but got %v", err)
+	}
+}
+
+func TestConfigManager_InMemoryFallback(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 設定ディレクトリを作れない場所 (通常のファイルの下) を指定する
+	blocker := filepath.Join(tmpDir, "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cm, err := newConfigManager(filepath.Join(blocker, "config", globalConfigFileName))
+	if err == nil {
+		t.Fatalf("Expected an error for an unusable config directory")
+	}
+	if err := cm.SetLastOpened(tmpDir); err != nil {
+		t.Errorf("Expected SetLastOpened to work in memory, but got %v", err)
+	}
+	if cm.GetLastOpened() != tmpDir {
+		t.Errorf("Expected the config to be kept in memory, but got %q", cm.GetLastOpened())
+	}
+
+	var empty ConfigManager
+	if err := empty.SetLastOpened(tmpDir); err != nil || empty.GetLastOpened() != tmpDir {
+		t.Errorf("Expected a manager without a path to work in memory, but got %v", err)
+	}
+}
+
+func TestLoadProjectConfig_GlobalSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path, err := getGlobalConfigPath()
+	if err != nil {
+		t.Fatalf("getGlobalConfigPath failed: %v", err)
+	}
+	defer os.Remove(path)
+	cm, err := newConfigManager(path)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	err = cm.PatchDefaultSettings(map[string]any{
+		"editor_settings":  map[string]any{"tab_size": 2, "theme": ThemeDark},
+		"theorem_settings": map[string]any{"proof_label": "Proof"},
+	})
+	if err != nil {
+		t.Fatalf("PatchDefaultSettings failed: %v", err)
+	}
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"editor_settings": map[string]any{"tab_size": 8}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+
+	// アプリを経由しない読み込みでも、グローバル設定の上にプロジェクトの設定が重なる
+	config, err := LoadProjectConfig(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if config.EditorSettings.TabSize != 8 || config.EditorSettings.Theme != ThemeDark {
+		t.Errorf("Expected the project tab size over the global theme, but got %+v", config.EditorSettings)
+	}
+	theorems, err := loadTheoremSettings(tmpDir)
+	if err != nil {
+		t.Fatalf("loadTheoremSettings failed: %v", err)
+	}
+	if theorems.ProofLabel != "Proof" {
+		t.Errorf("Expected the global proof label, but got %q", theorems.ProofLabel)
+	}
+}
+
+func TestConfigManager_Concurrent(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configPath := filepath.Join(tmpDir, globalConfigFileName)
+	cm, err := newConfigManager(configPath)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cm.SetLastOpened(filepath.Join(tmpDir, fmt.Sprint(i))); err != nil {
+				t.Errorf("SetLastOpened failed: %v", err)
+			}
+			cm.GetRecentProjects()
+		}(i)
+	}
+	wg.Wait()
+
+	// 最後に書き込まれたファイルは壊れておらず、一時ファイルも残らない
+	reloaded, err := newConfigManager(configPath)
+	if err != nil {
+		t.Fatalf("Expected a valid config file, but got %v", err)
+	}
+	if reloaded.GetLastOpened() == "" {
+		t.Errorf("Expected the last opened path to be saved")
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the config file, but got %v", entries)
+	}
+}
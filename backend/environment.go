@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// theoremTagRegexp は定理環境のタグ名です。プレビューの HTML パーサーがタグ名を小文字にするので、小文字だけを許します
+var theoremTagRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// TheoremEnvironment は <theorem name="..."> のようにタグで囲んで書く定理環境です。
+// Label は「定理」「Theorem」のような表示名で、Counter が同じ環境は通し番号を共有します。Counter が空の環境には番号を付けません。
+// Template は新しいノートを作るときの環境の中身です
+type TheoremEnvironment struct {
+	Tag      string `json:"tag"`
+	Label    string `json:"label"`
+	Counter  string `json:"counter"`
+	Template string `json:"template"`
+}
+
+// TheoremSettings はノートで使う定理環境の設定です。新しいノートには Environments の先頭の環境を使います。
+// ProofLabel は新しいノートの証明の <summary> と、LaTeX の証明の見出しです。
+// CatalogTitle は定理一覧の題で、UntaggedLabel は定理一覧をタグでまとめたときのタグのない定理のまとまりの名前です
+type TheoremSettings struct {
+	Environments  []TheoremEnvironment `json:"environments"`
+	ProofLabel    string               `json:"proof_label"`
+	CatalogTitle  string               `json:"catalog_title"`
+	UntaggedLabel string               `json:"untagged_label"`
+}
+
+func getDefaultTheoremSettings() TheoremSettings {
+	return TheoremSettings{
+		Environments: []TheoremEnvironment{
+			{Tag: "theorem", Label: "定理", Counter: "theorem", Template: "### 変数・条件\n\n\n### 主張\n"},
+		},
+		ProofLabel:    "証明",
+		CatalogTitle:  "定理一覧",
+		UntaggedLabel: "タグなし",
+	}
+}
+
+// loadTheoremSettings はプロジェクトの定理環境の設定を読み込みます。rootDir が空の場合は既定値を返します
+func loadTheoremSettings(rootDir string) (TheoremSettings, error) {
+	config, err := LoadProjectConfig(rootDir)
+	if err != nil {
+		return TheoremSettings{}, err
+	}
+	return config.TheoremSettings, nil
+}
+
+func validateTheoremSettings(settings TheoremSettings) error {
+	if len(settings.Environments) == 0 {
+		return invalidSetting("theorem_settings.environments", "must have at least one environment")
+	}
+	seen := make(map[string]bool, len(settings.Environments))
+	for i, env := range settings.Environments {
+		key := "theorem_settings.environments." + strconv.Itoa(i)
+		switch {
+		case !theoremTagRegexp.MatchString(env.Tag):
+			return invalidSetting(key+".tag", "must start with a lowercase letter and contain only a-z, 0-9 and -")
+		case htmlBlockTags[env.Tag]:
+			return invalidSetting(key+".tag", "%q is an HTML tag", env.Tag)
+		case seen[env.Tag]:
+			return invalidSetting(key+".tag", "%q is defined more than once", env.Tag)
+		case strings.TrimSpace(env.Label) == "":
+			return invalidSetting(key+".label", "must not be empty")
+		}
+		seen[env.Tag] = true
+	}
+	if strings.TrimSpace(settings.ProofLabel) == "" {
+		return invalidSetting("theorem_settings.proof_label", "must not be empty")
+	}
+	if strings.TrimSpace(settings.CatalogTitle) == "" {
+		return invalidSetting("theorem_settings.catalog_title", "must not be empty")
+	}
+	if strings.TrimSpace(settings.UntaggedLabel) == "" {
+		return invalidSetting("theorem_settings.untagged_label", "must not be empty")
+	}
+	return nil
+}
+
+// environment はタグ名 tag の定理環境を返します
+func (s TheoremSettings) environment(tag string) (TheoremEnvironment, bool) {
+	for _, env := range s.Environments {
+		if env.Tag == tag {
+			return env, true
+		}
+	}
+	return TheoremEnvironment{}, false
+}
+
+// openRegexp は行頭の <tag name="..."> に一致し、タグ名と定理名を取り出す正規表現を返します
+func (s TheoremSettings) openRegexp() *regexp.Regexp {
+	return regexp.MustCompile(`^<(` + s.tagPattern() + `) name="([^"]*)">`)
+}
+
+// tagPattern はいずれかの環境のタグ名に一致する正規表現です
+func (s TheoremSettings) tagPattern() string {
+	tags := make([]string, len(s.Environments))
+	for i, env := range s.Environments {
+		tags[i] = regexp.QuoteMeta(env.Tag)
+	}
+	return strings.Join(tags, "|")
+}
+
+// noteTemplate は新しいノートの内容です。先頭の環境のテンプレートと、空の証明を並べます
+func (s TheoremSettings) noteTemplate() string {
+	env := s.Environments[0]
+	body := strings.TrimRight(env.Template, "\n")
+	if body != "" {
+		body += "\n\n"
+	}
+	return `<` + env.Tag + ` name="">` + "\n" + body + `</` + env.Tag + ">\n\n" +
+		"<details>\n<summary>" + s.ProofLabel + "</summary>\n\n</details>"
+}
+
+// sectionHeadings はテンプレートの見出しから、定理一覧で変数・条件と主張として取り出す見出しを返します。
+// 最後の見出しを主張、2 つ以上ある場合は最初の見出しを変数・条件とします。テンプレートに見出しがない場合はどちらも空です
+func (env TheoremEnvironment) sectionHeadings() (conditions string, statement string) {
+	var headings []string
+	for _, line := range strings.Split(env.Template, "\n") {
+		if _, text, ok := parseATXHeading(strings.TrimSpace(line)); ok && text != "" {
+			headings = append(headings, text)
+		}
+	}
+	switch len(headings) {
+	case 0:
+		return "", ""
+	case 1:
+		return "", headings[0]
+	}
+	return headings[0], headings[len(headings)-1]
+}
+
+// theoremNumbering は文書の中の定理に、環境の Counter ごとの通し番号を付けます
+type theoremNumbering struct {
+	settings TheoremSettings
+	counters map[string]int
+}
+
+func newTheoremNumbering(settings TheoremSettings) *theoremNumbering {
+	return &theoremNumbering{settings: settings, counters: make(map[string]int)}
+}
+
+// next は定理 node の番号を進め、「定理 1」のような番号付きの表示名を返します。番号を付けない環境では表示名だけを返します
+func (n *theoremNumbering) next(node *Node) string {
+	env, ok := n.settings.environment(node.Environment)
+	if !ok {
+		env = TheoremEnvironment{Label: node.Environment}
+	}
+	if env.Counter == "" {
+		return env.Label
+	}
+	n.counters[env.Counter]++
+	return env.Label + " " + strconv.Itoa(n.counters[env.Counter])
+}
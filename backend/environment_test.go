@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bilingualEnvironments は定理と補題が番号を共有し、注意には番号を付けない設定です
+var bilingualEnvironments = []any{
+	map[string]any{"tag": "theorem", "label": "Theorem", "counter": "thm", "template": "### Statement\n"},
+	map[string]any{"tag": "lemma", "label": "補題", "counter": "thm", "template": ""},
+	map[string]any{"tag": "remark", "label": "注意", "counter": "", "template": ""},
+}
+
+func TestPatchProjectConfig_TheoremSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	config, err := PatchProjectConfig(tmpDir, map[string]any{
+		"theorem_settings": map[string]any{"environments": bilingualEnvironments, "proof_label": "Proof"},
+	})
+	if err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	expected := TheoremSettings{
+		Environments: []TheoremEnvironment{
+			{Tag: "theorem", Label: "Theorem", Counter: "thm", Template: "### Statement\n"},
+			{Tag: "lemma", Label: "補題", Counter: "thm"},
+			{Tag: "remark", Label: "注意"},
+		},
+		ProofLabel:    "Proof",
+		CatalogTitle:  "定理一覧",
+		UntaggedLabel: "タグなし",
+	}
+	if !reflect.DeepEqual(config.TheoremSettings, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, config.TheoremSettings)
+	}
+
+	invalid := []struct {
+		name         string
+		environments []any
+	}{
+		{"no environments", []any{}},
+		{"uppercase tag", []any{map[string]any{"tag": "Lemma", "label": "Lemma"}}},
+		{"HTML tag", []any{map[string]any{"tag": "details", "label": "Details"}}},
+		{"duplicate tag", []any{map[string]any{"tag": "lemma", "label": "A"}, map[string]any{"tag": "lemma", "label": "B"}}},
+		{"empty label", []any{map[string]any{"tag": "lemma", "label": " "}}},
+	}
+	for _, tt := range invalid {
+		patch := map[string]any{"theorem_settings": map[string]any{"environments": tt.environments}}
+		if _, err := PatchProjectConfig(tmpDir, patch); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("%s: expected ErrInvalidSettings, but got %v", tt.name, err)
+		}
+	}
+	for _, key := range []string{"catalog_title", "untagged_label"} {
+		patch := map[string]any{"theorem_settings": map[string]any{key: ""}}
+		if _, err := PatchProjectConfig(tmpDir, patch); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("empty %s: expected ErrInvalidSettings, but got %v", key, err)
+		}
+	}
+}
+
+func TestCreateFile_TheoremEnvironment(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	defaultPath := filepath.Join(tmpDir, "default.md")
+	if err := CreateFile(defaultPath, ""); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	content, _ := ReadFile(defaultPath)
+	expected := "<theorem name=\"\">\n### 変数・条件\n\n\n### 主張\n\n</theorem>\n\n<details>\n<summary>証明</summary>\n\n</details>"
+	if content != expected {
+		t.Errorf("Expected %q, but got %q", expected, content)
+	}
+
+	patch := map[string]any{"theorem_settings": map[string]any{
+		"environments": []any{bilingualEnvironments[1], bilingualEnvironments[0]},
+		"proof_label":  "Proof",
+	}}
+	if _, err := PatchProjectConfig(tmpDir, patch); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	lemmaPath := filepath.Join(tmpDir, "lemma.md")
+	if err := CreateFile(lemmaPath, tmpDir); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	content, _ = ReadFile(lemmaPath)
+	expected = "<lemma name=\"\">\n</lemma>\n\n<details>\n<summary>Proof</summary>\n\n</details>"
+	if content != expected {
+		t.Errorf("Expected %q, but got %q", expected, content)
+	}
+}
+
+func TestWriteFile_TheoremEnvironments(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"theorem_settings": map[string]any{"environments": bilingualEnvironments}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	notePath := filepath.Join(tmpDir, "zorn.md")
+	content := "<lemma name=\"Zorn\">\n#order\n</lemma>\n\n<remark name=\"Choice\">\n</remark>\n\n<corollary name=\"Other\">\n</corollary>\n"
+	if err := WriteFile(notePath, content, tmpDir); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	theorems, err := LoadTheorems(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	expectedTheorems := map[string]string{"Zorn": "zorn.md", "Choice": "zorn.md"}
+	if !reflect.DeepEqual(theorems, expectedTheorems) {
+		t.Errorf("Expected %v, but got %v", expectedTheorems, theorems)
+	}
+
+	index, err := loadNoteIndex(tmpDir)
+	if err != nil {
+		t.Fatalf("loadNoteIndex failed: %v", err)
+	}
+	expectedIndexed := []IndexedTheorem{{Name: "Zorn", Tags: []string{"order"}}, {Name: "Choice", Tags: []string{}}}
+	if got := index.Files["zorn.md"].Theorems; !reflect.DeepEqual(got, expectedIndexed) {
+		t.Errorf("Expected %+v, but got %+v", expectedIndexed, got)
+	}
+}
+
+func TestRenderHTML_TheoremLabels(t *testing.T) {
+	settings := TheoremSettings{
+		Environments: []TheoremEnvironment{
+			{Tag: "theorem", Label: "Theorem", Counter: "thm"},
+			{Tag: "lemma", Label: "補題", Counter: "thm"},
+			{Tag: "remark", Label: "注意"},
+		},
+		ProofLabel: "Proof",
+	}
+	content := "<theorem name=\"A\">\n</theorem>\n\n<lemma name=\"\">\n</lemma>\n\n<remark name=\"B\">\n</remark>\n\n<theorem name=\"C\">\n</theorem>\n"
+	got := RenderHTML(parseMarkdown(content, settings), HTMLRenderOptions{Theorems: settings})
+
+	for _, want := range []string{
+		`<div class="theorem" data-environment="theorem"><h4 class="theorem-title" id="a"><span class="theorem-label">Theorem 1</span>A</h4>`,
+		`<div class="theorem" data-environment="lemma"><h4 class="theorem-title" id="補題"><span class="theorem-label">補題 2</span></h4>`,
+		`<div class="theorem" data-environment="remark"><h4 class="theorem-title" id="b"><span class="theorem-label">注意</span>B</h4>`,
+		`<span class="theorem-label">Theorem 3</span>C</h4>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected HTML to contain %q, but got:\n%s", want, got)
+		}
+	}
+}
+
+func TestLaTeXTheoremDefinitions(t *testing.T) {
+	settings := TheoremSettings{
+		Environments: []TheoremEnvironment{
+			{Tag: "theorem", Label: "定理", Counter: "thm"},
+			{Tag: "lemma", Label: "補題", Counter: "thm"},
+			{Tag: "remark", Label: "注意"},
+			{Tag: "example", Label: "例 & 反例", Counter: "ex"},
+		},
+		ProofLabel: "Proof",
+	}
+	expected := "\\newtheorem{theorem}{定理}\n" +
+		"\\newtheorem{lemma}[theorem]{補題}\n" +
+		"\\newtheorem*{remark}{注意}\n" +
+		"\\newtheorem{example}{例 \\& 反例}\n" +
+		"\\renewcommand{\\proofname}{Proof}\n"
+	if got := latexTheoremDefinitions(settings); got != expected {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+}
+
+func TestProofLabel(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"theorem_settings": map[string]any{"proof_label": "Beweis"}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	notePath := filepath.Join(tmpDir, "satz.md")
+	content := "<theorem name=\"Satz\">\nAussage\n</theorem>\n\n<details>\n<summary>Beweis</summary>\n\nklar\n</details>\n"
+	if err := os.WriteFile(notePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	outline, err := GetDocumentOutline(notePath, tmpDir)
+	if err != nil {
+		t.Fatalf("GetDocumentOutline failed: %v", err)
+	}
+	if len(outline) != 2 || outline[1].Kind != OutlineKindProof {
+		t.Errorf("Expected the <details> to be a proof, but got %+v", outline)
+	}
+
+	stats, err := GetVaultStats(tmpDir)
+	if err != nil {
+		t.Fatalf("GetVaultStats failed: %v", err)
+	}
+	if stats.Proofs != 1 {
+		t.Errorf("Expected 1 proof, but got %d", stats.Proofs)
+	}
+
+	cards, err := BuildFlashcards(tmpDir)
+	if err != nil {
+		t.Fatalf("BuildFlashcards failed: %v", err)
+	}
+	if len(cards) != 1 || !strings.Contains(cards[0].Back, "klar") {
+		t.Errorf("Expected the proof on the back of the card, but got %+v", cards)
+	}
+
+	outDir := filepath.Join(tmpDir, "out")
+	if _, err := ExportLaTeX(tmpDir, LaTeXExportOptions{OutputDir: outDir}); err != nil {
+		t.Fatalf("ExportLaTeX failed: %v", err)
+	}
+	tex, err := os.ReadFile(filepath.Join(outDir, "satz.tex"))
+	if err != nil {
+		t.Fatalf("Failed to read satz.tex: %v", err)
+	}
+	if !strings.Contains(string(tex), "\\begin{proof}\nklar") {
+		t.Errorf("Expected a proof environment, but got:\n%s", tex)
+	}
+}
@@ -0,0 +1,442 @@
+package backend
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	epubMimetype   = "application/epub+zip"
+	epubContentDir = "OEBPS"
+)
+
+var ErrEmptyOutputPath = errors.New("output path is not specified")
+
+// epubImageTypes は EPUB に収録できる画像の形式です
+var epubImageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="` + epubContentDir + `/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// epubStyle は電子書籍リーダーで表示を崩さないための、サイトのスタイルへの追加分です
+const epubStyle = `
+.markdown-preview .mermaid {
+  white-space: pre-wrap;
+  font-family: monospace;
+  text-align: left;
+}
+
+.markdown-preview math[display="block"] {
+  margin: 1em 0;
+}
+`
+
+// EPUBExportOptions は EPUB のエクスポートの対象と出力先を指定します。
+// ノートは Paths の順に章として収録され、Paths が空の場合はプロジェクト内のすべてのノートを収録します。
+// Title が空の場合はプロジェクトのディレクトリ名を、Language が空の場合は ja を使います
+type EPUBExportOptions struct {
+	Paths      []string `json:"paths"`
+	OutputPath string   `json:"output_path"`
+	Title      string   `json:"title"`
+	Author     string   `json:"author"`
+	Language   string   `json:"language"`
+}
+
+type epubChapter struct {
+	path   string
+	rel    string
+	id     string
+	title  string
+	doc    *Node
+	mathml bool
+}
+
+type epubImage struct {
+	id        string
+	src       string
+	href      string
+	mediaType string
+}
+
+type epubFile struct {
+	name    string
+	content string
+}
+
+type epubExporter struct {
+	rootDir  string
+	chapters map[string]*epubChapter
+	images   map[string]*epubImage
+	ordered  []*epubImage
+	macros   mathMacroSet
+	theorems TheoremSettings
+	result   ExportResult
+}
+
+// ExportEPUB はノートを EPUB 3 の電子書籍にまとめます。
+// 目次は各ノートの見出しと定理から作り、画像は書籍の中に収録し、数式は MathML にします
+func ExportEPUB(rootDir string, opts EPUBExportOptions) (ExportResult, error) {
+	if opts.OutputPath == "" {
+		return ExportResult{}, ErrEmptyOutputPath
+	}
+	paths, err := resolveExportPaths(rootDir, opts.Paths)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if opts.Title == "" {
+		opts.Title = filepath.Base(rootDir)
+	}
+	if opts.Language == "" {
+		opts.Language = "ja"
+	}
+
+	e := &epubExporter{
+		rootDir:  rootDir,
+		chapters: make(map[string]*epubChapter, len(paths)),
+		images:   make(map[string]*epubImage),
+		result:   ExportResult{Files: []string{}, Warnings: []string{}},
+	}
+	macros, warnings, err := loadMathMacroSet(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	e.macros = macros
+	e.result.Warnings = append(e.result.Warnings, warnings...)
+	if e.theorems, err = loadTheoremSettings(rootDir); err != nil {
+		return ExportResult{}, err
+	}
+	chapters := make([]*epubChapter, 0, len(paths))
+	for i, p := range paths {
+		content, err := ReadFile(p)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		rel, err := filepath.Rel(rootDir, p)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		chapter := &epubChapter{
+			path: p,
+			rel:  filepath.ToSlash(rel),
+			id:   fmt.Sprintf("ch%03d", i+1),
+			doc:  parseMarkdown(content, e.theorems),
+		}
+		chapter.title = noteTitle(chapter.rel, chapter.doc, buildIndexEntry(content, e.theorems).Metadata.Title)
+		e.chapters[p] = chapter
+		chapters = append(chapters, chapter)
+	}
+
+	bodies := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		bodies[i] = e.renderChapter(chapter)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0755); err != nil {
+		return ExportResult{}, err
+	}
+	out, err := os.Create(opts.OutputPath)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if err := e.writeArchive(out, opts, chapters, bodies); err != nil {
+		out.Close()
+		return ExportResult{}, err
+	}
+	if err := out.Close(); err != nil {
+		return ExportResult{}, err
+	}
+	e.result.Files = append(e.result.Files, opts.OutputPath)
+	return e.result, nil
+}
+
+func (e *epubExporter) warn(chapter *epubChapter, format string, args ...any) {
+	e.result.Warnings = append(e.result.Warnings, chapter.rel+": "+fmt.Sprintf(format, args...))
+}
+
+// renderChapter はノートを章の XHTML の本文にします
+func (e *epubExporter) renderChapter(chapter *epubChapter) string {
+	rawHTML := false
+	chapter.doc.Walk(func(node *Node) bool {
+		switch node.Kind {
+		case NodeKindMath, NodeKindMathBlock:
+			chapter.mathml = true
+		case NodeKindHTMLBlock, NodeKindHTMLInline:
+			rawHTML = true
+		case NodeKindMermaid:
+			e.warn(chapter, "mermaid diagram is included as source text")
+		case NodeKindImage:
+			if !e.embedImage(chapter, node) {
+				*node = Node{Kind: NodeKindText, Literal: node.Literal}
+			}
+		case NodeKindEmbed:
+			if !e.embedImage(chapter, node) {
+				*node = Node{Kind: NodeKindText, Literal: node.Destination}
+			}
+		}
+		return true
+	})
+	if rawHTML {
+		e.warn(chapter, "raw HTML is omitted")
+	}
+
+	return RenderHTML(chapter.doc, HTMLRenderOptions{
+		XHTML:      true,
+		Math:       texToMathML,
+		ExpandMath: e.macros.expand,
+		Theorems:   e.theorems,
+		ImageURL: func(name string) string {
+			return "../" + e.images[imagePath(e.rootDir, name)].href
+		},
+		WikiLinkURL: func(node *Node) (string, bool) {
+			target, err := chapter.path, error(nil)
+			if node.Destination != "" {
+				target, err = resolveLinkPath(e.rootDir, node.Destination)
+			}
+			targetChapter, ok := e.chapters[target]
+			if err != nil || !ok {
+				e.warn(chapter, "link [[%s]] does not point to an exported note", node.Literal)
+				return "", false
+			}
+			href := targetChapter.id + ".xhtml"
+			if node.Fragment != "" {
+				if item, ok := findOutlineHeading(buildOutline(targetChapter.doc.Children, targetChapter.doc.EndLine, e.theorems), node.Fragment); ok {
+					href += "#" + item.Slug
+				}
+			}
+			return href, true
+		},
+	})
+}
+
+// embedImage は画像を書籍に収録し、![...](...) の場合は参照先を収録したファイルに置き換えます。
+// 収録できない画像は警告を残して false を返します
+func (e *epubExporter) embedImage(chapter *epubChapter, node *Node) bool {
+	src := imagePath(e.rootDir, node.Destination)
+	if node.Kind == NodeKindImage {
+		if strings.Contains(node.Destination, "://") || strings.HasPrefix(node.Destination, "data:") {
+			e.warn(chapter, "remote image %s was not downloaded", node.Destination)
+			return false
+		}
+		decoded, err := url.PathUnescape(node.Destination)
+		if err != nil {
+			decoded = node.Destination
+		}
+		src = filepath.Join(filepath.Dir(chapter.path), filepath.FromSlash(decoded))
+	}
+
+	image, ok := e.images[src]
+	if !ok {
+		rel, err := filepath.Rel(e.rootDir, src)
+		mediaType := epubImageTypes[strings.ToLower(filepath.Ext(src))]
+		switch {
+		case err != nil || strings.HasPrefix(rel, ".."):
+			e.warn(chapter, "image %s is outside the project", node.Destination)
+			return false
+		case mediaType == "":
+			e.warn(chapter, "image %s is not a format supported by EPUB", node.Destination)
+			return false
+		}
+		if _, err := os.Stat(src); err != nil {
+			e.warn(chapter, "image %s was not found", node.Destination)
+			return false
+		}
+		image = &epubImage{
+			id:        fmt.Sprintf("img%03d", len(e.ordered)+1),
+			src:       src,
+			href:      (&url.URL{Path: "images/" + filepath.ToSlash(rel)}).EscapedPath(),
+			mediaType: mediaType,
+		}
+		e.images[src] = image
+		e.ordered = append(e.ordered, image)
+	}
+	if node.Kind == NodeKindImage {
+		node.Destination = "../" + image.href
+	}
+	return true
+}
+
+// writeArchive は EPUB の ZIP を書き出します。mimetype は圧縮せずに先頭に置く必要があります
+func (e *epubExporter) writeArchive(w io.Writer, opts EPUBExportOptions, chapters []*epubChapter, bodies []string) error {
+	zw := zip.NewWriter(w)
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, epubMimetype); err != nil {
+		return err
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainer},
+		{epubContentDir + "/content.opf", renderEPUBPackage(opts, chapters, e.ordered)},
+		{epubContentDir + "/nav.xhtml", renderEPUBNav(opts, chapters, e.theorems)},
+		{epubContentDir + "/style.css", siteStyle + epubStyle},
+	}
+	for i, chapter := range chapters {
+		body := `<section epub:type="chapter" class="markdown-preview">` + "\n" + bodies[i] + "\n</section>"
+		files = append(files, epubFile{
+			name:    epubContentDir + "/text/" + chapter.id + ".xhtml",
+			content: renderEPUBDocument(opts.Language, chapter.title, "../style.css", body),
+		})
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range e.ordered {
+		name, err := url.PathUnescape(image.href)
+		if err != nil {
+			return err
+		}
+		fw, err := zw.Create(epubContentDir + "/" + name)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(image.src)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// renderEPUBDocument は XHTML の文書全体を作ります
+func renderEPUBDocument(language string, title string, stylesheet string, body string) string {
+	lang := html.EscapeString(language)
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + lang + `" lang="` + lang + `">
+<head>
+<meta charset="UTF-8" />
+<title>` + html.EscapeString(title) + `</title>
+<link rel="stylesheet" type="text/css" href="` + stylesheet + `" />
+</head>
+<body>
+` + body + `
+</body>
+</html>
+`
+}
+
+// renderEPUBNav は各章とその見出し・定理を入れ子にした目次を作ります
+func renderEPUBNav(opts EPUBExportOptions, chapters []*epubChapter, theorems TheoremSettings) string {
+	var b strings.Builder
+	b.WriteString(`<nav epub:type="toc" id="toc">` + "\n<h1>目次</h1>\n<ol>\n")
+	for _, chapter := range chapters {
+		href := "text/" + chapter.id + ".xhtml"
+		b.WriteString(`<li><a href="` + href + `">` + html.EscapeString(chapter.title) + "</a>")
+		writeEPUBNavItems(&b, href, buildOutline(chapter.doc.Children, chapter.doc.EndLine, theorems))
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n</nav>")
+	return renderEPUBDocument(opts.Language, opts.Title, "style.css", b.String())
+}
+
+// writeEPUBNavItems は見出しと定理を目次に加えます。証明などの折りたたみは項目にせず、中の見出しだけを加えます
+func writeEPUBNavItems(b *strings.Builder, href string, items []OutlineItem) {
+	var entries []OutlineItem
+	var collect func(items []OutlineItem)
+	collect = func(items []OutlineItem) {
+		for _, item := range items {
+			if item.Kind == OutlineKindHeading || item.Kind == OutlineKindTheorem {
+				entries = append(entries, item)
+			} else {
+				collect(item.Children)
+			}
+		}
+	}
+	collect(items)
+	if len(entries) == 0 {
+		return
+	}
+
+	b.WriteString("\n<ol>\n")
+	for _, item := range entries {
+		title := item.Title
+		if title == "" {
+			title = "Theorem"
+		}
+		b.WriteString(`<li><a href="` + href + "#" + html.EscapeString(item.Slug) + `">` + html.EscapeString(title) + "</a>")
+		writeEPUBNavItems(b, href, item.Children)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
+
+// renderEPUBPackage はパッケージ文書 (content.opf) を作ります
+func renderEPUBPackage(opts EPUBExportOptions, chapters []*epubChapter, images []*epubImage) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + html.EscapeString(opts.Language) + `">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">urn:uuid:` + epubIdentifier(opts.Title, chapters) + `</dc:identifier>
+<dc:title>` + html.EscapeString(opts.Title) + `</dc:title>
+<dc:language>` + html.EscapeString(opts.Language) + "</dc:language>\n")
+	if opts.Author != "" {
+		b.WriteString("<dc:creator>" + html.EscapeString(opts.Author) + "</dc:creator>\n")
+	}
+	b.WriteString(`<meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n</metadata>\n<manifest>\n")
+	b.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	b.WriteString(`<item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for _, chapter := range chapters {
+		properties := ""
+		if chapter.mathml {
+			properties = ` properties="mathml"`
+		}
+		b.WriteString(`<item id="` + chapter.id + `" href="text/` + chapter.id + `.xhtml" media-type="application/xhtml+xml"` + properties + "/>\n")
+	}
+	for _, image := range images {
+		b.WriteString(`<item id="` + image.id + `" href="` + html.EscapeString(image.href) + `" media-type="` + image.mediaType + `"/>` + "\n")
+	}
+	b.WriteString("</manifest>\n<spine>\n")
+	for _, chapter := range chapters {
+		b.WriteString(`<itemref idref="` + chapter.id + `"/>` + "\n")
+	}
+	b.WriteString("</spine>\n</package>\n")
+	return b.String()
+}
+
+// epubIdentifier はタイトルと収録するノートから UUID を作ります。同じ内容で出力し直しても識別子は変わりません
+func epubIdentifier(title string, chapters []*epubChapter) string {
+	h := sha1.New()
+	io.WriteString(h, title)
+	for _, chapter := range chapters {
+		io.WriteString(h, "\x00"+chapter.rel)
+	}
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
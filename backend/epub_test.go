@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportEPUB(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rootDir := filepath.Join(tmpDir, "vault")
+	files := map[string]string{
+		"intro.md": "# はじめに\n\n[[analysis/limit#定義|極限の定義]] を参照。<br>\n\n- [x] 読む\n",
+		"analysis/limit.md": "# 極限\n\n## 定義\n\n<theorem name=\"ε-δ 論法\">\n$\\lim_{x \\to a} f(x) = b$\n</theorem>\n\n" +
+			"$$\n\\frac{1}{2} < \\sqrt{x}\n$$\n\n![[グラフ.png]] ![図](fig.png) ![外部](https://example.com/a.png)\n",
+		"analysis/fig.png": "png",
+		"_images/グラフ.png":  "png",
+	}
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	outPath := filepath.Join(tmpDir, "out", "notes.epub")
+	result, err := ExportEPUB(rootDir, EPUBExportOptions{
+		Paths:      []string{"intro.md", "analysis/limit.md"},
+		OutputPath: outPath,
+		Title:      "解析学ノート",
+		Author:     "著者",
+	})
+	if err != nil {
+		t.Fatalf("ExportEPUB failed: %v", err)
+	}
+	expectedWarnings := []string{
+		"intro.md: raw HTML is omitted",
+		"analysis/limit.md: remote image https://example.com/a.png was not downloaded",
+	}
+	if strings.Join(result.Warnings, "\n") != strings.Join(expectedWarnings, "\n") {
+		t.Errorf("Expected warnings %v, but got %v", expectedWarnings, result.Warnings)
+	}
+
+	r, err := zip.OpenReader(outPath)
+	if err != nil {
+		t.Fatalf("Failed to open EPUB: %v", err)
+	}
+	defer r.Close()
+
+	if first := r.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("mimetype must be the first stored entry, but got %s (method %d)", first.Name, first.Method)
+	}
+	contents := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		contents[f.Name] = string(data)
+	}
+
+	for name, content := range contents {
+		if !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("%s is not well-formed XML: %v\n%s", name, err, content)
+				}
+				break
+			}
+		}
+	}
+
+	contains := func(name string, wants ...string) {
+		content, ok := contents[name]
+		if !ok {
+			t.Errorf("%s is not in the EPUB", name)
+			return
+		}
+		for _, want := range wants {
+			if !strings.Contains(content, want) {
+				t.Errorf("%s does not contain %q:\n%s", name, want, content)
+			}
+		}
+	}
+	contains("mimetype", "application/epub+zip")
+	contains("OEBPS/content.opf",
+		"<dc:title>解析学ノート</dc:title>",
+		"<dc:creator>著者</dc:creator>",
+		`<item id="ch001" href="text/ch001.xhtml" media-type="application/xhtml+xml"/>`,
+		`<item id="ch002" href="text/ch002.xhtml" media-type="application/xhtml+xml" properties="mathml"/>`,
+		`<item id="img001" href="images/_images/%E3%82%B0%E3%83%A9%E3%83%95.png" media-type="image/png"/>`,
+		`<item id="img002" href="images/analysis/fig.png" media-type="image/png"/>`,
+		"<itemref idref=\"ch001\"/>\n<itemref idref=\"ch002\"/>",
+	)
+	contains("OEBPS/nav.xhtml",
+		`<li><a href="text/ch002.xhtml">極限</a>`,
+		`<li><a href="text/ch002.xhtml#定義">定義</a>`,
+		`<li><a href="text/ch002.xhtml#ε-δ-論法">ε-δ 論法</a></li>`,
+	)
+	contains("OEBPS/text/ch001.xhtml",
+		`<a href="ch002.xhtml#定義">極限の定義</a>`,
+		`<input type="checkbox" checked="checked" disabled="disabled" />`,
+	)
+	contains("OEBPS/text/ch002.xhtml",
+		`<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow><msub><mi>lim</mi>`,
+		`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><mfrac><mn>1</mn><mn>2</mn></mfrac><mo>&lt;</mo><msqrt><mi>x</mi></msqrt>`,
+		`<img src="../images/_images/%E3%82%B0%E3%83%A9%E3%83%95.png" alt="グラフ.png" />`,
+		`<img src="../images/analysis/fig.png" alt="図" />`,
+		"外部",
+	)
+	contains("OEBPS/images/_images/グラフ.png", "png")
+	contains("OEBPS/images/analysis/fig.png", "png")
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const imagesDirName = "_images"
+
+var ErrEmptyOutputDir = errors.New("output directory is not specified")
+
+// ExportResult はエクスポートで書き出したファイルと、変換できなかった箇所の警告を保持します
+type ExportResult struct {
+	Files    []string `json:"files"`
+	Warnings []string `json:"warnings"`
+}
+
+// resolveExportPaths はエクスポート対象のノートを返します。paths が空の場合はプロジェクト内のすべてのノートが対象です
+func resolveExportPaths(rootDir string, paths []string) ([]string, error) {
+	if rootDir == "" {
+		return nil, os.ErrInvalid
+	}
+	if len(paths) == 0 {
+		return listMarkdownFiles(rootDir)
+	}
+
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(rootDir, path)
+		}
+		result = append(result, filepath.Clean(path))
+	}
+	return slices.Compact(result), nil
+}
+
+// imagePath は ![[name]] で埋め込まれた画像のパスを返します
+func imagePath(rootDir string, name string) string {
+	return filepath.Join(rootDir, imagesDirName, filepath.FromSlash(name))
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strings"
 )
 
 type FileItem struct {
@@ -22,18 +24,6 @@ const (
 	sessionDirPath   = ".theorem-note"
 	sessionFileName  = "session.json"
 	theoremsFileName = "theorems.json"
-	fileTemplate     = `<theorem name="">
-### 変数・条件
-
-
-### 主張
-
-</theorem>
-
-<details>
-<summary>証明</summary>
-
-</details>`
 )
 
 func GetNewDirectoryFileTree(ctx context.Context) (string, []FileItem, error) {
@@ -85,6 +75,36 @@ func GetFileTree(path string) ([]FileItem, error) {
 	return items, nil
 }
 
+// listMarkdownFiles は rootDir 以下の Markdown ファイルを列挙します。
+// ドットで始まるディレクトリ (.theorem-note や .git) は対象外です
+func listMarkdownFiles(rootDir string) ([]string, error) {
+	return listFilesByExt(rootDir, ".md")
+}
+
+// listFilesByExt はドットで始まるディレクトリを除いて、拡張子が ext のファイルを再帰的に探します
+func listFilesByExt(rootDir string, ext string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != rootDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ext) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 func ReadFile(path string) (string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -93,19 +113,78 @@ func ReadFile(path string) (string, error) {
 	return string(data), nil
 }
 
+// writeFileAtomic は同じディレクトリの一時ファイルに書き込んでから名前を変更し、
+// 書き込みの途中で終了しても path に中途半端な内容が残らないようにします
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// NoteFile はノートの内容とフロントマターのメタデータを保持します。
+// フロントマターを解釈できなかった場合は MetadataError にその理由が入ります
+type NoteFile struct {
+	Content       string       `json:"content"`
+	Metadata      NoteMetadata `json:"metadata"`
+	MetadataError string       `json:"metadata_error"`
+}
+
+func ReadNote(path string) (NoteFile, error) {
+	content, err := ReadFile(path)
+	if err != nil {
+		return NoteFile{}, err
+	}
+
+	note := NoteFile{Content: content}
+	note.Metadata, err = ParseNoteMetadata(content)
+	if err != nil {
+		note.MetadataError = err.Error()
+	}
+	return note, nil
+}
+
 func WriteFile(path string, content string, rootDir string) error {
 	err := os.WriteFile(path, []byte(content), 0644)
 	if err != nil {
 		return err
 	}
-	return extractAndSaveTheorems(path, content, rootDir)
+	if err := extractAndSaveTheorems(path, content, rootDir); err != nil {
+		return err
+	}
+	if rootDir == "" {
+		return nil
+	}
+	return updateNoteIndex(path, content, rootDir)
 }
 
-func CreateFile(path string) error {
+// CreateFile は rootDir のプロジェクトの先頭の定理環境を使ったテンプレートで、新しいノートを作ります
+func CreateFile(path string, rootDir string) error {
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
 		return os.ErrExist
 	}
-	return os.WriteFile(path, []byte(fileTemplate), 0644)
+	settings, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(settings.noteTemplate()), 0644)
 }
 
 func CreateDirectory(path string) error {
@@ -122,38 +201,104 @@ func getTheoremsFilePath(rootDir string) (string, error) {
 	return filepath.Join(rootDir, sessionDirPath, theoremsFileName), nil
 }
 
-func extractAndSaveTheorems(path string, content string, rootDir string) error {
-	re := regexp.MustCompile(`<theorem name="([^"]+)">`)
-	matches := re.FindAllStringSubmatch(content, -1)
+func theoremsFileExists(rootDir string) bool {
+	path, err := getTheoremsFilePath(rootDir)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
 
-	if len(matches) == 0 {
-		return nil
+// theoremsFile は theorems.json の内容です。Theorems は定理名から、定義したノートのルートからの相対パス (/ 区切り) への対応です
+type theoremsFile struct {
+	Version  int               `json:"version"`
+	Theorems map[string]string `json:"theorems"`
+}
+
+func loadTheoremsFile(rootDir string) (map[string]string, error) {
+	path, err := getTheoremsFilePath(rootDir)
+	if err != nil {
+		return nil, err
 	}
+	data, err := loadSidecar(rootDir, path, theoremsSchema)
+	if err != nil {
+		return nil, err
+	}
+	file := theoremsFile{Theorems: make(map[string]string)}
+	if data == nil {
+		return file.Theorems, nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Theorems == nil {
+		file.Theorems = make(map[string]string)
+	}
+	return file.Theorems, nil
+}
 
+func saveTheoremsFile(rootDir string, theorems map[string]string) error {
 	if err := ensureSessionDirExists(rootDir); err != nil {
 		return err
 	}
-
-	theoremsFilePath, err := getTheoremsFilePath(rootDir)
+	path, err := getTheoremsFilePath(rootDir)
 	if err != nil {
 		return err
 	}
+	data, err := json.MarshalIndent(theoremsFile{Version: theoremsSchema.version(), Theorems: theorems}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
 
-	theorems := make(map[string]string)
-	if _, err := os.Stat(theoremsFilePath); !os.IsNotExist(err) {
-		data, err := os.ReadFile(theoremsFilePath)
-		if err != nil {
-			return err
-		}
-		if len(data) > 0 {
-			if err := json.Unmarshal(data, &theorems); err != nil {
-				return err
+// migrateTheoremsV0 は定理名から絶対パスへの対応だけを保存していた theorems.json を変換します。
+// プロジェクトのフォルダを移動しても定理のリンクが切れないように、パスはルートからの相対パスにします
+func migrateTheoremsV0(rootDir string, data []byte) ([]byte, error) {
+	var legacy map[string]string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	file := theoremsFile{Version: 1, Theorems: make(map[string]string, len(legacy))}
+	for name, path := range legacy {
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(rootDir, path)
+			if err != nil {
+				return nil, err
 			}
+			path = rel
 		}
+		file.Theorems[name] = filepath.ToSlash(path)
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+func extractAndSaveTheorems(path string, content string, rootDir string) error {
+	settings, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return err
+	}
+	re := regexp.MustCompile(`<(?:` + settings.tagPattern() + `) name="([^"]+)">`)
+	matches := re.FindAllStringSubmatch(content, -1)
+
+	// 定理が含まれないファイルでも、以前登録した定理は削除する必要がある
+	if len(matches) == 0 && !theoremsFileExists(rootDir) {
+		return nil
+	}
+
+	key, ok := indexKey(rootDir, path)
+	if !ok {
+		return nil
+	}
+
+	theorems, err := loadTheoremsFile(rootDir)
+	if err != nil {
+		return err
 	}
 
 	for k, v := range theorems {
-		if v == path {
+		if v == key {
 			delete(theorems, k)
 		}
 	}
@@ -161,16 +306,11 @@ func extractAndSaveTheorems(path string, content string, rootDir string) error {
 	for _, match := range matches {
 		if len(match) > 1 {
 			theoremName := match[1]
-			theorems[theoremName] = path
+			theorems[theoremName] = key
 		}
 	}
 
-	data, err := json.MarshalIndent(theorems, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(theoremsFilePath, data, 0644)
+	return saveTheoremsFile(rootDir, theorems)
 }
 
 func getSessionFilePath(rootDir string) (string, error) {
@@ -187,81 +327,52 @@ func ensureSessionDirExists(rootDir string) error {
 	return os.MkdirAll(filepath.Join(rootDir, sessionDirPath), 0755)
 }
 
+// SaveSession は開いているファイルの一覧を保存します。
+// 引き続き開いているファイルのカーソルやスクロール位置、表示モードなどは以前の状態を引き継ぎます
 func SaveSession(rootDir string, filePaths []string) error {
-	if err := ensureSessionDirExists(rootDir); err != nil {
-		return err
-	}
-
-	path, err := getSessionFilePath(rootDir)
+	state, err := LoadSessionState(rootDir)
 	if err != nil {
 		return err
 	}
 
-	data, err := json.Marshal(filePaths)
-	if err != nil {
-		return err
+	tabs := make([]SessionTab, 0, len(filePaths))
+	for _, path := range filePaths {
+		tab := SessionTab{Path: path}
+		if i := slices.IndexFunc(state.Tabs, func(t SessionTab) bool { return t.Path == path }); i >= 0 {
+			tab = state.Tabs[i]
+		}
+		tabs = append(tabs, tab)
 	}
-
-	return os.WriteFile(path, data, 0644)
+	state.Tabs = tabs
+	return SaveSessionState(rootDir, state)
 }
 
+// LoadSession は開いていたファイルの一覧を返します
 func LoadSession(rootDir string) ([]string, error) {
-	if rootDir == "" {
-		return []string{}, nil
-	}
-
-	path, err := getSessionFilePath(rootDir)
+	state, err := LoadSessionState(rootDir)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return []string{}, nil
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var filePaths []string
-	if err := json.Unmarshal(data, &filePaths); err != nil {
-		return nil, err
+	filePaths := make([]string, 0, len(state.Tabs))
+	for _, tab := range state.Tabs {
+		filePaths = append(filePaths, tab.Path)
 	}
-
 	return filePaths, nil
 }
 
+// LoadTheorems は定理名から、定義したノートのルートからの相対パスへの対応を返します
 func LoadTheorems(rootDir string) (map[string]string, error) {
 	if rootDir == "" {
 		return make(map[string]string), nil
 	}
 
-	path, err := getTheoremsFilePath(rootDir)
+	theorems, err := loadTheoremsFile(rootDir)
 	if err != nil {
 		return nil, err
 	}
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return make(map[string]string), nil
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var theorems map[string]string
-	if err := json.Unmarshal(data, &theorems); err != nil {
-		return nil, err
-	}
-
 	for k, v := range theorems {
-		theorems[k], err = filepath.Rel(rootDir, v)
-		if err != nil {
-			return nil, err
-		}
+		theorems[k] = filepath.FromSlash(v)
 	}
-
 	return theorems, nil
 }
@@ -275,14 +275,15 @@ func TestWriteFile_WithTheoremTag(t *testing.T) {
 		t.Fatalf("Failed to read theorems.json: %v", err)
 	}
 
-	var theorems map[string]string
+	var theorems theoremsFile
 	err = json.Unmarshal(file, &theorems)
 	if err != nil {
 		t.Fatalf("Failed to unmarshal theorems.json: %v", err)
 	}
 
-	expectedTheorems := map[string]string{
-		"Test Theorem": filePath,
+	expectedTheorems := theoremsFile{
+		Version:  theoremsSchema.version(),
+		Theorems: map[string]string{"Test Theorem": "test.md"},
 	}
 
 	if !reflect.DeepEqual(theorems, expectedTheorems) {
@@ -393,7 +394,7 @@ func TestCreateFile(t *testing.T) {
 	filePath := filepath.Join(tmpDir, "testfile.txt")
 
 	// Test creating a new file
-	err = CreateFile(filePath)
+	err = CreateFile(filePath, "")
 	if err != nil {
 		t.Fatalf("CreateFile failed: %v", err)
 	}
@@ -404,7 +405,7 @@ func TestCreateFile(t *testing.T) {
 	}
 
 	// Test creating a file that already exists
-	err = CreateFile(filePath)
+	err = CreateFile(filePath, "")
 	if err == nil {
 		t.Fatalf("Expected an error when creating a file that already exists, but got nil")
 	}
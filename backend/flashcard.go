@@ -0,0 +1,303 @@
+package backend
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	FlashcardFormatTSV = "tsv"
+	FlashcardFormatCSV = "csv"
+
+	// flashcardMediaSuffix は出力ファイルの隣に作る、カードが参照する画像のディレクトリ名の接尾辞です
+	flashcardMediaSuffix = "_media"
+)
+
+var ErrUnknownFlashcardFormat = errors.New("unknown flashcard format")
+
+// Flashcard は定理 1 つ分のカードです。ID は定理名から作るので、ファイルを移動しても変わりません。
+// ただし同じ名前の定理が複数ある場合は、Anki で 1 枚のカードにまとめられないようにノートのパスも使います。
+// Front と Back は Anki などに取り込める HTML で、数式は MathJax の \( \) と \[ \] で囲みます
+type Flashcard struct {
+	ID      string   `json:"id"`
+	Theorem string   `json:"theorem"`
+	Path    string   `json:"path"`
+	Front   string   `json:"front"`
+	Back    string   `json:"back"`
+	Tags    []string `json:"tags"`
+}
+
+// FlashcardOptions はカードの形式と出力先を指定します
+type FlashcardOptions struct {
+	Format     string `json:"format"`
+	OutputPath string `json:"output_path"`
+}
+
+// flashcardBuilder はカードを作りながら、参照された画像と警告を集めます
+type flashcardBuilder struct {
+	rootDir  string
+	macros   mathMacroSet
+	theorems TheoremSettings
+	images   []string
+	// media は images の画像から、_media にコピーするときのファイル名への対応です
+	media map[string]string
+	// duplicated はプロジェクト内に複数ある定理名で、seen は作ったカードのノートのパスと定理名ごとの数です
+	duplicated map[string]bool
+	seen       map[string]int
+	warnings   []string
+}
+
+// BuildFlashcards はインデックスにある名前付きの定理ごとに、定理の本文を表に、証明を裏にしたカードを作ります。
+// 証明は定理ブロック内か、定理ブロックの直後にある証明の <details> です
+func BuildFlashcards(rootDir string) ([]Flashcard, error) {
+	return (&flashcardBuilder{rootDir: rootDir}).build()
+}
+
+func (b *flashcardBuilder) build() ([]Flashcard, error) {
+	index, err := loadNoteIndex(b.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	macros, warnings, err := loadMathMacroSet(b.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	b.macros = macros
+	b.warnings = append(b.warnings, warnings...)
+	if b.theorems, err = loadTheoremSettings(b.rootDir); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(index.Files))
+	for key, entry := range index.Files {
+		if len(entry.Theorems) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	counts := make(map[string]int)
+	for _, key := range keys {
+		for _, theorem := range index.Files[key].Theorems {
+			counts[theorem.Name]++
+		}
+	}
+	b.duplicated = make(map[string]bool)
+	for name, count := range counts {
+		b.duplicated[name] = count > 1
+	}
+	b.seen = make(map[string]int)
+
+	cards := []Flashcard{}
+	for _, key := range keys {
+		content, err := ReadFile(filepath.Join(b.rootDir, filepath.FromSlash(key)))
+		if err != nil {
+			return nil, err
+		}
+		tags := map[string][]string{}
+		for _, theorem := range index.Files[key].Theorems {
+			tags[theorem.Name] = theorem.Tags
+		}
+		b.collect(parseMarkdown(content, b.theorems).Children, key, tags, &cards)
+	}
+	return cards, nil
+}
+
+// collect は兄弟のブロックの並びから定理を探します。直後の兄弟の証明を見るため、Walk ではなく並びごとに辿ります
+func (b *flashcardBuilder) collect(nodes []*Node, key string, tags map[string][]string, cards *[]Flashcard) {
+	for i, node := range nodes {
+		if node.Kind != NodeKindTheorem {
+			b.collect(node.Children, key, tags, cards)
+			continue
+		}
+		if node.Title == "" {
+			continue
+		}
+
+		var front []*Node
+		var proof *Node
+		for _, child := range node.Children {
+			if child.Kind == NodeKindDetails && isProofSummary(child.Title, b.theorems) && proof == nil {
+				proof = child
+				continue
+			}
+			front = append(front, child)
+		}
+		if proof == nil && i+1 < len(nodes) && nodes[i+1].Kind == NodeKindDetails && isProofSummary(nodes[i+1].Title, b.theorems) {
+			proof = nodes[i+1]
+		}
+
+		card := Flashcard{
+			ID:      b.cardID(node.Title, key),
+			Theorem: node.Title,
+			Path:    key,
+			Front:   "<p><strong>" + html.EscapeString(node.Title) + "</strong></p>\n" + b.render(front, key),
+			Tags:    append([]string{flashcardPathTag(key)}, tags[node.Title]...),
+		}
+		if proof != nil {
+			card.Back = b.render(proof.Children, key)
+		} else {
+			b.warnings = append(b.warnings, key+": theorem "+node.Title+" has no proof")
+		}
+		*cards = append(*cards, card)
+	}
+}
+
+// render はブロックをカード用の HTML にします。![[...]] の画像はファイル名だけで参照し、出力時にまとめてコピーします
+func (b *flashcardBuilder) render(nodes []*Node, key string) string {
+	doc := &Node{Kind: NodeKindDocument, Children: nodes}
+	doc.Walk(func(node *Node) bool {
+		if node.Kind == NodeKindImage && !strings.Contains(node.Destination, "://") {
+			b.warnings = append(b.warnings, key+": image "+node.Destination+" is not included; use ![[...]] instead")
+		}
+		return true
+	})
+	return strings.TrimSpace(RenderHTML(doc, HTMLRenderOptions{
+		ProjectRoot: b.rootDir,
+		ExpandMath:  b.macros.expand,
+		Theorems:    b.theorems,
+		ImageURL:    b.mediaName,
+		Math: func(tex string, display bool) string {
+			if display {
+				return `\[` + html.EscapeString(tex) + `\]`
+			}
+			return `\(` + html.EscapeString(tex) + `\)`
+		},
+	}))
+}
+
+// mediaName は _images の画像 name を _media にコピーするときのファイル名を返します。
+// _media はディレクトリを持てないのでファイル名だけにし、別のディレクトリの同名の画像とは fig-2.png のように番号で区別します
+func (b *flashcardBuilder) mediaName(name string) string {
+	if media, ok := b.media[name]; ok {
+		return media
+	}
+	if b.media == nil {
+		b.media = make(map[string]string)
+	}
+	base := path.Base(name)
+	media := base
+	ext := path.Ext(base)
+	taken := func(media string) bool {
+		return slices.ContainsFunc(b.images, func(other string) bool { return b.media[other] == media })
+	}
+	for i := 2; taken(media); i++ {
+		media = strings.TrimSuffix(base, ext) + "-" + strconv.Itoa(i) + ext
+	}
+	b.images = append(b.images, name)
+	b.media[name] = media
+	return media
+}
+
+// cardID は key のノートの定理 theorem のカードの ID を返します。同じ名前の定理が複数ある場合は
+// ノートのパス (同じノートに複数ある場合はさらに何番目か) も使い、移動すると ID が変わることを警告します
+func (b *flashcardBuilder) cardID(theorem string, key string) string {
+	if !b.duplicated[theorem] {
+		return flashcardID(theorem)
+	}
+	source := key + "#" + theorem
+	b.seen[source]++
+	if n := b.seen[source]; n > 1 {
+		source += "#" + strconv.Itoa(n)
+	}
+	b.warnings = append(b.warnings, key+": theorem "+theorem+" has the same name as another theorem; its card ID changes if the note is moved")
+	return flashcardID(source)
+}
+
+// flashcardID は定理名から、再エクスポートしても変わらないカードの ID を作ります
+func flashcardID(theorem string) string {
+	sum := sha1.Sum([]byte("theorem-note:" + theorem))
+	return "tn-" + hex.EncodeToString(sum[:8])
+}
+
+// flashcardPathTag はノートのパスを Anki の階層タグ (topology::compact) にします。タグに空白は使えないので _ にします
+func flashcardPathTag(key string) string {
+	tag := strings.TrimSuffix(key, path.Ext(key))
+	tag = strings.ReplaceAll(tag, "/", "::")
+	return strings.Join(strings.Fields(tag), "_")
+}
+
+// FormatFlashcards はカードを TSV または CSV にします。先頭の # の行は Anki の取り込み設定で、
+// 1 列目の ID を guid として使うので、同じカードを取り込み直すと既存のカードが更新されます
+func FormatFlashcards(cards []Flashcard, format string) (string, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	switch format {
+	case FlashcardFormatTSV, "":
+		w.Comma = '\t'
+		b.WriteString("#separator:tab\n")
+	case FlashcardFormatCSV:
+		b.WriteString("#separator:comma\n")
+	default:
+		return "", ErrUnknownFlashcardFormat
+	}
+	b.WriteString("#html:true\n#guid column:1\n#tags column:4\n")
+
+	for _, card := range cards {
+		tags := make([]string, len(card.Tags))
+		for i, tag := range card.Tags {
+			tags[i] = strings.Join(strings.Fields(tag), "_")
+		}
+		if err := w.Write([]string{card.ID, card.Front, card.Back, strings.Join(tags, " ")}); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ExportFlashcards はカードを opts.OutputPath に書き出し、カードが参照する画像を隣の _media ディレクトリにコピーします
+func ExportFlashcards(rootDir string, opts FlashcardOptions) (ExportResult, error) {
+	if rootDir == "" {
+		return ExportResult{}, os.ErrInvalid
+	}
+	if opts.OutputPath == "" {
+		return ExportResult{}, ErrEmptyOutputPath
+	}
+	b := &flashcardBuilder{rootDir: rootDir}
+	cards, err := b.build()
+	if err != nil {
+		return ExportResult{}, err
+	}
+	content, err := FormatFlashcards(cards, opts.Format)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0755); err != nil {
+		return ExportResult{}, err
+	}
+	if err := os.WriteFile(opts.OutputPath, []byte(content), 0644); err != nil {
+		return ExportResult{}, err
+	}
+	result := ExportResult{Files: []string{opts.OutputPath}, Warnings: []string{}}
+	result.Warnings = append(result.Warnings, b.warnings...)
+
+	mediaDir := strings.TrimSuffix(opts.OutputPath, filepath.Ext(opts.OutputPath)) + flashcardMediaSuffix
+	for _, name := range b.images {
+		dst := filepath.Join(mediaDir, b.media[name])
+		if err := os.MkdirAll(mediaDir, 0755); err != nil {
+			return ExportResult{}, err
+		}
+		if err := copyFile(imagePath(rootDir, name), dst); err != nil {
+			if os.IsNotExist(err) {
+				result.Warnings = append(result.Warnings, "image "+name+" was not found")
+				continue
+			}
+			return ExportResult{}, err
+		}
+		result.Files = append(result.Files, dst)
+	}
+	return result, nil
+}
@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFlashcardVault(t *testing.T) string {
+	t.Helper()
+	rootDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	writeTestVault(t, rootDir, map[string]string{
+		"topology/compact space.md": "# コンパクト\n\n" +
+			"<theorem name=\"ハイネ・ボレル\">\n" +
+			"### 主張\n" +
+			"$K \\subset \\mathbb{R}^n$ がコンパクト \\iff 有界閉集合\n\n" +
+			"#topology\n" +
+			"</theorem>\n\n" +
+			"<details>\n<summary>証明</summary>\n\n$$\na < b\n$$\n\n![[cover.png]]\n</details>\n",
+		"algebra.md": "<theorem name=\"ラグランジュ\">\n" +
+			"位数は割り切る\n\n" +
+			"<details>\n<summary>proof</summary>\n\n剰余類\n</details>\n" +
+			"</theorem>\n\n" +
+			"<theorem name=\"証明なし\">\n主張だけ\n</theorem>\n",
+		"_images/cover.png": "png",
+	})
+	if err := RebuildIndex(rootDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	return rootDir
+}
+
+func TestBuildFlashcards(t *testing.T) {
+	rootDir := writeTestFlashcardVault(t)
+	defer os.RemoveAll(rootDir)
+
+	cards, err := BuildFlashcards(rootDir)
+	if err != nil {
+		t.Fatalf("BuildFlashcards failed: %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("Expected 3 cards, but got %+v", cards)
+	}
+
+	lagrange := cards[0]
+	if lagrange.Theorem != "ラグランジュ" || lagrange.Path != "algebra.md" {
+		t.Errorf("Unexpected card: %+v", lagrange)
+	}
+	if strings.Contains(lagrange.Front, "剰余類") || lagrange.Back != "<p>剰余類</p>" {
+		t.Errorf("Expected the proof inside the theorem on the back, but got front %q and back %q", lagrange.Front, lagrange.Back)
+	}
+	if cards[1].Back != "" {
+		t.Errorf("Expected an empty back for a theorem without proof, but got %q", cards[1].Back)
+	}
+
+	heine := cards[2]
+	if !strings.HasPrefix(heine.Front, "<p><strong>ハイネ・ボレル</strong></p>") || !strings.Contains(heine.Front, `\(K \subset \mathbb{R}^n\)`) {
+		t.Errorf("Unexpected front: %q", heine.Front)
+	}
+	if !strings.Contains(heine.Back, `\[a &lt; b\]`) || !strings.Contains(heine.Back, `src="cover.png"`) {
+		t.Errorf("Unexpected back: %q", heine.Back)
+	}
+	if !reflect.DeepEqual(heine.Tags, []string{"topology::compact_space", "topology"}) {
+		t.Errorf("Unexpected tags: %v", heine.Tags)
+	}
+
+	// ID は定理名だけから決まるので、ファイルを移動しても変わらない
+	if heine.ID != flashcardID("ハイネ・ボレル") || heine.ID == lagrange.ID {
+		t.Errorf("Unexpected IDs: %q and %q", heine.ID, lagrange.ID)
+	}
+	if err := os.Rename(filepath.Join(rootDir, "algebra.md"), filepath.Join(rootDir, "group.md")); err != nil {
+		t.Fatalf("Failed to rename note: %v", err)
+	}
+	if err := RebuildIndex(rootDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	moved, err := BuildFlashcards(rootDir)
+	if err != nil {
+		t.Fatalf("BuildFlashcards failed: %v", err)
+	}
+	if moved[0].Path != "group.md" || moved[0].ID != lagrange.ID {
+		t.Errorf("Expected the ID to survive a rename, but got %+v", moved[0])
+	}
+}
+
+func TestBuildFlashcards_DuplicateNames(t *testing.T) {
+	rootDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	theorem := "<theorem name=\"基本定理\">\n主張\n</theorem>\n\n"
+	writeTestVault(t, rootDir, map[string]string{
+		"algebra.md":  theorem,
+		"calculus.md": theorem + theorem + "<theorem name=\"平均値の定理\">\n主張\n</theorem>\n",
+	})
+	if err := RebuildIndex(rootDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	builder := &flashcardBuilder{rootDir: rootDir}
+	cards, err := builder.build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if len(cards) != 4 {
+		t.Fatalf("Expected 4 cards, but got %+v", cards)
+	}
+	// 同じ名前の定理は Anki で 1 枚にまとめられないように、すべて別の ID になる
+	ids := map[string]bool{}
+	for _, card := range cards {
+		ids[card.ID] = true
+	}
+	if len(ids) != len(cards) {
+		t.Errorf("Expected distinct IDs, but got %+v", cards)
+	}
+	if cards[3].ID != flashcardID("平均値の定理") {
+		t.Errorf("Expected the ID of a unique name to depend only on the name, but got %q", cards[3].ID)
+	}
+	duplicates := 0
+	for _, warning := range builder.warnings {
+		if strings.Contains(warning, "基本定理 has the same name") {
+			duplicates++
+		}
+	}
+	if duplicates != 3 {
+		t.Errorf("Expected a warning for each duplicated theorem, but got %v", builder.warnings)
+	}
+}
+
+func TestExportFlashcards(t *testing.T) {
+	rootDir := writeTestFlashcardVault(t)
+	defer os.RemoveAll(rootDir)
+	outputPath := filepath.Join(rootDir, "out", "cards.tsv")
+
+	result, err := ExportFlashcards(rootDir, FlashcardOptions{Format: FlashcardFormatTSV, OutputPath: outputPath})
+	if err != nil {
+		t.Fatalf("ExportFlashcards failed: %v", err)
+	}
+	media := filepath.Join(rootDir, "out", "cards_media", "cover.png")
+	if !reflect.DeepEqual(result.Files, []string{outputPath, media}) {
+		t.Errorf("Unexpected files: %v", result.Files)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "証明なし") {
+		t.Errorf("Expected a warning for the theorem without proof, but got %v", result.Warnings)
+	}
+
+	content, err := ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	header := "#separator:tab\n#html:true\n#guid column:1\n#tags column:4\n"
+	if !strings.HasPrefix(content, header) {
+		t.Fatalf("Expected the Anki header, but got:\n%s", content)
+	}
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, header)))
+	r.Comma = '\t'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse TSV: %v", err)
+	}
+	if len(records) != 3 || len(records[2]) != 4 || records[2][3] != "topology::compact_space topology" {
+		t.Errorf("Unexpected records: %q", records)
+	}
+
+	if _, err := ExportFlashcards(rootDir, FlashcardOptions{Format: "apkg", OutputPath: outputPath}); err != ErrUnknownFlashcardFormat {
+		t.Errorf("Expected ErrUnknownFlashcardFormat, but got %v", err)
+	}
+}
+
+func TestExportFlashcards_MediaNameCollision(t *testing.T) {
+	rootDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	writeTestVault(t, rootDir, map[string]string{
+		"notes.md": "<theorem name=\"A\">\n![[algebra/fig.png]]\n</theorem>\n\n" +
+			"<theorem name=\"B\">\n![[topology/fig.png]]\n\n![[algebra/fig.png]]\n</theorem>\n",
+		"_images/algebra/fig.png":  "algebra",
+		"_images/topology/fig.png": "topology",
+	})
+	if err := RebuildIndex(rootDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	outputPath := filepath.Join(rootDir, "out", "cards.tsv")
+
+	result, err := ExportFlashcards(rootDir, FlashcardOptions{OutputPath: outputPath})
+	if err != nil {
+		t.Fatalf("ExportFlashcards failed: %v", err)
+	}
+	mediaDir := filepath.Join(rootDir, "out", "cards_media")
+	expectedFiles := []string{outputPath, filepath.Join(mediaDir, "fig.png"), filepath.Join(mediaDir, "fig-2.png")}
+	if !reflect.DeepEqual(result.Files, expectedFiles) {
+		t.Errorf("Expected %v, but got %v", expectedFiles, result.Files)
+	}
+	for name, want := range map[string]string{"fig.png": "algebra", "fig-2.png": "topology"} {
+		if content, _ := ReadFile(filepath.Join(mediaDir, name)); content != want {
+			t.Errorf("Expected %s to be the %s image, but got %q", name, want, content)
+		}
+	}
+
+	content, err := ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !strings.Contains(content, `src=""fig-2.png""`) || strings.Count(content, `src=""fig.png""`) != 2 {
+		t.Errorf("Expected the cards to refer to the renamed copies, but got:\n%s", content)
+	}
+}
@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+const frontmatterDelimiter = "---"
+
+// splitFrontmatter は content 先頭の YAML フロントマターを取り出します。
+// bodyOffset はフロントマターの終端の次のバイト位置です
+func splitFrontmatter(content string) (frontmatter string, bodyOffset int, ok bool) {
+	firstEnd := strings.IndexByte(content, '\n')
+	if firstEnd < 0 || strings.TrimRight(content[:firstEnd], "\r") != frontmatterDelimiter {
+		return "", 0, false
+	}
+
+	pos := firstEnd + 1
+	for pos <= len(content) {
+		end := strings.IndexByte(content[pos:], '\n')
+		line := content[pos:]
+		next := len(content)
+		if end >= 0 {
+			line = content[pos : pos+end]
+			next = pos + end + 1
+		}
+		line = strings.TrimRight(line, "\r")
+		if line == frontmatterDelimiter || line == "..." {
+			return content[firstEnd+1 : pos], next, true
+		}
+		if end < 0 {
+			break
+		}
+		pos = next
+	}
+	return "", 0, false
+}
+
+// parseFrontmatter は YAML のうち、ノートのフロントマターで使われる範囲を解釈します。
+// 値は string か []string で、入れ子になったマップは読み飛ばします
+func parseFrontmatter(frontmatter string) (map[string]any, error) {
+	values := make(map[string]any)
+	lines := strings.Split(strings.ReplaceAll(frontmatter, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// トップレベルのキーに属さないインデント行
+			continue
+		}
+
+		key, rest, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("frontmatter line %d: expected \"key: value\"", i+1)
+		}
+		key = unquoteYAML(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+
+		switch {
+		case rest == "":
+			// 後続のインデントされた "- item" をリストとして読む
+			var items []string
+			for i+1 < len(lines) {
+				next := strings.TrimSpace(lines[i+1])
+				if next == "" || strings.HasPrefix(next, "#") {
+					i++
+					continue
+				}
+				if lines[i+1][0] != ' ' && lines[i+1][0] != '\t' && !strings.HasPrefix(next, "- ") && next != "-" {
+					break
+				}
+				i++
+				if item, ok := strings.CutPrefix(next, "-"); ok {
+					items = append(items, parseYAMLScalar(item))
+				}
+			}
+			if items != nil {
+				values[key] = items
+			} else {
+				values[key] = ""
+			}
+		case rest == "|" || rest == ">" || rest == "|-" || rest == ">-":
+			var block []string
+			for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || lines[i+1][0] == ' ' || lines[i+1][0] == '\t') {
+				i++
+				block = append(block, strings.TrimSpace(lines[i]))
+			}
+			sep := "\n"
+			if rest[0] == '>' {
+				sep = " "
+			}
+			values[key] = strings.TrimSpace(strings.Join(block, sep))
+		case strings.HasPrefix(rest, "["):
+			if !strings.HasSuffix(rest, "]") {
+				return nil, fmt.Errorf("frontmatter line %d: unterminated list", i+1)
+			}
+			items := []string{}
+			for _, item := range splitYAMLFlow(rest[1 : len(rest)-1]) {
+				if item = parseYAMLScalar(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			values[key] = items
+		default:
+			values[key] = parseYAMLScalar(rest)
+		}
+	}
+	return values, nil
+}
+
+// splitYAMLFlow はフローシーケンスの中身をカンマで分割します。引用符内のカンマは区切りとみなしません
+func splitYAMLFlow(s string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		items = append(items, s[start:])
+	}
+	return items
+}
+
+func parseYAMLScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		return unquoteYAML(s)
+	}
+	// 引用符のない値では " #" 以降がコメントになる
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s[1 : len(s)-1])
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
+
+// frontmatterStrings は値を文字列のリストとして取り出します。
+// 文字列の場合はカンマまたは空白で区切られたリストとして扱います
+func frontmatterStrings(values map[string]any, key string) []string {
+	switch v := values[key].(type) {
+	case []string:
+		return v
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	}
+	return nil
+}
+
+// NoteMetadata はフロントマターから読み取ったノートのメタデータです
+type NoteMetadata struct {
+	Title   string   `json:"title"`
+	Aliases []string `json:"aliases"`
+	Authors []string `json:"authors"`
+	Status  string   `json:"status"`
+	Tags    []string `json:"tags"`
+}
+
+// ParseNoteMetadata はノートのフロントマターからメタデータを取り出します。
+// フロントマターがない場合は空のメタデータを返します
+func ParseNoteMetadata(content string) (NoteMetadata, error) {
+	metadata := NoteMetadata{
+		Aliases: []string{},
+		Authors: []string{},
+		Tags:    []string{},
+	}
+
+	fm, _, ok := splitFrontmatter(content)
+	if !ok {
+		return metadata, nil
+	}
+	values, err := parseFrontmatter(fm)
+	if err != nil {
+		return metadata, err
+	}
+
+	if title, ok := values["title"].(string); ok {
+		metadata.Title = title
+	}
+	if status, ok := values["status"].(string); ok {
+		metadata.Status = status
+	}
+	for _, key := range []string{"aliases", "alias"} {
+		metadata.Aliases = append(metadata.Aliases, frontmatterList(values, key)...)
+	}
+	for _, key := range []string{"authors", "author"} {
+		metadata.Authors = append(metadata.Authors, frontmatterList(values, key)...)
+	}
+	for _, tag := range frontmatterStrings(values, "tags") {
+		if tag = normalizeTag(tag); isValidTag(tag) {
+			metadata.Tags = append(metadata.Tags, tag)
+		}
+	}
+	return metadata, nil
+}
+
+// frontmatterList は値をリストとして取り出します。文字列の場合は一要素のリストになります
+func frontmatterList(values map[string]any, key string) []string {
+	switch v := values[key].(type) {
+	case []string:
+		return v
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNoteMetadata(t *testing.T) {
+	content := "---\n" +
+		"title: \"コンパクト空間: 定義と例\"\n" +
+		"aliases: [compact, 'Heine–Borel']\n" +
+		"authors:\n" +
+		"  - Alice\n" +
+		"  - Bob # reviewer\n" +
+		"status: draft\n" +
+		"tags: topology, exam\n" +
+		"extra:\n" +
+		"  nested: ignored\n" +
+		"---\n" +
+		"# Body\n"
+
+	metadata, err := ParseNoteMetadata(content)
+	if err != nil {
+		t.Fatalf("ParseNoteMetadata failed: %v", err)
+	}
+
+	expected := NoteMetadata{
+		Title:   "コンパクト空間: 定義と例",
+		Aliases: []string{"compact", "Heine–Borel"},
+		Authors: []string{"Alice", "Bob"},
+		Status:  "draft",
+		Tags:    []string{"topology", "exam"},
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("ParseNoteMetadata returned unexpected metadata.\nGot:  %+v\nWant: %+v", metadata, expected)
+	}
+
+	// A single author may be written as a scalar
+	metadata, err = ParseNoteMetadata("---\nauthor: Alice\nalias: cpt\n---\n")
+	if err != nil {
+		t.Fatalf("ParseNoteMetadata failed: %v", err)
+	}
+	if !reflect.DeepEqual(metadata.Authors, []string{"Alice"}) || !reflect.DeepEqual(metadata.Aliases, []string{"cpt"}) {
+		t.Errorf("Unexpected metadata for scalar values: %+v", metadata)
+	}
+
+	// Notes without frontmatter have empty metadata
+	metadata, err = ParseNoteMetadata("# Title\n---\n")
+	if err != nil {
+		t.Fatalf("ParseNoteMetadata failed: %v", err)
+	}
+	if !reflect.DeepEqual(metadata, NoteMetadata{Aliases: []string{}, Authors: []string{}, Tags: []string{}}) {
+		t.Errorf("Expected empty metadata, but got %+v", metadata)
+	}
+
+	if _, err := ParseNoteMetadata("---\ntitle: ok\nnot yaml\n---\n"); err == nil {
+		t.Errorf("Expected an error for invalid frontmatter, but got nil")
+	}
+}
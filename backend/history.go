@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	historyFileName = "history.json"
+	historyLimit    = 20
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrUndoConflict  = errors.New("file was modified after the operation")
+)
+
+// FileChange は一つのファイルに対する変更前後の内容を保持します
+type FileChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Operation は一括で取り消し可能な操作を表します
+type Operation struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Changes     []FileChange `json:"changes"`
+}
+
+func getHistoryFilePath(rootDir string) (string, error) {
+	if rootDir == "" {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(rootDir, sessionDirPath, historyFileName), nil
+}
+
+// historyFile は history.json の内容です。Operations は古い順に並びます
+type historyFile struct {
+	Version    int         `json:"version"`
+	Operations []Operation `json:"operations"`
+}
+
+func loadHistory(rootDir string) ([]Operation, error) {
+	path, err := getHistoryFilePath(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := loadSidecar(rootDir, path, historySchema)
+	if err != nil {
+		return nil, err
+	}
+	file := historyFile{Operations: []Operation{}}
+	if data == nil {
+		return file.Operations, nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Operations == nil {
+		file.Operations = []Operation{}
+	}
+	return file.Operations, nil
+}
+
+func saveHistory(rootDir string, history []Operation) error {
+	if err := ensureSessionDirExists(rootDir); err != nil {
+		return err
+	}
+
+	path, err := getHistoryFilePath(rootDir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(historyFile{Version: historySchema.version(), Operations: history}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// migrateHistoryV0 は操作の配列だけを保存していた history.json を変換します
+func migrateHistoryV0(rootDir string, data []byte) ([]byte, error) {
+	file := historyFile{Version: 1}
+	if err := json.Unmarshal(data, &file.Operations); err != nil {
+		return nil, err
+	}
+	if file.Operations == nil {
+		file.Operations = []Operation{}
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// recordOperation は操作を履歴に追加します。古い履歴は historyLimit 件を超えた分だけ破棄されます
+func recordOperation(rootDir string, description string, changes []FileChange) (Operation, error) {
+	history, err := loadHistory(rootDir)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	now := time.Now()
+	op := Operation{
+		ID:          strconv.FormatInt(now.UnixNano(), 36),
+		Description: description,
+		Timestamp:   now,
+		Changes:     changes,
+	}
+
+	history = append(history, op)
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+
+	if err := saveHistory(rootDir, history); err != nil {
+		return Operation{}, err
+	}
+	return op, nil
+}
+
+// GetHistory は取り消し可能な操作を新しい順に返します
+func GetHistory(rootDir string) ([]Operation, error) {
+	history, err := loadHistory(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Operation, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		result = append(result, history[i])
+	}
+	return result, nil
+}
+
+// UndoLastOperation は最後に記録された操作を取り消します。
+// 操作後にファイルが編集されている場合は何も変更せず ErrUndoConflict を返します
+func UndoLastOperation(rootDir string) (Operation, error) {
+	history, err := loadHistory(rootDir)
+	if err != nil {
+		return Operation{}, err
+	}
+	if len(history) == 0 {
+		return Operation{}, ErrNothingToUndo
+	}
+
+	op := history[len(history)-1]
+
+	for _, change := range op.Changes {
+		current, err := ReadFile(change.Path)
+		if err != nil {
+			return Operation{}, err
+		}
+		if current != change.After {
+			return Operation{}, ErrUndoConflict
+		}
+	}
+
+	for _, change := range op.Changes {
+		if err := WriteFile(change.Path, change.Before, rootDir); err != nil {
+			return Operation{}, err
+		}
+	}
+
+	if err := saveHistory(rootDir, history[:len(history)-1]); err != nil {
+		return Operation{}, err
+	}
+	return op, nil
+}
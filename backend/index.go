@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const indexFileName = "index.json"
+
+// IndexedTheorem はインデックスに記録される定理です
+type IndexedTheorem struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// NoteIndexEntry は一つのノートから抽出した情報を保持します
+type NoteIndexEntry struct {
+	Metadata NoteMetadata     `json:"metadata"`
+	Tags     []string         `json:"tags"`
+	Theorems []IndexedTheorem `json:"theorems"`
+}
+
+// NoteIndex はプロジェクト内のノートの情報を、ルートからの相対パス (スラッシュ区切り) をキーにして保持します
+type NoteIndex struct {
+	Files map[string]NoteIndexEntry `json:"files"`
+}
+
+// noteIndexFile は index.json の内容です
+type noteIndexFile struct {
+	Version int                       `json:"version"`
+	Files   map[string]NoteIndexEntry `json:"files"`
+}
+
+func getIndexFilePath(rootDir string) (string, error) {
+	if rootDir == "" {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(rootDir, sessionDirPath, indexFileName), nil
+}
+
+func buildIndexEntry(content string, theorems TheoremSettings) NoteIndexEntry {
+	// フロントマターが壊れていても、読み取れた範囲でインデックスに登録する
+	metadata, _ := ParseNoteMetadata(content)
+	entry := NoteIndexEntry{
+		Metadata: metadata,
+		Tags:     extractTags(content),
+		Theorems: []IndexedTheorem{},
+	}
+
+	// 定理ブロック内のタグはその定理だけに、ブロック外のタグはファイル内のすべての定理に付く
+	hashtags := findHashtags(content)
+	var blocks []*Node
+	parseMarkdown(content, theorems).Walk(func(node *Node) bool {
+		if node.Kind == NodeKindTheorem && node.Title != "" {
+			blocks = append(blocks, node)
+		}
+		return true
+	})
+	inBlock := func(span tagSpan, block *Node) bool {
+		line := strings.Count(content[:span.Start], "\n") + 1
+		return line >= block.StartLine && line <= block.EndLine
+	}
+
+	var fileTags []string
+	for _, tag := range entry.Tags {
+		outside := !slices.ContainsFunc(hashtags, func(span tagSpan) bool { return span.Tag == tag })
+		for _, span := range hashtags {
+			if span.Tag == tag && !slices.ContainsFunc(blocks, func(block *Node) bool { return inBlock(span, block) }) {
+				outside = true
+			}
+		}
+		if outside {
+			fileTags = append(fileTags, tag)
+		}
+	}
+
+	for _, block := range blocks {
+		theorem := IndexedTheorem{Name: block.Title, Tags: slices.Clone(fileTags)}
+		for _, span := range hashtags {
+			if inBlock(span, block) && !slices.Contains(theorem.Tags, span.Tag) {
+				theorem.Tags = append(theorem.Tags, span.Tag)
+			}
+		}
+		if theorem.Tags == nil {
+			theorem.Tags = []string{}
+		}
+		slices.Sort(theorem.Tags)
+		entry.Theorems = append(entry.Theorems, theorem)
+	}
+	return entry
+}
+
+func indexKey(rootDir string, path string) (string, bool) {
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// loadNoteIndex はインデックスを読み込みます。まだ作成されていない場合はプロジェクト全体から作成します。
+// インデックスはノートから作り直せるので、このアプリより新しいバージョンで書かれていた場合もエラーにせずに作り直します
+func loadNoteIndex(rootDir string) (NoteIndex, error) {
+	path, err := getIndexFilePath(rootDir)
+	if err != nil {
+		return NoteIndex{}, err
+	}
+
+	data, err := loadSidecar(rootDir, path, indexSchema)
+	if data == nil && (err == nil || errors.Is(err, ErrUnsupportedSchemaVersion)) {
+		if err := RebuildIndex(rootDir); err != nil {
+			return NoteIndex{}, err
+		}
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return NoteIndex{}, err
+	}
+
+	var index NoteIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return NoteIndex{}, err
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]NoteIndexEntry)
+	}
+	return index, nil
+}
+
+func saveNoteIndex(rootDir string, index NoteIndex) error {
+	if err := ensureSessionDirExists(rootDir); err != nil {
+		return err
+	}
+
+	path, err := getIndexFilePath(rootDir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(noteIndexFile{Version: indexSchema.version(), Files: index.Files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// updateNoteIndex は書き込まれたノートのインデックスを更新します
+func updateNoteIndex(path string, content string, rootDir string) error {
+	key, ok := indexKey(rootDir, path)
+	if !ok {
+		return nil
+	}
+
+	index, err := loadNoteIndex(rootDir)
+	if err != nil {
+		return err
+	}
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return err
+	}
+	index.Files[key] = buildIndexEntry(content, theorems)
+	return saveNoteIndex(rootDir, index)
+}
+
+// RebuildIndex はプロジェクト内のすべてのノートを読み直し、定理とインデックスを作り直します
+func RebuildIndex(rootDir string) error {
+	if rootDir == "" {
+		return os.ErrInvalid
+	}
+
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return err
+	}
+	settings, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return err
+	}
+
+	index := NoteIndex{Files: make(map[string]NoteIndexEntry)}
+	theorems := make(map[string]string)
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, _ := indexKey(rootDir, path)
+		entry := buildIndexEntry(content, settings)
+		index.Files[key] = entry
+		for _, theorem := range entry.Theorems {
+			theorems[theorem.Name] = key
+		}
+	}
+
+	if err := saveNoteIndex(rootDir, index); err != nil {
+		return err
+	}
+
+	return saveTheoremsFile(rootDir, theorems)
+}
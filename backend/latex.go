@@ -0,0 +1,504 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const latexMainFileName = "main.tex"
+
+const latexPreamble = `\documentclass{ltjsarticle}
+\usepackage{amsmath,amssymb,amsthm}
+\usepackage{graphicx}
+\usepackage[normalem]{ulem}
+\usepackage{hyperref}
+
+`
+
+// LaTeXExportOptions は LaTeX エクスポートの対象と出力先を指定します。
+// Paths が空の場合はプロジェクト内のすべてのノートを出力します
+type LaTeXExportOptions struct {
+	Paths     []string `json:"paths"`
+	OutputDir string   `json:"output_dir"`
+}
+
+type latexNote struct {
+	path string
+	rel  string
+	id   string
+	doc  *Node
+	// theorems は定理名から slug への対応です
+	theorems map[string]string
+}
+
+type latexExporter struct {
+	rootDir string
+	outDir  string
+	notes   map[string]*latexNote
+	current *latexNote
+	result  ExportResult
+	// noteLabelDone は現在のノートのラベルを出力済みかどうかです
+	noteLabelDone bool
+	bib           Bibliography
+	// macros は数式の中で展開するプロジェクトのマクロです。LaTeX の既存の命令と衝突しないように、\newcommand ではなく展開して出力します
+	macros mathMacroSet
+	// theorems は定理環境の設定です
+	theorems TheoremSettings
+	// cited は \cite で引用した文献のキーで、引用した順に thebibliography に並べます
+	cited []string
+}
+
+// ExportLaTeX はノートを LaTeX に変換し、ノートごとの .tex と、それらをまとめる main.tex を出力します。
+// <theorem> は theorem 環境に、証明の <details> は proof 環境に、[[...]] は \label と \ref の組に変換されます。
+// _images の画像は参照する .tex と同じディレクトリにコピーされます
+func ExportLaTeX(rootDir string, opts LaTeXExportOptions) (ExportResult, error) {
+	if opts.OutputDir == "" {
+		return ExportResult{}, ErrEmptyOutputDir
+	}
+	paths, err := resolveExportPaths(rootDir, opts.Paths)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	bib, err := LoadBibliography(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	macros, warnings, err := loadMathMacroSet(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	e := &latexExporter{
+		rootDir:  rootDir,
+		outDir:   opts.OutputDir,
+		notes:    make(map[string]*latexNote),
+		result:   ExportResult{Files: []string{}, Warnings: []string{}},
+		bib:      bib,
+		macros:   macros,
+		theorems: theorems,
+	}
+	e.result.Warnings = append(e.result.Warnings, warnings...)
+
+	for _, path := range paths {
+		content, err := ReadFile(path)
+		if err != nil {
+			return ExportResult{}, err
+		}
+
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		note := &latexNote{
+			path:     path,
+			rel:      filepath.ToSlash(rel),
+			id:       strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)),
+			doc:      parseMarkdown(content, theorems),
+			theorems: make(map[string]string),
+		}
+		note.doc.Walk(func(node *Node) bool {
+			if node.Kind == NodeKindTheorem {
+				note.theorems[node.Title] = node.Slug
+			}
+			return true
+		})
+		e.notes[path] = note
+	}
+
+	var main strings.Builder
+	main.WriteString(latexPreamble)
+	main.WriteString(latexTheoremDefinitions(theorems))
+	main.WriteString("\n\\begin{document}\n\n")
+	for _, path := range paths {
+		note := e.notes[path]
+		texRel := note.id + ".tex"
+		texPath := filepath.Join(e.outDir, filepath.FromSlash(texRel))
+
+		if err := os.MkdirAll(filepath.Dir(texPath), 0755); err != nil {
+			return ExportResult{}, err
+		}
+		if err := os.WriteFile(texPath, []byte(e.renderNote(note)), 0644); err != nil {
+			return ExportResult{}, err
+		}
+		e.result.Files = append(e.result.Files, texPath)
+		fmt.Fprintf(&main, "\\input{%s}\n", note.id)
+	}
+	if len(e.cited) > 0 {
+		main.WriteString("\n\\begin{thebibliography}{99}\n")
+		for _, key := range e.cited {
+			entry, _ := e.bib.Lookup(key)
+			fmt.Fprintf(&main, "\\bibitem{%s} %s\n", key, formatReferenceLaTeX(entry))
+		}
+		main.WriteString("\\end{thebibliography}\n")
+	}
+	main.WriteString("\n\\end{document}\n")
+
+	mainPath := filepath.Join(e.outDir, latexMainFileName)
+	if err := os.WriteFile(mainPath, []byte(main.String()), 0644); err != nil {
+		return ExportResult{}, err
+	}
+	e.result.Files = append(e.result.Files, mainPath)
+	return e.result, nil
+}
+
+// latexTheoremDefinitions は定理環境ごとの \newtheorem を作ります。
+// Counter が同じ環境は最初に定義した環境の番号を共有し、Counter が空の環境は番号のない \newtheorem* になります
+func latexTheoremDefinitions(theorems TheoremSettings) string {
+	var b strings.Builder
+	leaders := make(map[string]string)
+	for _, env := range theorems.Environments {
+		label := latexEscape(env.Label)
+		leader, shared := leaders[env.Counter]
+		switch {
+		case env.Counter == "":
+			fmt.Fprintf(&b, "\\newtheorem*{%s}{%s}\n", env.Tag, label)
+		case shared:
+			fmt.Fprintf(&b, "\\newtheorem{%s}[%s]{%s}\n", env.Tag, leader, label)
+		default:
+			leaders[env.Counter] = env.Tag
+			fmt.Fprintf(&b, "\\newtheorem{%s}{%s}\n", env.Tag, label)
+		}
+	}
+	fmt.Fprintf(&b, "\\renewcommand{\\proofname}{%s}\n", latexEscape(theorems.ProofLabel))
+	return b.String()
+}
+
+func (e *latexExporter) warn(format string, args ...any) {
+	e.result.Warnings = append(e.result.Warnings, e.current.rel+": "+fmt.Sprintf(format, args...))
+}
+
+func (e *latexExporter) renderNote(note *latexNote) string {
+	e.current = note
+	e.noteLabelDone = false
+
+	body := e.blocks(note.doc.Children, false)
+	if !e.noteLabelDone {
+		body = latexLabel("note", note.id) + "\n" + body
+	}
+	return "% " + note.rel + "\n" + body
+}
+
+// latexLabel は \label と \ref で使える名前を作ります
+func latexLabel(kind string, parts ...string) string {
+	name := kind + ":" + strings.Join(parts, ":")
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', '{', '}', '%', '#', ',', '~', '$', '&', '^', ' ', '\t':
+			return '-'
+		}
+		return r
+	}, name)
+	return `\label{` + name + `}`
+}
+
+func latexRef(label string) string {
+	return strings.Replace(label, `\label{`, `\ref{`, 1)
+}
+
+var latexSectionCommands = []string{`\section`, `\subsection`, `\subsubsection`, `\paragraph`, `\subparagraph`, `\subparagraph`}
+
+// blocks はブロック要素を変換します。inEnv が true の場合は theorem などの環境の中なので、見出しを節にしません
+func (e *latexExporter) blocks(nodes []*Node, inEnv bool) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if block := e.block(node, inEnv); block != "" {
+			parts = append(parts, block)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+func (e *latexExporter) block(node *Node, inEnv bool) string {
+	switch node.Kind {
+	case NodeKindParagraph:
+		return e.inlines(node.Children)
+
+	case NodeKindHeading:
+		title := e.inlines(node.Children)
+		if inEnv {
+			// [[note#主張]] のような環境の中の見出しへのリンクも \ref で参照できるようにラベルを付ける
+			return `\textbf{` + title + `}` + latexLabel("sec", e.current.id, node.Slug) + `\par`
+		}
+		heading := latexSectionCommands[node.Level-1] + "{" + title + "}" + latexLabel("sec", e.current.id, node.Slug)
+		if !e.noteLabelDone {
+			heading += latexLabel("note", e.current.id)
+			e.noteLabelDone = true
+		}
+		return heading
+
+	case NodeKindThematicBreak:
+		return `\noindent\rule{\linewidth}{0.4pt}`
+
+	case NodeKindBlockquote:
+		return "\\begin{quote}\n" + e.blocks(node.Children, true) + `\end{quote}`
+
+	case NodeKindList:
+		return e.list(node)
+
+	case NodeKindCodeBlock:
+		return "\\begin{verbatim}\n" + node.Literal + "\n\\end{verbatim}"
+
+	case NodeKindMathBlock:
+		return "\\[\n" + e.macros.expand(node.Literal) + "\n\\]"
+
+	case NodeKindMermaid:
+		e.warn("mermaid diagram was omitted")
+		return "% mermaid diagram omitted"
+
+	case NodeKindCardLink:
+		title := node.Attrs["title"]
+		if title == "" {
+			title = node.Attrs["url"]
+		}
+		return `\href{` + latexURL(node.Attrs["url"]) + "}{" + latexEscape(title) + "}"
+
+	case NodeKindTable:
+		return e.table(node)
+
+	case NodeKindHTMLBlock:
+		e.warn("HTML block at line %d was omitted", node.StartLine)
+		return ""
+
+	case NodeKindTheorem:
+		begin := `\begin{` + node.Environment + `}`
+		if node.Title != "" {
+			begin += "[" + latexEscape(node.Title) + "]"
+		}
+		return begin + latexLabel("thm", e.current.id, node.Slug) + "\n" + e.blocks(node.Children, true) + `\end{` + node.Environment + `}`
+
+	case NodeKindDetails:
+		if isProofSummary(node.Title, e.theorems) {
+			return "\\begin{proof}\n" + e.blocks(node.Children, true) + `\end{proof}`
+		}
+		return `\paragraph{` + latexEscape(node.Title) + "}\n" + e.blocks(node.Children, true)
+	}
+	return ""
+}
+
+func (e *latexExporter) list(node *Node) string {
+	env := "itemize"
+	var b strings.Builder
+	if node.Ordered {
+		env = "enumerate"
+	}
+	b.WriteString(`\begin{` + env + "}\n")
+	if node.Ordered && node.Start > 1 {
+		b.WriteString(`\setcounter{enumi}{` + strconv.Itoa(node.Start-1) + "}\n")
+	}
+	for _, item := range node.Children {
+		b.WriteString(`\item`)
+		if item.Task {
+			if item.Checked {
+				b.WriteString(`[$\boxtimes$]`)
+			} else {
+				b.WriteString(`[$\square$]`)
+			}
+		}
+		parts := make([]string, 0, len(item.Children))
+		for _, child := range item.Children {
+			if block := e.block(child, true); block != "" {
+				parts = append(parts, block)
+			}
+		}
+		b.WriteString(" " + strings.Join(parts, "\n") + "\n")
+	}
+	b.WriteString(`\end{` + env + "}")
+	return b.String()
+}
+
+func (e *latexExporter) table(node *Node) string {
+	var spec strings.Builder
+	for _, cell := range node.Children[0].Children {
+		switch cell.Align {
+		case "center":
+			spec.WriteString("c")
+		case "right":
+			spec.WriteString("r")
+		default:
+			spec.WriteString("l")
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`\begin{tabular}{` + spec.String() + "}\n\\hline\n")
+	for i, row := range node.Children {
+		cells := make([]string, len(row.Children))
+		for j, cell := range row.Children {
+			cells[j] = e.inlines(cell.Children)
+		}
+		b.WriteString(strings.Join(cells, " & ") + " \\\\\n")
+		if i == 0 {
+			b.WriteString("\\hline\n")
+		}
+	}
+	b.WriteString("\\hline\n\\end{tabular}")
+	return b.String()
+}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`^`, `\textasciicircum{}`,
+	`_`, `\_`,
+	`%`, `\%`,
+	`~`, `\textasciitilde{}`,
+)
+
+func latexEscape(s string) string {
+	return latexEscaper.Replace(s)
+}
+
+func latexURL(url string) string {
+	return strings.NewReplacer(`\`, `/`, `%`, `\%`, `#`, `\#`, `{`, `\{`, `}`, `\}`).Replace(url)
+}
+
+func (e *latexExporter) inlines(nodes []*Node) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		b.WriteString(e.inline(node))
+	}
+	return b.String()
+}
+
+// inline はインライン要素を変換します。数式はそのまま出力します
+func (e *latexExporter) inline(node *Node) string {
+	switch node.Kind {
+	case NodeKindText:
+		return e.text(node.Literal)
+	case NodeKindBreak:
+		// remark-breaks と同じく、段落内の改行はそのまま改行にする
+		return "\\\\\n"
+	case NodeKindEmphasis:
+		return `\emph{` + e.inlines(node.Children) + "}"
+	case NodeKindStrong:
+		return `\textbf{` + e.inlines(node.Children) + "}"
+	case NodeKindDelete:
+		return `\sout{` + e.inlines(node.Children) + "}"
+	case NodeKindCode:
+		return `\texttt{` + latexEscape(node.Literal) + "}"
+	case NodeKindMath:
+		if node.Display {
+			return `\[` + e.macros.expand(node.Literal) + `\]`
+		}
+		return "$" + e.macros.expand(node.Literal) + "$"
+	case NodeKindLink:
+		if len(node.Children) == 1 && node.Children[0].Kind == NodeKindText && node.Children[0].Literal == node.Destination {
+			return `\url{` + latexURL(node.Destination) + "}"
+		}
+		return `\href{` + latexURL(node.Destination) + "}{" + e.inlines(node.Children) + "}"
+	case NodeKindImage:
+		return e.image(node.Destination, false)
+	case NodeKindEmbed:
+		return e.image(node.Destination, true)
+	case NodeKindWikiLink:
+		return e.wikiLink(node)
+	case NodeKindHTMLInline:
+		if strings.HasPrefix(node.Literal, "<br") {
+			return "\\\\\n"
+		}
+	}
+	return ""
+}
+
+// text はテキストを変換します。.bib にある文献の [@key] は \cite にし、ない場合は警告してそのまま残します
+func (e *latexExporter) text(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range citationRegexp.FindAllStringSubmatchIndex(s, -1) {
+		citations := parseCitationGroup(s[m[2]:m[3]], 0)
+		known := len(citations) > 0
+		for i, citation := range citations {
+			entry, ok := e.bib.Lookup(citation.Key)
+			if !ok {
+				e.warn("unknown citation key %s", citation.Key)
+				known = false
+				continue
+			}
+			citations[i].Key = entry.Key
+		}
+		if !known {
+			continue
+		}
+		for _, citation := range citations {
+			if !slices.Contains(e.cited, citation.Key) {
+				e.cited = append(e.cited, citation.Key)
+			}
+		}
+		b.WriteString(latexEscape(s[last:m[0]]))
+		b.WriteString(citeCommand(citations))
+		last = m[1]
+	}
+	b.WriteString(latexEscape(s[last:]))
+	return b.String()
+}
+
+// wikiLink は [[path#heading|表示名]] を、エクスポート対象のノートへの \ref に変換します。
+// 見出しがある場合は節か定理を、表示名が対象ノートの定理名と一致する場合は定理を、それ以外はノートを参照します。
+// ラベルのない証明などの折りたたみへのリンクはノートを参照します
+func (e *latexExporter) wikiLink(node *Node) string {
+	display := e.inlines(node.Children)
+	path, err := resolveLinkPath(e.rootDir, node.Destination)
+	note, ok := e.notes[path]
+	if err != nil || !ok {
+		e.warn("link [[%s]] does not point to an exported note", node.Literal)
+		return display
+	}
+
+	label := latexLabel("note", note.id)
+	if node.Fragment != "" {
+		if item, ok := findOutlineHeading(buildOutline(note.doc.Children, note.doc.EndLine, e.theorems), node.Fragment); ok {
+			switch item.Kind {
+			case OutlineKindHeading:
+				label = latexLabel("sec", note.id, item.Slug)
+			case OutlineKindTheorem:
+				label = latexLabel("thm", note.id, item.Slug)
+			}
+		}
+	} else if slug, ok := note.theorems[strings.TrimSpace(node.Children[0].Literal)]; ok {
+		label = latexLabel("thm", note.id, slug)
+	}
+	return display + "~" + latexRef(label)
+}
+
+// image は画像をコピーし、\includegraphics に変換します。
+// ![[name]] の画像は _images から探し、それ以外はノートからの相対パスとして扱います
+func (e *latexExporter) image(src string, embed bool) string {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		e.warn("remote image %s was not downloaded", src)
+		return `\url{` + latexURL(src) + "}"
+	}
+
+	src, _, _ = strings.Cut(src, "|")
+	srcPath := filepath.Join(filepath.Dir(e.current.path), filepath.FromSlash(src))
+	if embed {
+		srcPath = imagePath(e.rootDir, src)
+	}
+	if _, err := os.Stat(srcPath); err != nil {
+		e.warn("image %s was not found", src)
+		return ""
+	}
+
+	relDir := filepath.Dir(filepath.FromSlash(e.current.rel))
+	dst := filepath.Join(e.outDir, relDir, filepath.Base(srcPath))
+	if err := copyFile(srcPath, dst); err != nil {
+		e.warn("failed to copy image %s: %v", src, err)
+	}
+	ref := filepath.ToSlash(filepath.Join(relDir, filepath.Base(srcPath)))
+	return `\includegraphics[width=0.8\linewidth]{` + ref + "}"
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportLaTeX(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outDir := filepath.Join(tmpDir, "out")
+	rootDir := filepath.Join(tmpDir, "vault")
+	files := map[string]string{
+		"topology/compact.md": "---\ntitle: コンパクト\n---\n" +
+			"# コンパクト性\n\n" +
+			"<theorem name=\"ハイネ・ボレル\">\n" +
+			"### 主張\n" +
+			"$[0, 1]$ はコンパクト & 有界 50%\n" +
+			"</theorem>\n\n" +
+			"<details>\n<summary>証明</summary>\n\n**明らか**\n</details>\n\n" +
+			"![[figure.png]]\n",
+		"analysis.md": "# 解析\n\n" +
+			"[[topology/compact|ハイネ・ボレル]] と [[topology/compact#コンパクト性]] と [[missing]]\n\n" +
+			"[[topology/compact#主張]] と [[topology/compact#証明]]\n\n" +
+			"- a\n  - b\n- c\n\n" +
+			"$$\nx^2\n$$\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write note: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(rootDir, imagesDirName), 0755); err != nil {
+		t.Fatalf("Failed to create images dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, imagesDirName, "figure.png"), []byte("png"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	result, err := ExportLaTeX(rootDir, LaTeXExportOptions{OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("ExportLaTeX failed: %v", err)
+	}
+	if len(result.Files) != 3 {
+		t.Errorf("Expected 3 files, but got %v", result.Files)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "[[missing]]") {
+		t.Errorf("Expected a warning for the missing link, but got %v", result.Warnings)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	main := read("main.tex")
+	for _, want := range []string{`\newtheorem{theorem}{定理}`, `\input{analysis}`, `\input{topology/compact}`} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main.tex does not contain %q:\n%s", want, main)
+		}
+	}
+
+	compact := read("topology/compact.tex")
+	for _, want := range []string{
+		`\section{コンパクト性}\label{sec:topology/compact:コンパクト性}\label{note:topology/compact}`,
+		`\begin{theorem}[ハイネ・ボレル]\label{thm:topology/compact:ハイネボレル}`,
+		`\textbf{主張}\label{sec:topology/compact:主張}\par`,
+		`$[0, 1]$ はコンパクト \& 有界 50\%`,
+		"\\begin{proof}\n\\textbf{明らか}\n\\end{proof}",
+		`\includegraphics[width=0.8\linewidth]{topology/figure.png}`,
+	} {
+		if !strings.Contains(compact, want) {
+			t.Errorf("compact.tex does not contain %q:\n%s", want, compact)
+		}
+	}
+	if strings.Contains(compact, "title: コンパクト") {
+		t.Errorf("Frontmatter should not be exported:\n%s", compact)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "topology", "figure.png")); err != nil {
+		t.Errorf("Image was not copied: %v", err)
+	}
+
+	analysis := read("analysis.tex")
+	for _, want := range []string{
+		`ハイネ・ボレル~\ref{thm:topology/compact:ハイネボレル}`,
+		`topology/compact\#コンパクト性~\ref{sec:topology/compact:コンパクト性}`,
+		`topology/compact\#主張~\ref{sec:topology/compact:主張}`,
+		`topology/compact\#証明~\ref{note:topology/compact}`,
+		" と missing",
+		"\\begin{itemize}\n\\item a\n\\begin{itemize}\n\\item b\n\\end{itemize}\n\\item c\n\\end{itemize}",
+		"\\[\nx^2\n\\]",
+	} {
+		if !strings.Contains(analysis, want) {
+			t.Errorf("analysis.tex does not contain %q:\n%s", want, analysis)
+		}
+	}
+}
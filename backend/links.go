@@ -0,0 +1,202 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrLinkNotFound = errors.New("link target not found")
+
+// ResolvedLink は [[...]] リンクの解決結果です。Path は絶対パスです。
+// 見出しが指定され、ノート内に見つかった場合は Slug と Line (1 始まり) が設定されます
+type ResolvedLink struct {
+	Path    string `json:"path"`
+	Heading string `json:"heading"`
+	Slug    string `json:"slug"`
+	Line    int    `json:"line"`
+}
+
+// parseLinkTarget は [[path#heading|表示名]] の中身からパスと見出しを取り出します
+func parseLinkTarget(target string) (string, string) {
+	target, _, _ = strings.Cut(target, "|")
+	path, heading, _ := strings.Cut(target, "#")
+	return strings.TrimSpace(path), strings.TrimSpace(heading)
+}
+
+// ResolveLink は [[...]] の中身をノートのパスに解決します。
+// ルートからの相対パス (拡張子 .md は省略可) を優先し、見つからない場合はフロントマターの aliases から探します
+func ResolveLink(rootDir string, target string) (ResolvedLink, error) {
+	if rootDir == "" {
+		return ResolvedLink{}, os.ErrInvalid
+	}
+
+	linkPath, heading := parseLinkTarget(target)
+	if linkPath == "" {
+		return ResolvedLink{}, ErrLinkNotFound
+	}
+
+	path, err := resolveLinkPath(rootDir, linkPath)
+	if err != nil {
+		return ResolvedLink{}, err
+	}
+
+	link := ResolvedLink{Path: path, Heading: heading}
+	if heading == "" {
+		return link, nil
+	}
+	outline, err := GetDocumentOutline(path, rootDir)
+	if err != nil {
+		return ResolvedLink{}, err
+	}
+	if item, ok := findOutlineHeading(outline, heading); ok {
+		link.Slug = item.Slug
+		link.Line = item.StartLine
+	}
+	return link, nil
+}
+
+func resolveLinkPath(rootDir string, linkPath string) (string, error) {
+	if path, ok := findLinkedFile(rootDir, linkPath); ok {
+		return path, nil
+	}
+	index, err := loadNoteIndex(rootDir)
+	if err != nil {
+		return "", err
+	}
+	return resolveAlias(rootDir, index, linkPath)
+}
+
+// findLinkedFile は linkPath をルートからの相対パスとして、拡張子を省略した場合も含めてノートを探します
+func findLinkedFile(rootDir string, linkPath string) (string, bool) {
+	candidate := filepath.Join(rootDir, filepath.FromSlash(linkPath))
+	for _, path := range []string{candidate, candidate + ".md"} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// resolveAlias は index から linkPath をエイリアスに持つノートを探します。複数ある場合はパスの順で最初のノートです
+func resolveAlias(rootDir string, index NoteIndex, linkPath string) (string, error) {
+	keys := make([]string, 0, len(index.Files))
+	for key := range index.Files {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, alias := range index.Files[key].Metadata.Aliases {
+			if strings.EqualFold(alias, linkPath) {
+				return filepath.Join(rootDir, filepath.FromSlash(key)), nil
+			}
+		}
+	}
+	return "", ErrLinkNotFound
+}
+
+// BrokenLink は解決できなかった [[...]] リンクまたは ![[...]] の埋め込みです。
+// Path はリンクを含むノートのルートからの相対パス (スラッシュ区切り) で、Line は 1 始まりです
+type BrokenLink struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
+const (
+	BrokenLinkNoteNotFound    = "note not found"
+	BrokenLinkHeadingNotFound = "heading not found"
+	BrokenLinkImageNotFound   = "image not found"
+)
+
+// CheckLinks はプロジェクト内のすべてのノートのリンクと埋め込みを解決し、リンク先のノート、見出し、
+// 画像が見つからないものを返します。コードや数式の中は対象外です。
+// エイリアスは保存されたインデックスではなくノートから読み取り、プロジェクトのファイルは変更しません
+func CheckLinks(rootDir string) ([]BrokenLink, error) {
+	if rootDir == "" {
+		return nil, os.ErrInvalid
+	}
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]*Node)
+	index := NoteIndex{Files: make(map[string]NoteIndexEntry)}
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		docs[path] = parseMarkdown(content, theorems)
+		key, _ := indexKey(rootDir, path)
+		index.Files[key] = buildIndexEntry(content, theorems)
+	}
+
+	broken := []BrokenLink{}
+	for _, path := range files {
+		doc := docs[path]
+		rel, _ := indexKey(rootDir, path)
+
+		var links []*Node
+		var lines []int
+		line := 0
+		doc.Walk(func(node *Node) bool {
+			if node.StartLine > 0 {
+				line = node.StartLine
+			}
+			if node.Kind == NodeKindWikiLink || node.Kind == NodeKindEmbed {
+				links = append(links, node)
+				lines = append(lines, line)
+			}
+			return true
+		})
+
+		for i, node := range links {
+			report := func(reason string) {
+				broken = append(broken, BrokenLink{Path: rel, Line: lines[i], Target: node.Literal, Reason: reason})
+			}
+			if node.Kind == NodeKindEmbed {
+				if _, err := os.Stat(imagePath(rootDir, node.Destination)); err != nil {
+					report(BrokenLinkImageNotFound)
+				}
+				continue
+			}
+
+			target := path
+			if node.Destination != "" {
+				var ok bool
+				if target, ok = findLinkedFile(rootDir, node.Destination); !ok {
+					if target, err = resolveAlias(rootDir, index, node.Destination); err != nil {
+						report(BrokenLinkNoteNotFound)
+						continue
+					}
+				}
+			}
+			if node.Fragment == "" {
+				continue
+			}
+			targetDoc, ok := docs[target]
+			if !ok {
+				// .md 以外の拡張子のファイルなど、一覧にないリンク先はここで読む
+				content, err := ReadFile(target)
+				if err != nil {
+					return nil, err
+				}
+				targetDoc = parseMarkdown(content, theorems)
+				docs[target] = targetDoc
+			}
+			if _, ok := findOutlineHeading(buildOutline(targetDoc.Children, targetDoc.EndLine, theorems), node.Fragment); !ok {
+				report(BrokenLinkHeadingNotFound)
+			}
+		}
+	}
+	return broken, nil
+}
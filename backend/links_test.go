@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveLink(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "topology"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	filePath := filepath.Join(tmpDir, "topology", "compact.md")
+	if err := WriteFile(filePath, "---\naliases: [コンパクト, Compactness]\n---\n# 定義\n", tmpDir); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	tests := []struct {
+		target  string
+		heading string
+	}{
+		{"topology/compact.md", ""},
+		{"topology/compact", ""},
+		{"topology/compact#定義|コンパクト性", "定義"},
+		{"コンパクト", ""},
+		{"compactness#定義", "定義"},
+	}
+	for _, tt := range tests {
+		link, err := ResolveLink(tmpDir, tt.target)
+		if err != nil {
+			t.Errorf("ResolveLink(%q) failed: %v", tt.target, err)
+			continue
+		}
+		if link.Path != filePath || link.Heading != tt.heading {
+			t.Errorf("ResolveLink(%q) = %+v, want path %s and heading %q", tt.target, link, filePath, tt.heading)
+		}
+	}
+
+	if _, err := ResolveLink(tmpDir, "missing"); err != ErrLinkNotFound {
+		t.Errorf("Expected ErrLinkNotFound, but got %v", err)
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeTestVault(t, tmpDir, map[string]string{
+		"a.md": "# 定義\n\n" +
+			"[[b]] [[b#性質]] [[b#なし]] [[#定義]] [[#ない見出し]]\n\n" +
+			"- [[missing]]\n\n" +
+			"`[[コードの中]]` ![[figure.png]] ![[none.png]]\n",
+		"b.md":               "---\naliases: [ビー]\n---\n## 性質\n\n[[ビー]]\n",
+		"_images/figure.png": "png",
+	})
+	if err := RebuildIndex(tmpDir); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	broken, err := CheckLinks(tmpDir)
+	if err != nil {
+		t.Fatalf("CheckLinks failed: %v", err)
+	}
+	expected := []BrokenLink{
+		{Path: "a.md", Line: 3, Target: "b#なし", Reason: BrokenLinkHeadingNotFound},
+		{Path: "a.md", Line: 3, Target: "#ない見出し", Reason: BrokenLinkHeadingNotFound},
+		{Path: "a.md", Line: 5, Target: "missing", Reason: BrokenLinkNoteNotFound},
+		{Path: "a.md", Line: 7, Target: "none.png", Reason: BrokenLinkImageNotFound},
+	}
+	if !reflect.DeepEqual(broken, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, broken)
+	}
+}
@@ -0,0 +1,524 @@
+package backend
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	macrosFileName = "macros.tex"
+	// macrosConfigKey は config.json で {"\\R": "\\mathbb{R}"} の形でマクロを定義するキーです
+	macrosConfigKey = "macros"
+
+	// maxMacroDepth はマクロの展開の入れ子の上限で、これを超えるマクロは再帰しているとみなします
+	maxMacroDepth = 32
+)
+
+var macroNameRegexp = regexp.MustCompile(`^\\[A-Za-z]+$`)
+
+// MathMacro はプロジェクトで定義した数式のマクロです。Body の #1 から #Args は引数に置き換えられます。
+// Source は定義したファイル (macros.tex か config.json) で、Line は macros.tex での行番号です
+type MathMacro struct {
+	Name   string `json:"name"`
+	Body   string `json:"body"`
+	Args   int    `json:"args"`
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
+
+// MacroIssue はマクロの定義の問題です。問題のある定義は使いません
+type MacroIssue struct {
+	Source  string `json:"source"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// MathMacros はプロジェクトのマクロの一覧です。Macros は名前の順に並びます
+type MathMacros struct {
+	Macros []MathMacro  `json:"macros"`
+	Issues []MacroIssue `json:"issues"`
+}
+
+// LoadMathMacros は .theorem-note/macros.tex と config.json の "macros" からマクロを読み込みます。
+// macros.tex には \newcommand、\renewcommand、\providecommand、\def、\DeclareMathOperator を書けます。
+// 同じ名前のマクロが両方にある場合は config.json の定義を使います
+func LoadMathMacros(rootDir string) (MathMacros, error) {
+	if rootDir == "" {
+		return MathMacros{}, os.ErrInvalid
+	}
+	macros := MathMacros{Macros: []MathMacro{}, Issues: []MacroIssue{}}
+	defined := make(map[string]MathMacro)
+
+	content, err := os.ReadFile(filepath.Join(rootDir, projectConfigDir, macrosFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return MathMacros{}, err
+	}
+	fileMacros, issues := parseMacrosTeX(string(content))
+	macros.Issues = append(macros.Issues, issues...)
+	for _, macro := range fileMacros {
+		defined[macro.Name] = macro
+	}
+
+	layer, err := loadProjectSettingsLayer(rootDir)
+	if err != nil {
+		return MathMacros{}, err
+	}
+	configMacros, issues := parseConfigMacros(layer[macrosConfigKey])
+	macros.Issues = append(macros.Issues, issues...)
+	for _, macro := range configMacros {
+		if previous, ok := defined[macro.Name]; ok {
+			macros.Issues = append(macros.Issues, MacroIssue{
+				Source:  projectConfigFile,
+				Message: fmt.Sprintf("%s is also defined in %s:%d; the definition in %s is used", macro.Name, macrosFileName, previous.Line, projectConfigFile),
+			})
+		}
+		defined[macro.Name] = macro
+	}
+
+	// 再帰するマクロは展開が終わらないので使わない
+	set := mathMacroSet(defined)
+	for _, name := range slices.Sorted(maps.Keys(defined)) {
+		macro := defined[name]
+		if _, ok := set.expandTokens(splitTeX(macro.Body), 1); !ok {
+			macros.Issues = append(macros.Issues, MacroIssue{Source: macro.Source, Line: macro.Line, Message: macro.Name + " expands recursively"})
+			delete(defined, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(defined)) {
+		macros.Macros = append(macros.Macros, defined[name])
+	}
+	return macros, nil
+}
+
+// parseConfigMacros は config.json の "macros" を読みます。引数の数は本体に現れる最大の #n です
+func parseConfigMacros(value any) ([]MathMacro, []MacroIssue) {
+	var macros []MathMacro
+	var issues []MacroIssue
+	if value == nil {
+		return macros, issues
+	}
+	definitions, ok := value.(map[string]any)
+	if !ok {
+		return macros, append(issues, MacroIssue{Source: projectConfigFile, Message: `"macros" must be an object`})
+	}
+	for _, name := range slices.Sorted(maps.Keys(definitions)) {
+		body, ok := definitions[name].(string)
+		if !ok {
+			issues = append(issues, MacroIssue{Source: projectConfigFile, Message: name + ": the definition must be a string"})
+			continue
+		}
+		macro := MathMacro{Name: name, Body: body, Args: macroParameterCount(body), Source: projectConfigFile}
+		if err := validateMacro(macro); err != nil {
+			issues = append(issues, MacroIssue{Source: projectConfigFile, Message: err.Error()})
+			continue
+		}
+		macros = append(macros, macro)
+	}
+	return macros, issues
+}
+
+// parseMacrosTeX は macros.tex のマクロの定義を読みます。解釈できない行は問題として報告して読み飛ばします
+func parseMacrosTeX(content string) ([]MathMacro, []MacroIssue) {
+	var macros []MathMacro
+	var issues []MacroIssue
+	s := &macroScanner{src: stripTeXComments(content)}
+	for s.skipSpace(); s.pos < len(s.src); s.skipSpace() {
+		line := s.line()
+		command := s.command()
+		macro, err := s.definition(command)
+		if err == nil {
+			macro.Source = macrosFileName
+			macro.Line = line
+			err = validateMacro(macro)
+		}
+		if err != nil {
+			issues = append(issues, MacroIssue{Source: macrosFileName, Line: line, Message: err.Error()})
+			s.skipLine()
+			continue
+		}
+		macros = append(macros, macro)
+	}
+	return macros, issues
+}
+
+// stripTeXComments は % から行末までを取り除きます。\% はコメントではありません
+func stripTeXComments(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if line[j] == '%' {
+				lines[i] = line[:j]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type macroScanner struct {
+	src string
+	pos int
+}
+
+func (s *macroScanner) line() int {
+	return strings.Count(s.src[:s.pos], "\n") + 1
+}
+
+func (s *macroScanner) skipSpace() {
+	for s.pos < len(s.src) && strings.ContainsRune(" \t\r\n", rune(s.src[s.pos])) {
+		s.pos++
+	}
+}
+
+func (s *macroScanner) skipLine() {
+	if end := strings.IndexByte(s.src[s.pos:], '\n'); end >= 0 {
+		s.pos += end + 1
+	} else {
+		s.pos = len(s.src)
+	}
+}
+
+// command は現在の位置の \name を読みます。命令でない場合は空文字列を返し、位置は変えません
+func (s *macroScanner) command() string {
+	if s.pos >= len(s.src) || s.src[s.pos] != '\\' {
+		return ""
+	}
+	end := s.pos + 1
+	for end < len(s.src) && isASCIILetter(s.src[end]) {
+		end++
+	}
+	if end == s.pos+1 {
+		return ""
+	}
+	name := s.src[s.pos:end]
+	s.pos = end
+	return name
+}
+
+// group は {...} の中身を読みます。中の波括弧は対応している必要があります
+func (s *macroScanner) group() (string, error) {
+	s.skipSpace()
+	if s.pos >= len(s.src) || s.src[s.pos] != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	depth := 0
+	for i := s.pos; i < len(s.src); i++ {
+		switch s.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				body := s.src[s.pos+1 : i]
+				s.pos = i + 1
+				return body, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unbalanced braces")
+}
+
+// optional は [...] があれば中身を読みます
+func (s *macroScanner) optional() (string, bool) {
+	s.skipSpace()
+	if s.pos >= len(s.src) || s.src[s.pos] != '[' {
+		return "", false
+	}
+	end := strings.IndexByte(s.src[s.pos:], ']')
+	if end < 0 {
+		return "", false
+	}
+	value := s.src[s.pos+1 : s.pos+end]
+	s.pos += end + 1
+	return value, true
+}
+
+// macroName は \newcommand{\name} と \newcommand\name のどちらの形でも名前を読みます
+func (s *macroScanner) macroName() (string, error) {
+	s.skipSpace()
+	if s.pos < len(s.src) && s.src[s.pos] == '{' {
+		inner, err := s.group()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(inner), nil
+	}
+	if name := s.command(); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("expected a macro name")
+}
+
+func (s *macroScanner) definition(command string) (MathMacro, error) {
+	switch command {
+	case `\newcommand`, `\renewcommand`, `\providecommand`:
+		if strings.HasPrefix(s.src[s.pos:], "*") {
+			s.pos++
+		}
+		name, err := s.macroName()
+		if err != nil {
+			return MathMacro{}, err
+		}
+		args := 0
+		if value, ok := s.optional(); ok {
+			if args, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || args < 0 || args > 9 {
+				return MathMacro{}, fmt.Errorf("%s: the number of arguments must be between 0 and 9", name)
+			}
+		}
+		if _, ok := s.optional(); ok {
+			return MathMacro{}, fmt.Errorf("%s: optional arguments are not supported", name)
+		}
+		body, err := s.group()
+		if err != nil {
+			return MathMacro{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return MathMacro{Name: name, Body: body, Args: args}, nil
+
+	case `\def`:
+		name := s.command()
+		if name == "" {
+			return MathMacro{}, fmt.Errorf("expected a macro name")
+		}
+		args := 0
+		for s.pos+1 < len(s.src) && s.src[s.pos] == '#' && s.src[s.pos+1] == byte('1'+args) {
+			args++
+			s.pos += 2
+		}
+		body, err := s.group()
+		if err != nil {
+			return MathMacro{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return MathMacro{Name: name, Body: body, Args: args}, nil
+
+	case `\DeclareMathOperator`:
+		operator := `\operatorname`
+		if strings.HasPrefix(s.src[s.pos:], "*") {
+			operator += "*"
+			s.pos++
+		}
+		name, err := s.macroName()
+		if err != nil {
+			return MathMacro{}, err
+		}
+		text, err := s.group()
+		if err != nil {
+			return MathMacro{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return MathMacro{Name: name, Body: operator + "{" + text + "}"}, nil
+
+	case "":
+		return MathMacro{}, fmt.Errorf("expected a macro definition")
+	}
+	return MathMacro{}, fmt.Errorf("%s is not a macro definition", command)
+}
+
+// validateMacro はマクロの名前が \ と英字からなり、本体の波括弧が対応していて、#n が引数の数を超えないことを確かめます
+func validateMacro(macro MathMacro) error {
+	if !macroNameRegexp.MatchString(macro.Name) {
+		return fmt.Errorf("%q is not a valid macro name", macro.Name)
+	}
+	depth := 0
+	tokens := splitTeX(macro.Body)
+	for i, tok := range tokens {
+		switch tok {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case "#":
+			n := 0
+			if i+1 < len(tokens) {
+				n, _ = strconv.Atoi(tokens[i+1])
+			}
+			if n < 1 || n > macro.Args {
+				return fmt.Errorf("%s: # must be followed by an argument number between 1 and %d", macro.Name, macro.Args)
+			}
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%s: unbalanced braces", macro.Name)
+	}
+	return nil
+}
+
+// macroParameterCount は本体に現れる最大の #n を返します
+func macroParameterCount(body string) int {
+	count := 0
+	tokens := splitTeX(body)
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i] == "#" {
+			if n, err := strconv.Atoi(tokens[i+1]); err == nil && n <= 9 {
+				count = max(count, n)
+			}
+		}
+	}
+	return count
+}
+
+// mathMacroSet は名前からマクロへの対応で、書き出しの際に数式のマクロを展開します
+type mathMacroSet map[string]MathMacro
+
+// loadMathMacroSet は書き出しで使うマクロを読み込みます。warnings はマクロの定義の問題です
+func loadMathMacroSet(rootDir string) (mathMacroSet, []string, error) {
+	macros, err := LoadMathMacros(rootDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	set := make(mathMacroSet, len(macros.Macros))
+	for _, macro := range macros.Macros {
+		set[macro.Name] = macro
+	}
+	var warnings []string
+	for _, issue := range macros.Issues {
+		if issue.Line > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", issue.Source, issue.Line, issue.Message))
+		} else {
+			warnings = append(warnings, issue.Source+": "+issue.Message)
+		}
+	}
+	return set, warnings, nil
+}
+
+// expand は tex の中のマクロを展開します。KaTeX 以外の出力でも、プレビューと同じ数式になるようにします
+func (m mathMacroSet) expand(tex string) string {
+	if len(m) == 0 || !strings.Contains(tex, `\`) {
+		return tex
+	}
+	tokens, ok := m.expandTokens(splitTeX(tex), 0)
+	if !ok {
+		return tex
+	}
+	return joinTeX(tokens)
+}
+
+func (m mathMacroSet) expandTokens(tokens []string, depth int) ([]string, bool) {
+	var out []string
+	for i := 0; i < len(tokens); i++ {
+		macro, ok := m[tokens[i]]
+		if !ok {
+			out = append(out, tokens[i])
+			continue
+		}
+		if depth >= maxMacroDepth {
+			return nil, false
+		}
+
+		args := make([][]string, macro.Args)
+		j := i + 1
+		for k := range args {
+			for j < len(tokens) && strings.TrimSpace(tokens[j]) == "" {
+				j++
+			}
+			if j >= len(tokens) {
+				break
+			}
+			if tokens[j] == "{" {
+				end := matchingBrace(tokens, j)
+				args[k] = tokens[j+1 : end]
+				j = end + 1
+				continue
+			}
+			args[k] = tokens[j : j+1]
+			j++
+		}
+
+		expanded, ok := m.expandTokens(substituteMacroArgs(splitTeX(macro.Body), args), depth+1)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, expanded...)
+		i = j - 1
+	}
+	return out, true
+}
+
+// matchingBrace は tokens[open] の { に対応する } の位置を返します。対応する } がない場合は最後の位置です
+func matchingBrace(tokens []string, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+func substituteMacroArgs(body []string, args [][]string) []string {
+	var out []string
+	for i := 0; i < len(body); i++ {
+		if body[i] == "#" && i+1 < len(body) {
+			if n, err := strconv.Atoi(body[i+1]); err == nil && n >= 1 && n <= len(args) {
+				out = append(out, args[n-1]...)
+				i++
+				continue
+			}
+		}
+		out = append(out, body[i])
+	}
+	return out
+}
+
+// splitTeX は数式を命令と 1 文字ずつに分けます。tokenizeTeX と違い、空白もそのまま残します
+func splitTeX(tex string) []string {
+	var tokens []string
+	for i := 0; i < len(tex); {
+		end := i + 1
+		if tex[i] == '\\' && i+1 < len(tex) {
+			end = i + 1
+			for end < len(tex) && isASCIILetter(tex[end]) {
+				end++
+			}
+			if end == i+1 {
+				_, size := utf8.DecodeRuneInString(tex[end:])
+				end += size
+			}
+		} else {
+			_, size := utf8.DecodeRuneInString(tex[i:])
+			end = i + size
+		}
+		tokens = append(tokens, tex[i:end])
+		i = end
+	}
+	return tokens
+}
+
+// joinTeX はトークンをつなげます。英字の命令の直後に英字が続く場合は、別の命令にならないように空白を入れます
+func joinTeX(tokens []string) string {
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && tok != "" && isASCIILetter(tok[0]) {
+			prev := tokens[i-1]
+			if len(prev) > 1 && prev[0] == '\\' && isASCIILetter(prev[len(prev)-1]) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
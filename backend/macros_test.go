@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeMacroFiles(t *testing.T, rootDir string, tex string, config string) {
+	t.Helper()
+	dir := filepath.Join(rootDir, projectConfigDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, macrosFileName), []byte(tex), 0644); err != nil {
+		t.Fatalf("Failed to write macros.tex: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, projectConfigFile), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config.json: %v", err)
+	}
+}
+
+func TestLoadMathMacros(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tex := `% 集合
+\newcommand{\R}{\mathbb{R}}
+\renewcommand\N{\mathbb{N}} % 自然数
+\newcommand{\abs}[1]{\left|#1\right|}
+\def\pair#1#2{\langle #1, #2\rangle}
+\DeclareMathOperator{\Hom}{Hom}
+\newcommand{\bad}[1]{#2}
+\newcommand{\opt}[1][x]{#1}
+\usepackage{amsmath}
+\newcommand{\loop}{\loop}
+`
+	config := `{"version":1,"macros":{"\\R":"\\mathbf{R}","\\norm":"\\lVert #1 \\rVert","R2":"x"}}`
+	writeMacroFiles(t, tmpDir, tex, config)
+
+	macros, err := LoadMathMacros(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadMathMacros failed: %v", err)
+	}
+	expected := []MathMacro{
+		{Name: `\Hom`, Body: `\operatorname{Hom}`, Source: macrosFileName, Line: 6},
+		{Name: `\N`, Body: `\mathbb{N}`, Source: macrosFileName, Line: 3},
+		{Name: `\R`, Body: `\mathbf{R}`, Source: projectConfigFile},
+		{Name: `\abs`, Body: `\left|#1\right|`, Args: 1, Source: macrosFileName, Line: 4},
+		{Name: `\norm`, Body: `\lVert #1 \rVert`, Args: 1, Source: projectConfigFile},
+		{Name: `\pair`, Body: `\langle #1, #2\rangle`, Args: 2, Source: macrosFileName, Line: 5},
+	}
+	if !reflect.DeepEqual(macros.Macros, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, macros.Macros)
+	}
+
+	var messages []string
+	for _, issue := range macros.Issues {
+		messages = append(messages, issue.Source+":"+issue.Message)
+	}
+	for _, want := range []string{
+		macrosFileName + `:\bad: # must be followed`,
+		macrosFileName + `:\opt: optional arguments`,
+		macrosFileName + `:\usepackage is not a macro definition`,
+		macrosFileName + `:\loop expands recursively`,
+		projectConfigFile + `:"R2" is not a valid macro name`,
+		projectConfigFile + `:\R is also defined in macros.tex:2`,
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.HasPrefix(message, want)
+		}
+		if !found {
+			t.Errorf("Expected an issue starting with %q, but got %v", want, messages)
+		}
+	}
+}
+
+func TestMathMacroSet_Expand(t *testing.T) {
+	set := mathMacroSet{
+		`\R`:    {Name: `\R`, Body: `\mathbb{R}`},
+		`\abs`:  {Name: `\abs`, Body: `\left|#1\right|`, Args: 1},
+		`\pair`: {Name: `\pair`, Body: `\langle #1, #2\rangle`, Args: 2},
+		`\eps`:  {Name: `\eps`, Body: `\varepsilon`},
+		`\absR`: {Name: `\absR`, Body: `\abs{\R}`},
+		`\loop`: {Name: `\loop`, Body: `\loop`},
+	}
+	tests := []struct {
+		tex      string
+		expected string
+	}{
+		{`x \in \R^2`, `x \in \mathbb{R}^2`},
+		{`\abs{x + y} \le \abs x`, `\left|x + y\right| \le \left|x\right|`},
+		{`\pair{a}{\R}`, `\langle a, \mathbb{R}\rangle`},
+		{`\eps x`, `\varepsilon x`},
+		{`\abs\eps`, `\left|\varepsilon\right|`},
+		{`\absR`, `\left|\mathbb{R}\right|`},
+		{`\Rx + \alpha`, `\Rx + \alpha`},
+		{"\\begin{aligned}\n\\R &= 1\n\\end{aligned}", "\\begin{aligned}\n\\mathbb{R} &= 1\n\\end{aligned}"},
+		{`\loop`, `\loop`},
+	}
+	for _, tt := range tests {
+		if got := set.expand(tt.tex); got != tt.expected {
+			t.Errorf("expand(%q): expected %q, but got %q", tt.tex, tt.expected, got)
+		}
+	}
+}
+
+func TestExportLaTeX_ExpandsMacros(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeMacroFiles(t, tmpDir, `\newcommand{\R}{\mathbb{R}}`+"\n"+`\newcommand{\broken}{`, `{"version":1}`)
+	notePath := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(notePath, []byte("# Note\n\n$x \\in \\R$\n\n$$\n\\R^n\n$$\n"), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	outDir := filepath.Join(tmpDir, "out")
+	result, err := ExportLaTeX(tmpDir, LaTeXExportOptions{Paths: []string{notePath}, OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("ExportLaTeX failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "note.tex"))
+	if err != nil {
+		t.Fatalf("Failed to read note.tex: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `$x \in \mathbb{R}$`) || !strings.Contains(content, "\\[\n\\mathbb{R}^n\n\\]") || strings.Contains(content, `\R`) {
+		t.Errorf("Expected macros to be expanded, but got:\n%s", content)
+	}
+	if len(result.Warnings) != 1 || !strings.HasPrefix(result.Warnings[0], macrosFileName+":2:") {
+		t.Errorf("Expected a warning for the broken macro, but got %v", result.Warnings)
+	}
+}
@@ -0,0 +1,1213 @@
+package backend
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown の構文木の要素の種類です。ブロック要素とインライン要素があります
+const (
+	NodeKindDocument      = "document"
+	NodeKindFrontmatter   = "frontmatter"
+	NodeKindParagraph     = "paragraph"
+	NodeKindHeading       = "heading"
+	NodeKindThematicBreak = "thematic_break"
+	NodeKindBlockquote    = "blockquote"
+	NodeKindList          = "list"
+	NodeKindListItem      = "list_item"
+	NodeKindCodeBlock     = "code_block"
+	NodeKindMathBlock     = "math_block"
+	NodeKindMermaid       = "mermaid"
+	NodeKindCardLink      = "cardlink"
+	NodeKindTable         = "table"
+	NodeKindTableRow      = "table_row"
+	NodeKindTableCell     = "table_cell"
+	NodeKindHTMLBlock     = "html_block"
+	NodeKindTheorem       = "theorem"
+	NodeKindDetails       = "details"
+
+	NodeKindText       = "text"
+	NodeKindBreak      = "break"
+	NodeKindEmphasis   = "emphasis"
+	NodeKindStrong     = "strong"
+	NodeKindDelete     = "delete"
+	NodeKindCode       = "code"
+	NodeKindMath       = "math"
+	NodeKindLink       = "link"
+	NodeKindImage      = "image"
+	NodeKindWikiLink   = "wiki_link"
+	NodeKindEmbed      = "embed"
+	NodeKindHTMLInline = "html_inline"
+)
+
+var (
+	summaryRegexp         = regexp.MustCompile(`<summary>(.*?)</summary>`)
+	htmlTagRegexp         = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	fenceOpenRegexp       = regexp.MustCompile("^(`{3,}|~{3,})\\s*(.*)$")
+	listMarkerRegexp      = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( {1,4}|\t|$)`)
+	tableDelimiterRegexp  = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	htmlBlockOpenRegexp   = regexp.MustCompile(`^(?:<!--|</?([A-Za-z][A-Za-z0-9-]*)(?:\s|/?>|$))`)
+	inlineHTMLRegexp      = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][\w:.-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--(?s:.*?)-->)`)
+	angleAutolinkRegexp   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	entityRegexp          = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	autolinkLiteralRegexp = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]+`)
+)
+
+// htmlBlockTags は段落の途中でも HTML ブロックを始められるタグです
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "center": true, "dd": true,
+	"details": true, "dialog": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true, "ul": true,
+}
+
+// Node は Markdown の構文木の要素です。どのフィールドを使うかは Kind によって決まります。
+// StartLine と EndLine はブロック要素の範囲で、1 始まりで EndLine の行を含みます
+type Node struct {
+	Kind     string  `json:"kind"`
+	Children []*Node `json:"children,omitempty"`
+	// Literal はテキスト・コード・数式・HTML の中身と、wiki リンクの [[...]] の中の | より前の部分です
+	Literal string `json:"literal,omitempty"`
+	// Level は見出しのレベルです
+	Level int `json:"level,omitempty"`
+	// Info はコードブロックの言語です
+	Info string `json:"info,omitempty"`
+	// Destination はリンクと画像の URL、wiki リンクと埋め込みのパスです
+	Destination string `json:"destination,omitempty"`
+	// Fragment は wiki リンクの # より後の見出しです
+	Fragment string `json:"fragment,omitempty"`
+	// Title は定理名、<details> の summary、リンクと画像の title です
+	Title string `json:"title,omitempty"`
+	// Environment は定理環境のタグ名です
+	Environment string `json:"environment,omitempty"`
+	// Slug は見出しと定理の id で、プレビューの rehype-slug と同じ規則で付けられます
+	Slug    string `json:"slug,omitempty"`
+	Ordered bool   `json:"ordered,omitempty"`
+	Start   int    `json:"start,omitempty"`
+	Tight   bool   `json:"tight,omitempty"`
+	Task    bool   `json:"task,omitempty"`
+	Checked bool   `json:"checked,omitempty"`
+	// Align は表のセルの配置 (left, center, right) です
+	Align string `json:"align,omitempty"`
+	// Display は $$ で囲まれたインライン数式かどうかです
+	Display bool `json:"display,omitempty"`
+	// Attrs は cardlink ブロックのキーと値です
+	Attrs     map[string]string `json:"attrs,omitempty"`
+	StartLine int               `json:"start_line,omitempty"`
+	EndLine   int               `json:"end_line,omitempty"`
+}
+
+// Walk は n とその子孫を文書の順にたどります。fn が false を返した要素の子はたどりません
+func (n *Node) Walk(fn func(node *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// PlainText はインライン要素から書式を取り除いたテキストを返します。
+// プレビューで表示される文字列と同じで、見出しの slug の元になります
+func (n *Node) PlainText() string {
+	var b strings.Builder
+	n.Walk(func(node *Node) bool {
+		switch node.Kind {
+		case NodeKindText, NodeKindCode, NodeKindMath:
+			b.WriteString(node.Literal)
+		case NodeKindBreak:
+			b.WriteString("\n")
+		case NodeKindImage, NodeKindEmbed, NodeKindHTMLInline:
+			return false
+		}
+		return true
+	})
+	return b.String()
+}
+
+type markdownLine struct {
+	text string
+	no   int
+}
+
+// ParseMarkdown は既定の定理環境で Markdown を構文木に変換します。
+// プレビューと同じく GFM・remark-breaks・remark-math の記法に加えて、<theorem>・<details>・[[...]]・![[...]]・
+// mermaid と cardlink のコードブロックを扱います。フロントマターは NodeKindFrontmatter として先頭に置かれます
+func ParseMarkdown(content string) *Node {
+	return parseMarkdown(content, getDefaultTheoremSettings())
+}
+
+// parseMarkdown は theorems の定理環境のタグを定理として Markdown を構文木に変換します
+func parseMarkdown(content string, theorems TheoremSettings) *Node {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	texts := strings.Split(content, "\n")
+	doc := &Node{Kind: NodeKindDocument, StartLine: 1, EndLine: len(texts)}
+
+	start := 0
+	if fm, offset, ok := splitFrontmatter(content); ok {
+		start = strings.Count(content[:offset], "\n")
+		doc.Children = append(doc.Children, &Node{Kind: NodeKindFrontmatter, Literal: fm, StartLine: 1, EndLine: start})
+	}
+
+	lines := make([]markdownLine, 0, len(texts)-start)
+	for i := start; i < len(texts); i++ {
+		lines = append(lines, markdownLine{text: texts[i], no: i + 1})
+	}
+	p := &markdownParser{theoremOpen: theorems.openRegexp()}
+	doc.Children = append(doc.Children, p.parseBlocks(lines)...)
+
+	slugger := NewSlugger()
+	doc.Walk(func(node *Node) bool {
+		switch node.Kind {
+		case NodeKindHeading:
+			node.Slug = slugger.Slug(node.PlainText())
+		case NodeKindTheorem:
+			// プレビューでは定理名が h4 の見出しになるので、見出しと同じく slug を消費する
+			node.Slug = slugger.Slug(theoremTitle(node, theorems))
+		}
+		return true
+	})
+	return doc
+}
+
+// markdownParser はブロック要素を読み取ります。theoremOpen は定理環境の開始タグです
+type markdownParser struct {
+	theoremOpen *regexp.Regexp
+}
+
+// theoremTitle は定理の見出しに表示する定理名です。名前のない定理には環境の表示名を使います
+func theoremTitle(node *Node, theorems TheoremSettings) string {
+	if node.Title != "" {
+		return node.Title
+	}
+	if env, ok := theorems.environment(node.Environment); ok {
+		return env.Label
+	}
+	return node.Environment
+}
+
+func isBlankLine(text string) bool {
+	return strings.TrimSpace(text) == ""
+}
+
+// indentWidth は行頭の空白の幅を返します。タブは 4 文字として数えます
+func indentWidth(text string) int {
+	width := 0
+	for _, c := range text {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// stripIndent は行頭から幅 width までの空白を取り除きます
+func stripIndent(text string, width int) string {
+	removed := 0
+	for i, c := range text {
+		if removed >= width {
+			return text[i:]
+		}
+		switch c {
+		case ' ':
+			removed++
+		case '\t':
+			removed += 4 - removed%4
+		default:
+			return text[i:]
+		}
+	}
+	return ""
+}
+
+func isThematicBreak(line string) bool {
+	compact := strings.Join(strings.Fields(line), "")
+	if len(compact) < 3 {
+		return false
+	}
+	for _, c := range []string{"-", "*", "_"} {
+		if strings.Trim(compact, c) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isSetextUnderline(trimmed string) (int, bool) {
+	switch {
+	case trimmed != "" && strings.Trim(trimmed, "=") == "":
+		return 1, true
+	case trimmed != "" && strings.Trim(trimmed, "-") == "":
+		return 2, true
+	}
+	return 0, false
+}
+
+// startsBlock は段落を中断して新しいブロックを始める行かどうかを返します
+func (p *markdownParser) startsBlock(text string) bool {
+	if indentWidth(text) >= 4 {
+		return false
+	}
+	trimmed := strings.TrimSpace(text)
+	if _, _, ok := parseATXHeading(trimmed); ok {
+		return true
+	}
+	if m := listMarkerRegexp.FindStringSubmatch(text); m != nil {
+		rest := strings.TrimSpace(text[len(m[0]):])
+		if rest != "" && (len(m[2]) == 1 || strings.HasPrefix(m[2], "1")) {
+			return true
+		}
+	}
+	if m := htmlBlockOpenRegexp.FindStringSubmatch(trimmed); m != nil && htmlBlockTags[strings.ToLower(m[1])] {
+		return true
+	}
+	return strings.HasPrefix(trimmed, ">") || fenceOpenRegexp.MatchString(trimmed) ||
+		strings.HasPrefix(trimmed, "$$") || isThematicBreak(trimmed) || p.theoremOpen.MatchString(trimmed)
+}
+
+// parseBlocks は行の並びをブロック要素に分けます
+func (p *markdownParser) parseBlocks(lines []markdownLine) []*Node {
+	var nodes []*Node
+	var paragraph []markdownLine
+	flush := func() {
+		if len(paragraph) > 0 {
+			nodes = append(nodes, newParagraph(paragraph))
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line.text) {
+			flush()
+			continue
+		}
+
+		inParagraph := len(paragraph) > 0
+		if inParagraph {
+			if level, ok := isSetextUnderline(strings.TrimSpace(line.text)); ok && indentWidth(line.text) < 4 {
+				p := newParagraph(paragraph)
+				nodes = append(nodes, &Node{
+					Kind:      NodeKindHeading,
+					Level:     level,
+					Children:  p.Children,
+					StartLine: p.StartLine,
+					EndLine:   line.no,
+				})
+				paragraph = nil
+				continue
+			}
+			if !p.startsBlock(line.text) {
+				paragraph = append(paragraph, line)
+				continue
+			}
+		}
+
+		if node, next, ok := p.parseBlockStart(lines, i, inParagraph); ok {
+			flush()
+			nodes = append(nodes, node)
+			i = next - 1
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+	return nodes
+}
+
+func newParagraph(lines []markdownLine) *Node {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = strings.TrimLeft(line.text, " \t")
+	}
+	return &Node{
+		Kind:      NodeKindParagraph,
+		Children:  parseInline(strings.TrimRight(strings.Join(texts, "\n"), " \t")),
+		StartLine: lines[0].no,
+		EndLine:   lines[len(lines)-1].no,
+	}
+}
+
+// parseBlockStart は lines[i] から始まるブロックを読み取り、ブロックの次の行の位置を返します
+func (p *markdownParser) parseBlockStart(lines []markdownLine, i int, inParagraph bool) (*Node, int, bool) {
+	line := lines[i]
+	indent := indentWidth(line.text)
+	trimmed := strings.TrimSpace(line.text)
+
+	if indent >= 4 {
+		if inParagraph {
+			return nil, 0, false
+		}
+		return parseIndentedCode(lines, i)
+	}
+
+	if m := fenceOpenRegexp.FindStringSubmatch(trimmed); m != nil && !(m[1][0] == '`' && strings.Contains(m[2], "`")) {
+		return parseFencedCode(lines, i, m[1], strings.TrimSpace(m[2]))
+	}
+	if strings.HasPrefix(trimmed, "$$") && !strings.Contains(trimmed[2:], "$$") {
+		return parseMathBlock(lines, i)
+	}
+	if m := p.theoremOpen.FindStringSubmatch(trimmed); m != nil {
+		inner, end := collectTaggedBlock(lines, i, m[0], "<"+m[1], "</"+m[1]+">")
+		return &Node{
+			Kind:        NodeKindTheorem,
+			Title:       m[2],
+			Environment: m[1],
+			Children:    p.parseBlocks(inner),
+			StartLine:   line.no,
+			EndLine:     lines[end].no,
+		}, end + 1, true
+	}
+	if strings.HasPrefix(trimmed, "<details") && (len(trimmed) == 8 || trimmed[8] == '>' || trimmed[8] == ' ') {
+		return p.parseDetails(lines, i)
+	}
+	if level, text, ok := parseATXHeading(trimmed); ok {
+		return &Node{Kind: NodeKindHeading, Level: level, Children: parseInline(text), StartLine: line.no, EndLine: line.no}, i + 1, true
+	}
+	if isThematicBreak(trimmed) {
+		return &Node{Kind: NodeKindThematicBreak, StartLine: line.no, EndLine: line.no}, i + 1, true
+	}
+	if strings.HasPrefix(trimmed, ">") {
+		return p.parseBlockquote(lines, i)
+	}
+	if m := listMarkerRegexp.FindStringSubmatch(line.text); m != nil {
+		rest := strings.TrimSpace(line.text[len(m[0]):])
+		if !inParagraph || (rest != "" && (len(m[2]) == 1 || strings.HasPrefix(m[2], "1"))) {
+			return p.parseList(lines, i)
+		}
+	}
+	if m := htmlBlockOpenRegexp.FindStringSubmatch(trimmed); m != nil && (!inParagraph || htmlBlockTags[strings.ToLower(m[1])]) {
+		return parseHTMLBlock(lines, i)
+	}
+	if !inParagraph && strings.Contains(trimmed, "|") && i+1 < len(lines) {
+		if node, next, ok := p.parseTable(lines, i); ok {
+			return node, next, true
+		}
+	}
+	return nil, 0, false
+}
+
+func parseIndentedCode(lines []markdownLine, i int) (*Node, int, bool) {
+	var code []string
+	end := i
+	j := i
+	for ; j < len(lines); j++ {
+		if isBlankLine(lines[j].text) {
+			code = append(code, stripIndent(lines[j].text, 4))
+			continue
+		}
+		if indentWidth(lines[j].text) < 4 {
+			break
+		}
+		code = append(code, stripIndent(lines[j].text, 4))
+		end = j
+	}
+	code = code[:end-i+1]
+	return &Node{
+		Kind:      NodeKindCodeBlock,
+		Literal:   strings.Join(code, "\n"),
+		StartLine: lines[i].no,
+		EndLine:   lines[end].no,
+	}, end + 1, true
+}
+
+func parseFencedCode(lines []markdownLine, i int, fence string, info string) (*Node, int, bool) {
+	indent := indentWidth(lines[i].text)
+	var code []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		trimmed := strings.TrimSpace(lines[j].text)
+		if indentWidth(lines[j].text) < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			break
+		}
+		code = append(code, stripIndent(lines[j].text, indent))
+	}
+	end := min(j, len(lines)-1)
+
+	lang, _, _ := strings.Cut(info, " ")
+	node := &Node{
+		Kind:      NodeKindCodeBlock,
+		Info:      lang,
+		Literal:   strings.Join(code, "\n"),
+		StartLine: lines[i].no,
+		EndLine:   lines[end].no,
+	}
+	switch lang {
+	case "mermaid":
+		node.Kind = NodeKindMermaid
+	case "cardlink":
+		node.Kind = NodeKindCardLink
+		node.Attrs = parseCardLink(code)
+	}
+	return node, end + 1, true
+}
+
+// parseCardLink はプレビューと同じく、"key: value" の行を読み取ります。値を囲む " は取り除きます
+func parseCardLink(lines []string) map[string]string {
+	attrs := make(map[string]string)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+		attrs[key] = value
+	}
+	return attrs
+}
+
+func parseMathBlock(lines []markdownLine, i int) (*Node, int, bool) {
+	var math []string
+	if rest := strings.TrimSpace(strings.TrimSpace(lines[i].text)[2:]); rest != "" {
+		math = append(math, rest)
+	}
+	j := i + 1
+	for ; j < len(lines); j++ {
+		if end := strings.Index(lines[j].text, "$$"); end >= 0 {
+			if strings.TrimSpace(lines[j].text[:end]) != "" {
+				math = append(math, lines[j].text[:end])
+			}
+			break
+		}
+		math = append(math, lines[j].text)
+	}
+	end := min(j, len(lines)-1)
+	return &Node{
+		Kind:      NodeKindMathBlock,
+		Literal:   strings.Join(math, "\n"),
+		StartLine: lines[i].no,
+		EndLine:   lines[end].no,
+	}, end + 1, true
+}
+
+// collectTaggedBlock は lines[start] の openTag から closeTag までの中身と、閉じタグのある行の位置を返します。
+// 同じタグの入れ子も扱います
+func collectTaggedBlock(lines []markdownLine, start int, openTag string, nestTag string, closeTag string) ([]markdownLine, int) {
+	first := lines[start].text
+	first = first[strings.Index(first, openTag)+len(openTag):]
+
+	depth := 1
+	var inner []markdownLine
+	for j := start; j < len(lines); j++ {
+		text := first
+		if j > start {
+			text = lines[j].text
+		}
+		offset := 0
+		for {
+			open := strings.Index(text[offset:], nestTag)
+			end := strings.Index(text[offset:], closeTag)
+			if end < 0 {
+				break
+			}
+			if open >= 0 && open < end {
+				depth++
+				offset += open + len(nestTag)
+				continue
+			}
+			depth--
+			if depth == 0 {
+				if before := text[:offset+end]; !isBlankLine(before) {
+					inner = append(inner, markdownLine{text: before, no: lines[j].no})
+				}
+				return inner, j
+			}
+			offset += end + len(closeTag)
+		}
+		if strings.Count(text[offset:], nestTag) > 0 {
+			depth += strings.Count(text[offset:], nestTag)
+		}
+		if j > start || !isBlankLine(text) {
+			inner = append(inner, markdownLine{text: text, no: lines[j].no})
+		}
+	}
+	return inner, len(lines) - 1
+}
+
+func (p *markdownParser) parseDetails(lines []markdownLine, i int) (*Node, int, bool) {
+	trimmed := strings.TrimSpace(lines[i].text)
+	openTag := trimmed
+	if end := strings.IndexByte(trimmed, '>'); end >= 0 {
+		openTag = trimmed[:end+1]
+	}
+	inner, end := collectTaggedBlock(lines, i, openTag, "<details", "</details>")
+
+	node := &Node{Kind: NodeKindDetails, StartLine: lines[i].no, EndLine: lines[end].no}
+	body := make([]markdownLine, 0, len(inner))
+	for j, line := range inner {
+		// summary は先頭の数行にあるものだけを使う
+		if m := summaryRegexp.FindStringSubmatch(line.text); m != nil && node.Title == "" && j < 3 {
+			node.Title = strings.TrimSpace(htmlTagRegexp.ReplaceAllString(m[1], ""))
+			if rest := summaryRegexp.ReplaceAllString(line.text, ""); !isBlankLine(rest) {
+				body = append(body, markdownLine{text: rest, no: line.no})
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	node.Children = p.parseBlocks(body)
+	return node, end + 1, true
+}
+
+// isProofSummary は <details> の summary が証明を表すかどうかを返します。
+// 設定の ProofLabel のほか、設定を変える前に書いたノートのために既定の「証明」と proof も証明として扱います
+func isProofSummary(summary string, theorems TheoremSettings) bool {
+	lower := strings.ToLower(summary)
+	if label := strings.ToLower(strings.TrimSpace(theorems.ProofLabel)); label != "" && strings.Contains(lower, label) {
+		return true
+	}
+	return strings.Contains(summary, "証明") || strings.Contains(lower, "proof")
+}
+
+func (p *markdownParser) parseBlockquote(lines []markdownLine, i int) (*Node, int, bool) {
+	var quoted []markdownLine
+	j := i
+	for ; j < len(lines); j++ {
+		text := lines[j].text
+		trimmed := strings.TrimSpace(text)
+		if indentWidth(text) < 4 && strings.HasPrefix(trimmed, ">") {
+			rest := trimmed[1:]
+			if strings.HasPrefix(rest, " ") {
+				rest = rest[1:]
+			}
+			quoted = append(quoted, markdownLine{text: rest, no: lines[j].no})
+			continue
+		}
+		// 段落の遅延継続行
+		if !isBlankLine(text) && len(quoted) > 0 && !isBlankLine(quoted[len(quoted)-1].text) && !p.startsBlock(text) {
+			quoted = append(quoted, lines[j])
+			continue
+		}
+		break
+	}
+	return &Node{
+		Kind:      NodeKindBlockquote,
+		Children:  p.parseBlocks(quoted),
+		StartLine: lines[i].no,
+		EndLine:   lines[j-1].no,
+	}, j, true
+}
+
+type listMarker struct {
+	ordered       bool
+	delimiter     byte
+	start         int
+	contentIndent int
+	rest          string
+}
+
+func parseListMarker(text string) (listMarker, bool) {
+	m := listMarkerRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return listMarker{}, false
+	}
+	marker := listMarker{delimiter: m[2][len(m[2])-1]}
+	if len(m[2]) > 1 || m[2][0] >= '0' && m[2][0] <= '9' {
+		marker.ordered = true
+		marker.start, _ = strconv.Atoi(m[2][:len(m[2])-1])
+	}
+	marker.rest = text[len(m[0]):]
+	spacing := len(m[3])
+	if m[3] == "\t" {
+		spacing = 4 - (len(m[1])+len(m[2]))%4
+	}
+	if strings.TrimSpace(marker.rest) == "" || spacing > 4 {
+		spacing = 1
+	}
+	marker.contentIndent = len(m[1]) + len(m[2]) + spacing
+	return marker, true
+}
+
+func (p *markdownParser) parseList(lines []markdownLine, i int) (*Node, int, bool) {
+	first, _ := parseListMarker(lines[i].text)
+	list := &Node{Kind: NodeKindList, Ordered: first.ordered, Start: first.start, Tight: true, StartLine: lines[i].no}
+	if !first.ordered {
+		list.Start = 0
+	}
+
+	j := i
+	for j < len(lines) {
+		marker, ok := parseListMarker(lines[j].text)
+		if !ok || marker.ordered != first.ordered || marker.delimiter != first.delimiter || isThematicBreak(strings.TrimSpace(lines[j].text)) {
+			break
+		}
+
+		itemLines := []markdownLine{{text: marker.rest, no: lines[j].no}}
+		k := j + 1
+	collect:
+		for ; k < len(lines); k++ {
+			text := lines[k].text
+			switch {
+			case isBlankLine(text):
+				itemLines = append(itemLines, markdownLine{no: lines[k].no})
+			case indentWidth(text) >= marker.contentIndent:
+				itemLines = append(itemLines, markdownLine{text: stripIndent(text, marker.contentIndent), no: lines[k].no})
+			case listMarkerRegexp.MatchString(text):
+				// 次の項目か、別のリスト
+				break collect
+			case !isBlankLine(itemLines[len(itemLines)-1].text) && !p.startsBlock(text):
+				itemLines = append(itemLines, lines[k])
+			default:
+				break collect
+			}
+		}
+		trailing := 0
+		for len(itemLines) > 1 && isBlankLine(itemLines[len(itemLines)-1].text) {
+			itemLines = itemLines[:len(itemLines)-1]
+			trailing++
+		}
+
+		item := &Node{Kind: NodeKindListItem, StartLine: lines[j].no, EndLine: itemLines[len(itemLines)-1].no}
+		head := itemLines[0].text
+		if len(head) >= 3 && head[0] == '[' && head[2] == ']' && strings.ContainsRune(" xX", rune(head[1])) &&
+			(len(head) == 3 || head[3] == ' ' || head[3] == '\t') {
+			item.Task = true
+			item.Checked = head[1] != ' '
+			itemLines[0].text = strings.TrimLeft(head[3:], " \t")
+		}
+		item.Children = p.parseBlocks(itemLines)
+		for c := 1; c < len(item.Children); c++ {
+			if item.Children[c].StartLine > item.Children[c-1].EndLine+1 {
+				list.Tight = false
+			}
+		}
+		list.Children = append(list.Children, item)
+
+		j = k
+		if trailing > 0 {
+			if next, ok := parseListMarker(textAt(lines, j)); ok && next.ordered == first.ordered && next.delimiter == first.delimiter {
+				list.Tight = false
+			}
+		}
+	}
+	list.EndLine = list.Children[len(list.Children)-1].EndLine
+	return list, j, true
+}
+
+func textAt(lines []markdownLine, i int) string {
+	if i < len(lines) {
+		return lines[i].text
+	}
+	return ""
+}
+
+func parseHTMLBlock(lines []markdownLine, i int) (*Node, int, bool) {
+	comment := strings.HasPrefix(strings.TrimSpace(lines[i].text), "<!--")
+	var texts []string
+	j := i
+	for ; j < len(lines); j++ {
+		if !comment && isBlankLine(lines[j].text) {
+			break
+		}
+		texts = append(texts, lines[j].text)
+		if comment && strings.Contains(lines[j].text, "-->") {
+			j++
+			break
+		}
+	}
+	return &Node{
+		Kind:      NodeKindHTMLBlock,
+		Literal:   strings.Join(texts, "\n"),
+		StartLine: lines[i].no,
+		EndLine:   lines[j-1].no,
+	}, j, true
+}
+
+// splitTableRow は表の行をセルに分けます。\| はセルの区切りとして扱いません
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func (p *markdownParser) parseTable(lines []markdownLine, i int) (*Node, int, bool) {
+	delimiter := strings.TrimSpace(lines[i+1].text)
+	if !tableDelimiterRegexp.MatchString(delimiter) || !strings.ContainsAny(delimiter+lines[i].text, "|") {
+		return nil, 0, false
+	}
+	header := splitTableRow(lines[i].text)
+	specs := splitTableRow(delimiter)
+	if len(header) != len(specs) {
+		return nil, 0, false
+	}
+
+	aligns := make([]string, len(specs))
+	for c, spec := range specs {
+		switch {
+		case strings.HasPrefix(spec, ":") && strings.HasSuffix(spec, ":"):
+			aligns[c] = "center"
+		case strings.HasPrefix(spec, ":"):
+			aligns[c] = "left"
+		case strings.HasSuffix(spec, ":"):
+			aligns[c] = "right"
+		}
+	}
+	newRow := func(line markdownLine) *Node {
+		cells := splitTableRow(line.text)
+		row := &Node{Kind: NodeKindTableRow, StartLine: line.no, EndLine: line.no}
+		for c, align := range aligns {
+			cell := &Node{Kind: NodeKindTableCell, Align: align, StartLine: line.no, EndLine: line.no}
+			if c < len(cells) {
+				cell.Children = parseInline(cells[c])
+			}
+			row.Children = append(row.Children, cell)
+		}
+		return row
+	}
+
+	table := &Node{Kind: NodeKindTable, StartLine: lines[i].no, Children: []*Node{newRow(lines[i])}}
+	j := i + 2
+	for ; j < len(lines) && !isBlankLine(lines[j].text) && !p.startsBlock(lines[j].text); j++ {
+		table.Children = append(table.Children, newRow(lines[j]))
+	}
+	table.EndLine = lines[j-1].no
+	return table, j, true
+}
+
+// parseInline はインライン要素を解析します。remark-breaks と同じく、改行はすべて改行要素になります
+func parseInline(text string) []*Node {
+	delimiters := make(map[int]delimiterMatch)
+	var nodes []*Node
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			nodes = append(nodes, &Node{Kind: NodeKindText, Literal: buf.String()})
+			buf.Reset()
+		}
+	}
+	emit := func(node *Node) {
+		flush()
+		nodes = append(nodes, node)
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch c := text[i]; c {
+		case '\\':
+			if len(rest) > 1 && rest[1] == '\n' {
+				emit(&Node{Kind: NodeKindBreak})
+				i += 2
+				continue
+			}
+			if len(rest) > 1 && isASCIIPunct(rest[1]) {
+				buf.WriteByte(rest[1])
+				i += 2
+				continue
+			}
+
+		case '\n':
+			trimmed := strings.TrimRight(buf.String(), " ")
+			buf.Reset()
+			buf.WriteString(trimmed)
+			emit(&Node{Kind: NodeKindBreak})
+			i++
+			for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+				i++
+			}
+			continue
+
+		case '`':
+			if end, inner, ok := matchCodeSpan(rest); ok {
+				emit(&Node{Kind: NodeKindCode, Literal: inner})
+				i += end
+				continue
+			}
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			buf.WriteString(rest[:n])
+			i += n
+			continue
+
+		case '$':
+			if end, inner, ok := matchMath(rest); ok {
+				emit(&Node{Kind: NodeKindMath, Literal: inner, Display: strings.HasPrefix(rest, "$$")})
+				i += end
+				continue
+			}
+			n := len(rest) - len(strings.TrimLeft(rest, "$"))
+			buf.WriteString(rest[:n])
+			i += n
+			continue
+
+		case '!':
+			if strings.HasPrefix(rest, "![[") {
+				if end := strings.Index(rest, "]]"); end > 3 && !strings.Contains(rest[3:end], "\n") {
+					name := rest[3:end]
+					emit(&Node{Kind: NodeKindEmbed, Destination: name, Literal: name})
+					i += end + 2
+					continue
+				}
+			}
+			if strings.HasPrefix(rest, "![") {
+				if end, label, dest, title, ok := matchInlineLink(rest[1:]); ok {
+					alt := &Node{Children: parseInline(label)}
+					emit(&Node{Kind: NodeKindImage, Destination: dest, Title: title, Literal: alt.PlainText()})
+					i += end + 1
+					continue
+				}
+			}
+
+		case '[':
+			if strings.HasPrefix(rest, "[[") {
+				if end := strings.Index(rest, "]]"); end > 2 && !strings.Contains(rest[2:end], "\n") {
+					emit(newWikiLink(rest[2:end]))
+					i += end + 2
+					continue
+				}
+			}
+			if end, label, dest, title, ok := matchInlineLink(rest); ok {
+				emit(&Node{Kind: NodeKindLink, Destination: dest, Title: title, Children: parseInline(label)})
+				i += end
+				continue
+			}
+
+		case '<':
+			if m := angleAutolinkRegexp.FindStringSubmatch(rest); m != nil {
+				emit(&Node{Kind: NodeKindLink, Destination: m[1], Children: []*Node{{Kind: NodeKindText, Literal: m[1]}}})
+				i += len(m[0])
+				continue
+			}
+			if m := inlineHTMLRegexp.FindString(rest); m != "" {
+				emit(&Node{Kind: NodeKindHTMLInline, Literal: m})
+				i += len(m)
+				continue
+			}
+
+		case '&':
+			if m := entityRegexp.FindString(rest); m != "" {
+				buf.WriteString(html.UnescapeString(m))
+				i += len(m)
+				continue
+			}
+
+		case '*', '_', '~':
+			if node, end, ok := matchDelimited(text, i, delimiters); ok {
+				emit(node)
+				i = end
+				continue
+			}
+			n := len(rest) - len(strings.TrimLeft(rest, string(c)))
+			buf.WriteString(rest[:n])
+			i += n
+			continue
+
+		case 'h', 'w':
+			if i == 0 || strings.IndexByte(" \t\n*_~(", text[i-1]) >= 0 {
+				if url := trimAutolinkLiteral(autolinkLiteralRegexp.FindString(rest)); url != "" && (c == 'h' || strings.Contains(url[4:], ".")) {
+					dest := url
+					if c == 'w' {
+						dest = "http://" + url
+					}
+					emit(&Node{Kind: NodeKindLink, Destination: dest, Children: []*Node{{Kind: NodeKindText, Literal: url}}})
+					i += len(url)
+					continue
+				}
+			}
+		}
+		buf.WriteByte(text[i])
+		i++
+	}
+	flush()
+	return nodes
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// newWikiLink は [[path#heading|表示名]] の中身から wiki リンクを作ります。
+// プレビューと同じく表示名がない場合は | より前の部分をそのまま表示します
+func newWikiLink(inner string) *Node {
+	target, display, _ := strings.Cut(inner, "|")
+	if display == "" {
+		display = target
+	}
+	path, heading := parseLinkTarget(target)
+	return &Node{
+		Kind:        NodeKindWikiLink,
+		Literal:     target,
+		Destination: path,
+		Fragment:    heading,
+		Children:    []*Node{{Kind: NodeKindText, Literal: display}},
+	}
+}
+
+// matchCodeSpan は同じ長さのバッククォートで閉じられたコードを探します
+func matchCodeSpan(rest string) (int, string, bool) {
+	n := len(rest) - len(strings.TrimLeft(rest, "`"))
+	for j := n; j < len(rest); {
+		k := strings.IndexByte(rest[j:], '`')
+		if k < 0 {
+			return 0, "", false
+		}
+		j += k
+		m := len(rest[j:]) - len(strings.TrimLeft(rest[j:], "`"))
+		if m == n {
+			inner := strings.ReplaceAll(rest[n:j], "\n", " ")
+			if len(inner) >= 2 && inner[0] == ' ' && inner[len(inner)-1] == ' ' && strings.Trim(inner, " ") != "" {
+				inner = inner[1 : len(inner)-1]
+			}
+			return j + m, inner, true
+		}
+		j += m
+	}
+	return 0, "", false
+}
+
+// matchMath は remark-math と同じく、同じ数の $ で閉じられた数式を探します
+func matchMath(rest string) (int, string, bool) {
+	n := len(rest) - len(strings.TrimLeft(rest, "$"))
+	if n > 2 {
+		return 0, "", false
+	}
+	for j := n; j < len(rest); {
+		k := strings.IndexAny(rest[j:], "$\\")
+		if k < 0 {
+			return 0, "", false
+		}
+		j += k
+		if rest[j] == '\\' {
+			j += 2
+			continue
+		}
+		m := len(rest[j:]) - len(strings.TrimLeft(rest[j:], "$"))
+		if m == n && j > n {
+			return j + m, rest[n:j], true
+		}
+		j += m
+	}
+	return 0, "", false
+}
+
+// matchInlineLink は [text](destination "title") を読み取ります
+func matchInlineLink(rest string) (int, string, string, string, bool) {
+	depth := 0
+	closing := -1
+	for j := 0; j < len(rest) && closing < 0; j++ {
+		switch rest[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		case '`':
+			if end, _, ok := matchCodeSpan(rest[j:]); ok {
+				j += end - 1
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(rest) || rest[closing+1] != '(' {
+		return 0, "", "", "", false
+	}
+	label := rest[1:closing]
+
+	j := closing + 2
+	skipSpace := func() {
+		for j < len(rest) && (rest[j] == ' ' || rest[j] == '\t' || rest[j] == '\n') {
+			j++
+		}
+	}
+	skipSpace()
+
+	var dest strings.Builder
+	if j < len(rest) && rest[j] == '<' {
+		end := strings.IndexAny(rest[j+1:], ">\n")
+		if end < 0 || rest[j+1+end] != '>' {
+			return 0, "", "", "", false
+		}
+		dest.WriteString(rest[j+1 : j+1+end])
+		j += end + 2
+	} else {
+		parens := 0
+	dest:
+		for ; j < len(rest); j++ {
+			switch c := rest[j]; {
+			case c == '\\' && j+1 < len(rest) && isASCIIPunct(rest[j+1]):
+				dest.WriteByte(rest[j+1])
+				j++
+			case c == '(':
+				parens++
+				dest.WriteByte(c)
+			case c == ')':
+				if parens == 0 {
+					break dest
+				}
+				parens--
+				dest.WriteByte(c)
+			case c == ' ' || c == '\t' || c == '\n':
+				break dest
+			default:
+				dest.WriteByte(c)
+			}
+		}
+	}
+	skipSpace()
+
+	title := ""
+	if j < len(rest) && strings.IndexByte(`"'(`, rest[j]) >= 0 {
+		closer := rest[j]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := strings.IndexByte(rest[j+1:], closer)
+		if end < 0 {
+			return 0, "", "", "", false
+		}
+		title = rest[j+1 : j+1+end]
+		j += end + 2
+		skipSpace()
+	}
+	if j >= len(rest) || rest[j] != ')' {
+		return 0, "", "", "", false
+	}
+	return j + 1, label, html.UnescapeString(dest.String()), title, true
+}
+
+type delimiterMatch struct {
+	node *Node
+	end  int
+	ok   bool
+}
+
+// matchDelimited は *・_・~ で囲まれた強調と取り消し線を読み取り、要素と終わりの位置を返します。
+// 閉じの記号を探すときに内側の強調も読むので、同じ位置の結果は memo に残して使い回します
+func matchDelimited(text string, i int, memo map[int]delimiterMatch) (*Node, int, bool) {
+	if m, ok := memo[i]; ok {
+		return m.node, m.end, m.ok
+	}
+	node, end, ok := matchDelimitedAt(text, i, memo)
+	memo[i] = delimiterMatch{node: node, end: end, ok: ok}
+	return node, end, ok
+}
+
+func matchDelimitedAt(text string, i int, memo map[int]delimiterMatch) (*Node, int, bool) {
+	c := text[i]
+	n := len(text[i:]) - len(strings.TrimLeft(text[i:], string(c)))
+	after := i + n
+	// 開きの記号の直後に空白があってはいけない
+	if after >= len(text) || text[after] == ' ' || text[after] == '\t' || text[after] == '\n' {
+		return nil, 0, false
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return nil, 0, false
+	}
+
+	if c == '~' {
+		if n > 2 {
+			return nil, 0, false
+		}
+		if end, ok := findCloser(text, after, c, n, memo); ok {
+			return &Node{Kind: NodeKindDelete, Children: parseInline(text[after:end])}, end + n, true
+		}
+		return nil, 0, false
+	}
+
+	if n >= 3 {
+		if end, ok := findCloser(text, after, c, 3, memo); ok {
+			strong := &Node{Kind: NodeKindStrong, Children: parseInline(text[after:end])}
+			return &Node{Kind: NodeKindEmphasis, Children: []*Node{strong}}, end + 3, true
+		}
+	}
+	for _, size := range []int{min(n, 2), 1} {
+		if end, ok := findCloser(text, i+size, c, size, memo); ok {
+			kind := NodeKindEmphasis
+			if size == 2 {
+				kind = NodeKindStrong
+			}
+			return &Node{Kind: kind, Children: parseInline(text[i+size : end])}, end + size, true
+		}
+	}
+	return nil, 0, false
+}
+
+// findCloser は from 以降で c が size 個以上続く閉じの記号を探します。コードと数式の中は読み飛ばします
+func findCloser(text string, from int, c byte, size int, memo map[int]delimiterMatch) (int, bool) {
+	for j := from; j < len(text); {
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if end, _, ok := matchCodeSpan(text[j:]); ok {
+				j += end
+				continue
+			}
+		case '$':
+			if end, _, ok := matchMath(text[j:]); ok {
+				j += end
+				continue
+			}
+		}
+		if text[j] != c {
+			j++
+			continue
+		}
+		m := len(text[j:]) - len(strings.TrimLeft(text[j:], string(c)))
+		rightFlanking := j > from && text[j-1] != ' ' && text[j-1] != '\t' && text[j-1] != '\n'
+		if c == '_' && j+m < len(text) && isWordByte(text[j+m]) {
+			rightFlanking = false
+		}
+		if rightFlanking && m >= size && (c != '~' || m == size) {
+			return j, true
+		}
+		// 内側の強調は閉じの記号まで読み飛ばす
+		if _, end, ok := matchDelimited(text, j, memo); ok {
+			j = end
+			continue
+		}
+		j += m
+	}
+	return 0, false
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
+
+// trimAutolinkLiteral は GFM と同じく、URL の末尾の句読点と対応しない ) を取り除きます
+func trimAutolinkLiteral(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte("?!.,:*_~'\"", last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, ")") > strings.Count(url, "("):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
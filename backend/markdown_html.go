@@ -0,0 +1,394 @@
+package backend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HTMLRenderOptions は構文木を HTML にするときの、プロジェクトに依存する部分を指定します
+type HTMLRenderOptions struct {
+	// ProjectRoot は ![[...]] の画像の URL を作るときのプロジェクトのルートです
+	ProjectRoot string
+	// ImageURL は ![[name]] の画像の URL を返します。nil の場合はプレビューと同じく file:/// の URL になります
+	ImageURL func(name string) string
+	// WikiLinkURL は [[...]] のリンク先を返します。nil の場合や ok が false の場合は、
+	// プレビューと同じく data-internal-link 属性を持つリンクになります
+	WikiLinkURL func(node *Node) (url string, ok bool)
+	// Math は数式の要素を返します。nil の場合は rehype-katex を通す前の <code class="language-math ..."> になります
+	Math func(tex string, display bool) string
+	// ExpandMath は Math や <code class="language-math ..."> に渡す前に数式を書き換えます。プロジェクトのマクロの展開に使います
+	ExpandMath func(tex string) string
+	// Theorems は定理環境の表示名と番号の付け方です。Environments が空の場合は既定の定理環境を使います
+	Theorems TheoremSettings
+	// XHTML が true の場合は EPUB などの XHTML 文書に入れられるように、空要素を <br /> の形で閉じ、
+	// ノートに直接書かれた HTML は出力しません
+	XHTML bool
+}
+
+var (
+	htmlTextEscaper      = strings.NewReplacer("&", "&#x26;", "<", "&#x3C;")
+	htmlAttributeEscaper = strings.NewReplacer("&", "&#x26;", `"`, "&#x22;")
+
+	xhtmlTextEscaper      = strings.NewReplacer("&", "&#x26;", "<", "&#x3C;", ">", "&#x3E;")
+	xhtmlAttributeEscaper = strings.NewReplacer("&", "&#x26;", `"`, "&#x22;", "<", "&#x3C;")
+)
+
+// RenderHTML は構文木をプレビューの markdownToHtml と同じ HTML にします。
+// 数式は rehype-katex を通す前の <code class="language-math ..."> のまま、コードはハイライトせずに出力します。
+// フロントマターは出力しません
+func RenderHTML(doc *Node, opts HTMLRenderOptions) string {
+	if len(opts.Theorems.Environments) == 0 {
+		opts.Theorems = getDefaultTheoremSettings()
+	}
+	r := &htmlRenderer{opts: opts, numbering: newTheoremNumbering(opts.Theorems)}
+	return r.blocks(doc.Children)
+}
+
+type htmlRenderer struct {
+	opts      HTMLRenderOptions
+	numbering *theoremNumbering
+}
+
+func (r *htmlRenderer) math(tex string) string {
+	if r.opts.ExpandMath != nil {
+		return r.opts.ExpandMath(tex)
+	}
+	return tex
+}
+
+func (r *htmlRenderer) blocks(nodes []*Node) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Kind == NodeKindFrontmatter {
+			continue
+		}
+		parts = append(parts, r.block(node))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (r *htmlRenderer) inlines(nodes []*Node) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		b.WriteString(r.inline(node))
+	}
+	return b.String()
+}
+
+func (r *htmlRenderer) block(node *Node) string {
+	switch node.Kind {
+	case NodeKindParagraph:
+		return "<p>" + r.inlines(node.Children) + "</p>"
+
+	case NodeKindHeading:
+		return fmt.Sprintf(`<h%d id="%s">%s</h%d>`, node.Level, r.attr(node.Slug), r.inlines(node.Children), node.Level)
+
+	case NodeKindThematicBreak:
+		return "<hr" + r.voidEnd()
+
+	case NodeKindBlockquote:
+		return "<blockquote>\n" + wrapLines(r.blocks(node.Children)) + "</blockquote>"
+
+	case NodeKindList:
+		return r.list(node)
+
+	case NodeKindCodeBlock:
+		class := ""
+		if node.Info != "" {
+			class = ` class="language-` + r.attr(node.Info) + `"`
+		}
+		return "<pre><code" + class + ">" + r.text(withNewline(node.Literal)) + "</code></pre>"
+
+	case NodeKindMathBlock:
+		tex := r.math(node.Literal)
+		if r.opts.Math != nil {
+			return r.opts.Math(tex, true)
+		}
+		return `<pre><code class="language-math math-display">` + r.text(withNewline(tex)) + "</code></pre>"
+
+	case NodeKindMermaid:
+		return `<div class="mermaid">` + r.text(withNewline(node.Literal)) + "</div>"
+
+	case NodeKindCardLink:
+		return r.cardLink(node.Attrs)
+
+	case NodeKindTable:
+		return r.table(node)
+
+	case NodeKindHTMLBlock:
+		if r.opts.XHTML {
+			return ""
+		}
+		return node.Literal
+
+	case NodeKindTheorem:
+		// 番号付きの表示名は slug を付けた後に見出しに加わるので、id には影響しない
+		label := `<span class="theorem-label">` + r.text(r.numbering.next(node)) + "</span>"
+		return `<div class="theorem" data-environment="` + r.attr(node.Environment) + `"><h4 class="theorem-title" id="` +
+			r.attr(node.Slug) + `">` + label + r.text(node.Title) + `</h4><div class="theorem-content">` +
+			r.blocks(node.Children) + "</div></div>"
+
+	case NodeKindDetails:
+		return "<details>\n<summary>" + r.text(node.Title) + "</summary>\n" + wrapLines(r.blocks(node.Children)) + "</details>"
+	}
+	return ""
+}
+
+// withNewline は mdast-util-to-hast と同じく、空でないコードの末尾に改行を付けます
+func withNewline(code string) string {
+	if code == "" {
+		return ""
+	}
+	return code + "\n"
+}
+
+func wrapLines(content string) string {
+	if content == "" {
+		return ""
+	}
+	return content + "\n"
+}
+
+func (r *htmlRenderer) list(node *Node) string {
+	tag := "ul"
+	attrs := ""
+	if node.Ordered {
+		tag = "ol"
+		if node.Start != 1 {
+			attrs += ` start="` + strconv.Itoa(node.Start) + `"`
+		}
+	}
+	for _, item := range node.Children {
+		if item.Task {
+			attrs += ` class="contains-task-list"`
+			break
+		}
+	}
+
+	items := make([]string, 0, len(node.Children))
+	for _, item := range node.Children {
+		items = append(items, r.listItem(item, !node.Tight))
+	}
+	return "<" + tag + attrs + ">\n" + wrapLines(strings.Join(items, "\n")) + "</" + tag + ">"
+}
+
+// listItem は mdast-util-to-hast と同じく、詰まったリストでは段落の <p> を省きます
+func (r *htmlRenderer) listItem(item *Node, loose bool) string {
+	var b strings.Builder
+	if item.Task {
+		b.WriteString(`<li class="task-list-item">`)
+	} else {
+		b.WriteString("<li>")
+	}
+
+	children := item.Children
+	if item.Task {
+		checkbox := `<input type="checkbox" disabled`
+		if item.Checked {
+			checkbox = `<input type="checkbox" checked disabled`
+		}
+		if r.opts.XHTML {
+			checkbox = strings.NewReplacer(" checked", ` checked="checked"`, " disabled", ` disabled="disabled"`).Replace(checkbox)
+		}
+		checkbox += r.voidEnd()
+		if len(children) > 0 && children[0].Kind == NodeKindParagraph {
+			paragraph := *children[0]
+			paragraph.Children = append([]*Node{{Kind: NodeKindHTMLInline, Literal: checkbox + " ", Task: true}}, paragraph.Children...)
+			children = append([]*Node{&paragraph}, children[1:]...)
+		} else {
+			children = append([]*Node{{Kind: NodeKindParagraph, Children: []*Node{{Kind: NodeKindHTMLInline, Literal: checkbox, Task: true}}}}, children...)
+		}
+	}
+
+	for i, child := range children {
+		paragraph := child.Kind == NodeKindParagraph
+		if loose || i != 0 || !paragraph {
+			b.WriteString("\n")
+		}
+		if paragraph && !loose {
+			b.WriteString(r.inlines(child.Children))
+		} else {
+			b.WriteString(r.block(child))
+		}
+	}
+	if len(children) > 0 && (loose || children[len(children)-1].Kind != NodeKindParagraph) {
+		b.WriteString("\n")
+	}
+	b.WriteString("</li>")
+	return b.String()
+}
+
+func (r *htmlRenderer) table(node *Node) string {
+	row := func(row *Node, cellTag string) string {
+		var b strings.Builder
+		b.WriteString("<tr>\n")
+		for _, cell := range row.Children {
+			align := ""
+			if cell.Align != "" {
+				align = ` align="` + cell.Align + `"`
+			}
+			b.WriteString("<" + cellTag + align + ">" + r.inlines(cell.Children) + "</" + cellTag + ">\n")
+		}
+		b.WriteString("</tr>")
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString("<table>\n<thead>\n" + row(node.Children[0], "th") + "\n</thead>")
+	if len(node.Children) > 1 {
+		rows := make([]string, 0, len(node.Children)-1)
+		for _, r := range node.Children[1:] {
+			rows = append(rows, row(r, "td"))
+		}
+		b.WriteString("\n<tbody>\n" + strings.Join(rows, "\n") + "\n</tbody>")
+	}
+	b.WriteString("\n</table>")
+	return b.String()
+}
+
+// cardLink はプレビューの makeCardLinkElement と同じ要素を作ります
+func (r *htmlRenderer) cardLink(attrs map[string]string) string {
+	var b strings.Builder
+	b.WriteString(`<div class="card-link-container"><a href="` + r.attr(attrs["url"]) +
+		`" class="card-link" target="_blank" rel="noopener noreferrer">`)
+	b.WriteString(`<div class="card-content"><p class="card-title">` + r.text(attrs["title"]) + "</p>")
+	b.WriteString(`<p class="card-description">` + r.text(attrs["description"]) + "</p>")
+	b.WriteString(`<div class="card-footer"><img`)
+	if favicon, ok := attrs["favicon"]; ok {
+		b.WriteString(` src="` + r.attr(favicon) + `"`)
+	}
+	b.WriteString(` alt="site favicon" class="card-favicon"` + r.voidEnd() + `<span class="card-url">` + r.text(attrs["host"]) + "</span></div></div>")
+	if image := attrs["image"]; image != "" {
+		b.WriteString(`<div class="card-thumbnail"><img src="` + r.attr(image) + `" alt="` +
+			r.attr(attrs["title"]+" Logo") + `"` + r.voidEnd() + `</div>`)
+	}
+	b.WriteString("</a></div>")
+	return b.String()
+}
+
+func (r *htmlRenderer) inline(node *Node) string {
+	switch node.Kind {
+	case NodeKindText:
+		return r.text(node.Literal)
+	case NodeKindBreak:
+		return "<br" + r.voidEnd() + "\n"
+	case NodeKindEmphasis:
+		return "<em>" + r.inlines(node.Children) + "</em>"
+	case NodeKindStrong:
+		return "<strong>" + r.inlines(node.Children) + "</strong>"
+	case NodeKindDelete:
+		return "<del>" + r.inlines(node.Children) + "</del>"
+	case NodeKindCode:
+		return "<code>" + r.text(node.Literal) + "</code>"
+	case NodeKindMath:
+		tex := r.math(node.Literal)
+		if r.opts.Math != nil {
+			return r.opts.Math(tex, node.Display)
+		}
+		return `<code class="language-math math-inline">` + r.text(tex) + "</code>"
+	case NodeKindHTMLInline:
+		// リストのチェックボックス (Task) 以外は、XHTML として正しい保証がないため出力しません
+		if r.opts.XHTML && !node.Task {
+			return ""
+		}
+		return node.Literal
+
+	case NodeKindLink:
+		title := ""
+		if node.Title != "" {
+			title = ` title="` + r.attr(node.Title) + `"`
+		}
+		return `<a href="` + r.attr(normalizeURI(node.Destination)) + `"` + title + ">" + r.inlines(node.Children) + "</a>"
+
+	case NodeKindImage:
+		title := ""
+		if node.Title != "" {
+			title = ` title="` + r.attr(node.Title) + `"`
+		}
+		return `<img src="` + r.attr(normalizeURI(node.Destination)) + `" alt="` +
+			r.attr(node.Literal) + `"` + title + r.voidEnd()
+
+	case NodeKindEmbed:
+		var src string
+		if r.opts.ImageURL != nil {
+			src = r.opts.ImageURL(node.Destination)
+		} else {
+			root := strings.ReplaceAll(r.opts.ProjectRoot, `\`, "/")
+			src = normalizeURI("file:///" + root + "/" + imagesDirName + "/" + encodeURIComponent(node.Destination))
+		}
+		return `<img src="` + r.attr(src) + `" alt="` + r.attr(node.Destination) + `"` + r.voidEnd()
+
+	case NodeKindWikiLink:
+		if r.opts.WikiLinkURL != nil {
+			if url, ok := r.opts.WikiLinkURL(node); ok {
+				return `<a href="` + r.attr(url) + `">` + r.inlines(node.Children) + "</a>"
+			}
+		}
+		return `<a href="#" data-internal-link="true" data-path="` + r.attr(node.Literal) + `">` +
+			r.inlines(node.Children) + "</a>"
+	}
+	return ""
+}
+
+// text はテキストをエスケープします。XHTML の場合は ]]> が現れないように > もエスケープします
+func (r *htmlRenderer) text(s string) string {
+	if r.opts.XHTML {
+		return xhtmlTextEscaper.Replace(s)
+	}
+	return htmlTextEscaper.Replace(s)
+}
+
+// attr は属性の値をエスケープします。XHTML の場合は属性の値に書けない < もエスケープします
+func (r *htmlRenderer) attr(s string) string {
+	if r.opts.XHTML {
+		return xhtmlAttributeEscaper.Replace(s)
+	}
+	return htmlAttributeEscaper.Replace(s)
+}
+
+// voidEnd は <br> などの空要素の終わりを返します
+func (r *htmlRenderer) voidEnd() string {
+	if r.opts.XHTML {
+		return " />"
+	}
+	return ">"
+}
+
+// encodeURIComponent は JavaScript の encodeURIComponent と同じくエスケープします
+func encodeURIComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.!~*'()", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// normalizeURI は micromark の normalizeUri と同じく、URL に使えない文字だけをエスケープします。
+// すでにエスケープされた %XX はそのままにします
+func normalizeURI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHexByte(s[i+1]) && isHexByte(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexByte("!#$&'()*+,-./:;=?@[]_~", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isHexByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRenderHTMLGolden は testdata/markdown の .md を HTML にして、プレビューの markdownToHtml の出力
+// (rehype-katex と rehype-highlight を通す前のもの) と比較します
+func TestRenderHTMLGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if err != nil {
+		t.Fatalf("Failed to list golden files: %v", err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("No golden files found")
+	}
+
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			markdown, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatalf("Failed to read input: %v", err)
+			}
+			expected, err := os.ReadFile(strings.TrimSuffix(input, ".md") + ".html")
+			if err != nil {
+				t.Fatalf("Failed to read golden output: %v", err)
+			}
+
+			got := RenderHTML(ParseMarkdown(string(markdown)), HTMLRenderOptions{ProjectRoot: "/vault"})
+			if got != strings.TrimRight(string(expected), "\n") {
+				t.Errorf("Rendered HTML does not match.\nGot:\n%s\nWant:\n%s", got, expected)
+			}
+		})
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	content := "---\n" + // 1
+		"aliases: [a]\n" + // 2
+		"---\n" + // 3
+		"# 定理\n" + // 4
+		"<theorem name=\"X\">\n" + // 5
+		"see [[other#主張|Y]]\n" + // 6
+		"</theorem>\n" + // 7
+		"```\n" + // 8
+		"<theorem name=\"Z\">\n" + // 9
+		"```" // 10
+
+	doc := ParseMarkdown(content)
+	kinds := make([]string, 0, len(doc.Children))
+	for _, child := range doc.Children {
+		kinds = append(kinds, child.Kind)
+	}
+	expectedKinds := []string{NodeKindFrontmatter, NodeKindHeading, NodeKindTheorem, NodeKindCodeBlock}
+	if !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Fatalf("Expected blocks %v, but got %v", expectedKinds, kinds)
+	}
+
+	theorem := doc.Children[2]
+	if theorem.Title != "X" || theorem.Slug != "x" || theorem.StartLine != 5 || theorem.EndLine != 7 {
+		t.Errorf("Unexpected theorem node: %+v", theorem)
+	}
+	link := theorem.Children[0].Children[1]
+	expectedLink := &Node{
+		Kind:        NodeKindWikiLink,
+		Literal:     "other#主張",
+		Destination: "other",
+		Fragment:    "主張",
+		Children:    []*Node{{Kind: NodeKindText, Literal: "Y"}},
+	}
+	if !reflect.DeepEqual(link, expectedLink) {
+		t.Errorf("Expected wiki link %+v, but got %+v", expectedLink, link)
+	}
+	if code := doc.Children[3]; code.Literal != "<theorem name=\"Z\">" || code.StartLine != 8 || code.EndLine != 10 {
+		t.Errorf("Unexpected code block: %+v", code)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"***a***", "<em><strong>a</strong></em>"},
+		{"*a **b** c*", "<em>a <strong>b</strong> c</em>"},
+		{"snake_case_name", "snake_case_name"},
+		{"**a*", "**a*"},
+		{"$a * b$ *c*", `<code class="language-math math-inline">a * b</code> <em>c</em>`},
+		{"`` a`b ``", "<code>a`b</code>"},
+		{"x <span>y</span>", "x <span>y</span>"},
+	}
+	r := &htmlRenderer{}
+	for _, tt := range tests {
+		if got := r.inlines(parseInline(tt.input)); got != tt.want {
+			t.Errorf("parseInline(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package backend
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// mathFontVariants は \mathbb などのフォントの命令に対応する MathML の mathvariant です
+var mathFontVariants = map[string]string{
+	"mathbb":     "double-struck",
+	"mathcal":    "script",
+	"mathscr":    "script",
+	"mathfrak":   "fraktur",
+	"mathbf":     "bold",
+	"boldsymbol": "bold-italic",
+	"bm":         "bold-italic",
+	"mathit":     "italic",
+	"mathrm":     "normal",
+	"mathsf":     "sans-serif",
+	"mathtt":     "monospace",
+}
+
+// mathIdentifiers は <mi> になる記号の命令です
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "emptyset": "∅", "varnothing": "∅", "partial": "∂", "nabla": "∇",
+	"ell": "ℓ", "hbar": "ℏ", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ", "wp": "℘",
+	"top": "⊤", "bot": "⊥", "angle": "∠", "triangle": "△", "%": "%", "#": "#", "$": "$", "_": "_",
+}
+
+// mathOperators は <mo> になる記号の命令です
+var mathOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "cdots": "⋯", "ldots": "…",
+	"dots": "…", "vdots": "⋮", "ddots": "⋱", "circ": "∘", "ast": "∗", "star": "⋆", "bullet": "∙",
+	"oplus": "⊕", "otimes": "⊗", "cap": "∩", "cup": "∪", "setminus": "∖", "wedge": "∧",
+	"land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "ne": "≠", "neq": "≠", "equiv": "≡",
+	"approx": "≈", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "subsetneq": "⊊",
+	"supset": "⊃", "supseteq": "⊇", "supsetneq": "⊋",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸",
+	"iff": "⟺", "mapsto": "↦", "longrightarrow": "⟶", "longleftarrow": "⟵", "longmapsto": "⟼",
+	"hookrightarrow": "↪", "uparrow": "↑", "downarrow": "↓",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "mid": "∣", "parallel": "∥", "perp": "⊥",
+	"vdash": "⊢", "models": "⊨", "colon": ":", "prime": "′",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"lbrace": "{", "rbrace": "}", "vert": "|", "Vert": "‖", "{": "{", "}": "}", "|": "‖", "&": "&",
+}
+
+// mathLargeOperators は上下に添字を置ける大きな演算子です
+var mathLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁",
+	"bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀", "bigsqcup": "⨆",
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// mathFunctions は立体で書く関数名です。値が true のものは別行立ての数式で添字を下に置きます
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false,
+	"log": false, "ln": false, "exp": false, "det": true, "dim": false, "ker": false, "deg": false,
+	"gcd": true, "hom": false, "arg": false,
+	"lim": true, "limsup": true, "liminf": true, "max": true, "min": true, "sup": true, "inf": true, "Pr": true,
+}
+
+var mathSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", "!": "-0.1667em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+type mathAccent struct {
+	mark     string
+	under    bool
+	stretchy bool
+}
+
+var mathAccents = map[string]mathAccent{
+	"hat": {mark: "^"}, "widehat": {mark: "^", stretchy: true}, "bar": {mark: "¯"},
+	"overline": {mark: "‾", stretchy: true}, "underline": {mark: "‾", under: true, stretchy: true},
+	"vec": {mark: "→"}, "overrightarrow": {mark: "→", stretchy: true}, "tilde": {mark: "~"},
+	"widetilde": {mark: "~", stretchy: true}, "dot": {mark: "˙"}, "ddot": {mark: "¨"},
+	"overbrace": {mark: "⏞", stretchy: true}, "underbrace": {mark: "⏟", under: true, stretchy: true},
+}
+
+// mathBigSizes は \big などで指定する括弧の大きさです
+var mathBigSizes = map[string]string{
+	"big": "1.2em", "Big": "1.623em", "bigg": "2.047em", "Bigg": "2.470em",
+}
+
+// mathEnvironmentFences は行列の環境を囲む括弧です
+var mathEnvironmentFences = map[string][2]string{
+	"pmatrix": {"(", ")"}, "bmatrix": {"[", "]"}, "Bmatrix": {"{", "}"},
+	"vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"}, "cases": {"{", ""},
+}
+
+var mathmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// texToMathML は KaTeX で書かれた数式を MathML にします。
+// 対応していない命令は <merror> として元の命令を残し、元の数式は <annotation> に保持します
+func texToMathML(tex string, display bool) string {
+	p := &mathmlParser{tokens: tokenizeTeX(tex), display: display}
+	var items []string
+	for p.peek() != "" {
+		items = append(items, p.parseRow()...)
+		if p.peek() != "" {
+			// 対応する { のない } を読み飛ばします
+			p.pos++
+		}
+	}
+
+	attrs := ""
+	if display {
+		attrs = ` display="block"`
+	}
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML"` + attrs + "><semantics><mrow>" + strings.Join(items, "") +
+		`</mrow><annotation encoding="application/x-tex">` + mathmlEscaper.Replace(tex) + "</annotation></semantics></math>"
+}
+
+// tokenizeTeX は数式を命令、1 文字、空白に分けます。連続する空白は 1 つの " " にまとめます
+func tokenizeTeX(tex string) []string {
+	var tokens []string
+	runes := []rune(tex)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				i++
+			}
+			tokens = append(tokens, " ")
+		case r == '\\' && i+1 < len(runes):
+			j := i + 1
+			for j < len(runes) && (runes[j] >= 'a' && runes[j] <= 'z' || runes[j] >= 'A' && runes[j] <= 'Z') {
+				j++
+			}
+			if j == i+1 {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j - 1
+		default:
+			tokens = append(tokens, string(r))
+		}
+	}
+	return tokens
+}
+
+type mathmlParser struct {
+	tokens  []string
+	pos     int
+	display bool
+	variant string
+}
+
+// peek は空白を読み飛ばして次のトークンを返します。終わりに達した場合は空文字列を返します
+func (p *mathmlParser) peek() string {
+	for p.pos < len(p.tokens) && p.tokens[p.pos] == " " {
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *mathmlParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+// parseRow は }、終わり、stops のいずれかに達するまでの要素を返します。終わりのトークンは読みません
+func (p *mathmlParser) parseRow(stops ...string) []string {
+	var items []string
+	for {
+		tok := p.peek()
+		if tok == "" || tok == "}" || slices.Contains(stops, tok) {
+			return items
+		}
+		p.pos++
+		base, limits := p.parseAtom(tok)
+		if item := p.parseScripts(base, limits); item != "" {
+			items = append(items, item)
+		}
+	}
+}
+
+// parseScripts は base に続く上付き・下付きの添字を読みます
+func (p *mathmlParser) parseScripts(base string, limits bool) string {
+	var sub, sup, primes string
+	hasSub, hasSup := false, false
+scripts:
+	for {
+		switch p.peek() {
+		case "_":
+			p.pos++
+			sub, hasSub = p.parseArgument(), true
+		case "^":
+			p.pos++
+			sup, hasSup = p.parseArgument(), true
+		case "'":
+			p.pos++
+			primes += "′"
+		case `\limits`:
+			p.pos++
+			limits = true
+		case `\nolimits`:
+			p.pos++
+			limits = false
+		default:
+			break scripts
+		}
+	}
+	if primes != "" {
+		items := []string{"<mo>" + primes + "</mo>"}
+		if hasSup {
+			items = append(items, sup)
+		}
+		sup, hasSup = mathRow(items), true
+	}
+	if !hasSub && !hasSup {
+		return base
+	}
+	if base == "" {
+		base = "<mrow></mrow>"
+	}
+
+	under, over := "msub", "msup"
+	both := "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case hasSub && hasSup:
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">"
+	case hasSub:
+		return "<" + under + ">" + base + sub + "</" + under + ">"
+	default:
+		return "<" + over + ">" + base + sup + "</" + over + ">"
+	}
+}
+
+// parseArgument は命令の引数を 1 つ読みます。{...} の場合はまとめて 1 つの要素にします
+func (p *mathmlParser) parseArgument() string {
+	tok := p.next()
+	if tok == "" {
+		return "<mrow></mrow>"
+	}
+	if tok == "{" {
+		return p.parseGroup()
+	}
+	atom, _ := p.parseAtom(tok)
+	if atom == "" {
+		return "<mrow></mrow>"
+	}
+	return atom
+}
+
+// parseGroup は { の後から対応する } までを読みます
+func (p *mathmlParser) parseGroup() string {
+	items := p.parseRow()
+	if p.peek() == "}" {
+		p.pos++
+	}
+	return mathRow(items)
+}
+
+// readRawGroup は {...} の中身を空白も含めてそのまま返します。{ で始まらない場合は次のトークンだけを返します
+func (p *mathmlParser) readRawGroup() []string {
+	tok := p.next()
+	if tok != "{" {
+		if tok == "" {
+			return nil
+		}
+		return []string{tok}
+	}
+	start, depth := p.pos, 1
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos] {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.tokens[start : p.pos-1]
+			}
+		}
+	}
+	return p.tokens[start:]
+}
+
+func (p *mathmlParser) parseAtom(tok string) (string, bool) {
+	switch {
+	case tok == "{":
+		return p.parseGroup(), false
+	case tok == "&" || tok == `\\`:
+		return "", false
+	case tok >= "0" && tok <= "9" && len(tok) == 1:
+		number := tok
+		for next := p.peek(); len(next) == 1 && (next[0] >= '0' && next[0] <= '9' || next == "."); next = p.peek() {
+			number += next
+			p.pos++
+		}
+		if strings.HasSuffix(number, ".") {
+			return "<mn>" + strings.TrimSuffix(number, ".") + "</mn><mo>.</mo>", false
+		}
+		return "<mn>" + number + "</mn>", false
+	case tok == "'":
+		return "<mo>′</mo>", false
+	case tok == "~":
+		return `<mspace width="0.3333em" />`, false
+	case tok == "-":
+		return "<mo>−</mo>", false
+	case tok == "*":
+		return "<mo>∗</mo>", false
+	case strings.HasPrefix(tok, `\`) && len(tok) > 1:
+		return p.parseCommand(tok[1:])
+	}
+
+	if r := []rune(tok)[0]; unicode.IsLetter(r) {
+		if r > unicode.MaxASCII && p.variant == "" && !unicode.In(r, unicode.Greek) {
+			return "<mtext>" + mathmlEscaper.Replace(tok) + "</mtext>", false
+		}
+		return p.identifier(tok), false
+	}
+	return "<mo>" + mathmlEscaper.Replace(tok) + "</mo>", false
+}
+
+func (p *mathmlParser) identifier(text string) string {
+	variant := p.variant
+	if variant == "" && text >= "Α" && text <= "Ω" {
+		variant = "normal"
+	}
+	if variant == "" {
+		return "<mi>" + mathmlEscaper.Replace(text) + "</mi>"
+	}
+	return `<mi mathvariant="` + variant + `">` + mathmlEscaper.Replace(text) + "</mi>"
+}
+
+func (p *mathmlParser) parseCommand(name string) (string, bool) {
+	if text, ok := mathIdentifiers[name]; ok {
+		return p.identifier(text), false
+	}
+	if text, ok := mathOperators[name]; ok {
+		return "<mo>" + mathmlEscaper.Replace(text) + "</mo>", false
+	}
+	if text, ok := mathLargeOperators[name]; ok {
+		return `<mo largeop="true">` + text + "</mo>", p.display && !strings.Contains(name, "int")
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		return "<mi>" + name + "</mi>", p.display && limits
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return `<mspace width="` + width + `" />`, false
+	}
+	if variant, ok := mathFontVariants[name]; ok {
+		saved := p.variant
+		p.variant = variant
+		arg := p.parseArgument()
+		p.variant = saved
+		return arg, false
+	}
+	if accent, ok := mathAccents[name]; ok {
+		arg := p.parseArgument()
+		mark := `<mo stretchy="false">` + accent.mark + "</mo>"
+		if accent.stretchy {
+			mark = `<mo stretchy="true">` + accent.mark + "</mo>"
+		}
+		if accent.under {
+			return `<munder accentunder="true">` + arg + mark + "</munder>", false
+		}
+		return `<mover accent="true">` + arg + mark + "</mover>", false
+	}
+	if size, ok := mathBigSizes[strings.TrimRight(name, "lmr")]; ok {
+		return `<mo fence="true" minsize="` + size + `" maxsize="` + size + `">` + mathmlEscaper.Replace(p.delimiter()) + "</mo>", false
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.parseArgument()
+		return "<mfrac>" + num + p.parseArgument() + "</mfrac>", false
+	case "binom", "dbinom", "tbinom":
+		n := p.parseArgument()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + n + p.parseArgument() + "</mfrac><mo>)</mo></mrow>", false
+	case "sqrt":
+		if p.peek() == "[" {
+			p.pos++
+			index := mathRow(p.parseRow("]"))
+			if p.peek() == "]" {
+				p.pos++
+			}
+			return "<mroot>" + p.parseArgument() + index + "</mroot>", false
+		}
+		return "<msqrt>" + p.parseArgument() + "</msqrt>", false
+	case "text", "textrm", "textnormal", "textup", "mbox", "textbf", "textit":
+		return "<mtext>" + mathmlEscaper.Replace(mathText(p.readRawGroup())) + "</mtext>", false
+	case "operatorname":
+		limits := false
+		if p.peek() == "*" {
+			p.pos++
+			limits = p.display
+		}
+		text := mathText(p.readRawGroup())
+		if len([]rune(text)) == 1 {
+			return `<mi mathvariant="normal">` + mathmlEscaper.Replace(text) + "</mi>", limits
+		}
+		return "<mi>" + mathmlEscaper.Replace(text) + "</mi>", limits
+	case "overset", "stackrel", "underset":
+		script := p.parseArgument()
+		base := p.parseArgument()
+		if name == "underset" {
+			return "<munder>" + base + script + "</munder>", false
+		}
+		return "<mover>" + base + script + "</mover>", false
+	case "left":
+		return p.parseFenced(), false
+	case "right", "middle":
+		return `<mo fence="true">` + mathmlEscaper.Replace(p.delimiter()) + "</mo>", false
+	case "not":
+		tok := p.next()
+		text := strings.TrimPrefix(tok, `\`)
+		if op, ok := mathOperators[text]; ok {
+			text = op
+		}
+		return "<mo>" + mathmlEscaper.Replace(text) + "̸</mo>", false
+	case "bmod":
+		return `<mo lspace="0.2222em" rspace="0.2222em">mod</mo>`, false
+	case "mod", "pmod":
+		arg := p.parseArgument()
+		if name == "pmod" {
+			return `<mrow><mspace width="1em" /><mo>(</mo><mi>mod</mi><mspace width="0.3333em" />` + arg + "<mo>)</mo></mrow>", false
+		}
+		return `<mrow><mspace width="1em" /><mi>mod</mi><mspace width="0.3333em" />` + arg + "</mrow>", false
+	case "begin":
+		return p.parseEnvironment(mathText(p.readRawGroup())), false
+	case "end", "label", "tag", "color":
+		p.readRawGroup()
+		return "", false
+	case "textcolor":
+		p.readRawGroup()
+		return p.parseArgument(), false
+	case "displaystyle", "textstyle", "scriptstyle", "nonumber", "notag", "limits", "nolimits":
+		return "", false
+	}
+	return `<merror><mtext>\` + mathmlEscaper.Replace(name) + "</mtext></merror>", false
+}
+
+// delimiter は \left などに続く括弧を読みます。\left. のように . の場合は空文字列を返します
+func (p *mathmlParser) delimiter() string {
+	tok := p.next()
+	switch {
+	case tok == ".":
+		return ""
+	case strings.HasPrefix(tok, `\`):
+		if op, ok := mathOperators[tok[1:]]; ok {
+			return op
+		}
+		return tok[1:]
+	}
+	return tok
+}
+
+// parseFenced は \left から対応する \right までを読みます
+func (p *mathmlParser) parseFenced() string {
+	fence := func(delim string) string {
+		if delim == "" {
+			return ""
+		}
+		return `<mo fence="true" stretchy="true">` + mathmlEscaper.Replace(delim) + "</mo>"
+	}
+
+	items := []string{fence(p.delimiter())}
+	for {
+		items = append(items, p.parseRow(`\right`, `\middle`)...)
+		switch p.peek() {
+		case `\middle`:
+			p.pos++
+			items = append(items, fence(p.delimiter()))
+			continue
+		case `\right`:
+			p.pos++
+			items = append(items, fence(p.delimiter()))
+		}
+		return "<mrow>" + strings.Join(items, "") + "</mrow>"
+	}
+}
+
+// parseEnvironment は \begin{name} から \end{name} までを & と \\ で区切った表にします
+func (p *mathmlParser) parseEnvironment(name string) string {
+	base := strings.TrimSuffix(name, "*")
+	if base == "array" || base == "alignat" {
+		// 列の指定は読み飛ばします
+		p.readRawGroup()
+	}
+
+	var rows [][]string
+	var row []string
+cells:
+	for {
+		row = append(row, mathRow(p.parseRow("&", `\\`, `\end`)))
+		switch p.next() {
+		case "&":
+			continue
+		case `\\`:
+			rows = append(rows, row)
+			row = nil
+			if p.peek() == "[" {
+				// \\[2pt] のような行間の指定は読み飛ばします
+				p.parseRow("]")
+				p.next()
+			}
+		case `\end`:
+			p.readRawGroup()
+			rows = append(rows, row)
+			break cells
+		default:
+			rows = append(rows, row)
+			break cells
+		}
+	}
+	if last := rows[len(rows)-1]; len(rows) > 1 && len(last) == 1 && last[0] == "<mrow></mrow>" {
+		rows = rows[:len(rows)-1]
+	}
+
+	attrs := ""
+	switch base {
+	case "aligned", "align", "split", "eqnarray", "alignat", "alignedat":
+		attrs = ` columnalign="right left right left right left" columnspacing="0em 2em"`
+	case "cases":
+		attrs = ` columnalign="left left"`
+	}
+	var b strings.Builder
+	b.WriteString("<mtable" + attrs + ">")
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+
+	fences, ok := mathEnvironmentFences[base]
+	if !ok {
+		return b.String()
+	}
+	table := `<mo fence="true" stretchy="true">` + fences[0] + "</mo>" + b.String()
+	if fences[1] != "" {
+		table += `<mo fence="true" stretchy="true">` + fences[1] + "</mo>"
+	}
+	return "<mrow>" + table + "</mrow>"
+}
+
+// mathText は \text{...} の中身を文字列にします。MathML では前後の空白が詰められるため、空白は改行しない空白にします
+func mathText(tokens []string) string {
+	var b strings.Builder
+	for _, tok := range tokens {
+		switch {
+		case tok == " ":
+			b.WriteString("\u00a0")
+		case tok == "{" || tok == "}":
+		case strings.HasPrefix(tok, `\`) && len(tok) == 2:
+			b.WriteString(strings.Replace(tok[1:], " ", "\u00a0", 1))
+		case strings.HasPrefix(tok, `\`):
+		default:
+			b.WriteString(tok)
+		}
+	}
+	return b.String()
+}
+
+// mathRow は要素が 1 つの場合はそのまま、それ以外は <mrow> にまとめて返します
+func mathRow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
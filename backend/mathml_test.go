@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTeXToMathML(t *testing.T) {
+	tests := []struct {
+		tex     string
+		display bool
+		want    string
+	}{
+		{`x^2 + y_i'`, false, `<msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><msubsup><mi>y</mi><mi>i</mi><mo>′</mo></msubsup>`},
+		{`\frac{a}{b} \sqrt[3]{x}`, false, `<mfrac><mi>a</mi><mi>b</mi></mfrac><mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{`\sum_{n=1}^\infty`, true, `<munderover><mo largeop="true">∑</mo><mrow><mi>n</mi><mo>=</mo><mn>1</mn></mrow><mi>∞</mi></munderover>`},
+		{`\sum_{n=1}^\infty`, false, `<msubsup><mo largeop="true">∑</mo><mrow><mi>n</mi><mo>=</mo><mn>1</mn></mrow><mi>∞</mi></msubsup>`},
+		{`\mathbb{R} \Gamma`, false, `<mi mathvariant="double-struck">R</mi><mi mathvariant="normal">Γ</mi>`},
+		{`\text{ if } 3.14`, false, "<mtext> if </mtext><mn>3.14</mn>"},
+		{`\left( x \middle| y \right.`, false, `<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi><mo fence="true" stretchy="true">|</mo><mi>y</mi></mrow>`},
+		{`\begin{pmatrix} 1 & 2 \\ 3 & 4 \\ \end{pmatrix}`, true, `<mrow><mo fence="true" stretchy="true">(</mo><mtable><mtr><mtd><mn>1</mn></mtd><mtd><mn>2</mn></mtd></mtr><mtr><mtd><mn>3</mn></mtd><mtd><mn>4</mn></mtd></mtr></mtable><mo fence="true" stretchy="true">)</mo></mrow>`},
+		{`a < b \unknown`, false, `<mi>a</mi><mo>&lt;</mo><mi>b</mi><merror><mtext>\unknown</mtext></merror>`},
+		{`}x^{`, false, `<msup><mi>x</mi><mrow></mrow></msup>`},
+	}
+
+	for _, tt := range tests {
+		got := texToMathML(tt.tex, tt.display)
+		if !strings.Contains(got, "<semantics><mrow>"+tt.want+"</mrow><annotation") {
+			t.Errorf("texToMathML(%q, %v) = %s\nwant body %s", tt.tex, tt.display, got, tt.want)
+		}
+	}
+}
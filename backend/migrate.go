@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrUnsupportedSchemaVersion = errors.New("file was written by a newer version of theorem-note")
+
+// SchemaVersionError は .theorem-note のファイルが、このアプリより新しいバージョンで書かれていたことを表します
+type SchemaVersionError struct {
+	File      string
+	Version   int
+	Supported int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("%s has schema version %d, but this version of theorem-note supports up to %d; please update the app",
+		e.File, e.Version, e.Supported)
+}
+
+func (e *SchemaVersionError) Unwrap() error {
+	return ErrUnsupportedSchemaVersion
+}
+
+// sidecarMigration は .theorem-note のファイルの内容を 1 つ上のバージョンの形式に変換します。
+// 戻り値には新しいバージョンの version フィールドを含めます
+type sidecarMigration func(rootDir string, data []byte) ([]byte, error)
+
+// sidecarSchema は .theorem-note のファイルの形式です。migrations[i] はバージョン i から i+1 への変換で、
+// version フィールドのない JSON はバージョン 0 として扱います
+type sidecarSchema struct {
+	name       string
+	migrations []sidecarMigration
+}
+
+func (s sidecarSchema) version() int {
+	return len(s.migrations)
+}
+
+var (
+	// configSchema の バージョン 0 は version フィールドのない ProjectConfig です
+	configSchema = sidecarSchema{
+		name:       projectConfigFile,
+		migrations: []sidecarMigration{addSchemaVersion(1)},
+	}
+	// sessionSchema のバージョン 0 は、開いていたファイルのパスの配列です
+	sessionSchema = sidecarSchema{
+		name:       sessionFileName,
+		migrations: []sidecarMigration{migrateSessionV0},
+	}
+	// theoremsSchema のバージョン 0 は、定理名から定義したノートの絶対パスへの対応です
+	theoremsSchema = sidecarSchema{
+		name:       theoremsFileName,
+		migrations: []sidecarMigration{migrateTheoremsV0},
+	}
+	workspacesSchema = sidecarSchema{
+		name:       workspacesFileName,
+		migrations: []sidecarMigration{addSchemaVersion(1)},
+	}
+	// indexSchema のバージョン 0 は version フィールドのない NoteIndex です
+	indexSchema = sidecarSchema{
+		name:       indexFileName,
+		migrations: []sidecarMigration{addSchemaVersion(1)},
+	}
+	// historySchema のバージョン 0 は、操作の配列です
+	historySchema = sidecarSchema{
+		name:       historyFileName,
+		migrations: []sidecarMigration{migrateHistoryV0},
+	}
+)
+
+// schemaVersion は JSON のオブジェクトの version フィールドを返します。オブジェクトでない場合や version がない場合は 0 です
+func schemaVersion(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return 0, nil
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	return header.Version, nil
+}
+
+// loadSidecar は .theorem-note のファイルを読み込み、古いバージョンの場合は現在の形式に変換して書き戻します。
+// 変換する前の内容は <ファイル名>.v<バージョン>.bak に残します。ファイルがない場合は nil を返します
+func loadSidecar(rootDir string, path string, schema sidecarSchema) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	migrated, version, err := migrateSidecar(rootDir, schema, data)
+	if err != nil {
+		return nil, err
+	}
+	if version == schema.version() {
+		return data, nil
+	}
+	return migrated, writeMigratedSidecar(path, data, migrated, version)
+}
+
+// migrateSidecar は data を現在の形式に変換し、変換前のバージョンと一緒に返します。ファイルには書き込みません
+func migrateSidecar(rootDir string, schema sidecarSchema, data []byte) ([]byte, int, error) {
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > schema.version() {
+		return nil, 0, &SchemaVersionError{File: schema.name, Version: version, Supported: schema.version()}
+	}
+	for v := version; v < schema.version(); v++ {
+		if data, err = schema.migrations[v](rootDir, data); err != nil {
+			return nil, 0, fmt.Errorf("failed to migrate %s from version %d: %w", schema.name, v, err)
+		}
+	}
+	return data, version, nil
+}
+
+// writeMigratedSidecar は変換した内容を書き戻し、変換前の内容 original をバックアップに残します
+func writeMigratedSidecar(path string, original []byte, data []byte, version int) error {
+	recordAppWrite(path, data)
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Stat(backup); err == nil {
+		// 以前の移行のバックアップは上書きしない
+		return writeFileAtomic(path, data, 0644)
+	}
+	if err := os.WriteFile(backup, original, 0644); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// addSchemaVersion は形式を変えずに version フィールドだけを付ける変換です
+func addSchemaVersion(version int) sidecarMigration {
+	return func(rootDir string, data []byte) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["version"] = json.RawMessage(fmt.Sprint(version))
+		return json.MarshalIndent(fields, "", "  ")
+	}
+}
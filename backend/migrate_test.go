@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSidecar_Migration(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ensureSessionDirExists(tmpDir); err != nil {
+		t.Fatalf("Failed to create session dir: %v", err)
+	}
+	dir := filepath.Join(tmpDir, sessionDirPath)
+	legacy := map[string]string{
+		projectConfigFile: `{"font_settings":{"editor_font_family":"mono","editor_font_size":16,"preview_font_family":"serif","preview_font_size":18}}`,
+		sessionFileName:   `["/notes/a.md","/notes/b.md"]`,
+		theoremsFileName:  `{"Pythagoras":"` + filepath.ToSlash(filepath.Join(tmpDir, "geometry", "a.md")) + `"}`,
+		indexFileName:     `{"files":{"geometry/a.md":{"tags":["geometry"]}}}`,
+		historyFileName:   `[{"id":"op1","description":"置換","timestamp":"2024-01-01T00:00:00Z","changes":[{"path":"a.md","before":"x","after":"y"}]}]`,
+	}
+	for name, content := range legacy {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	config, err := LoadProjectConfig(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	expectedFonts := FontSettings{EditorFontFamily: "mono", EditorFontSize: 16, PreviewFontFamily: "serif", PreviewFontSize: 18}
+	if !reflect.DeepEqual(config.FontSettings, expectedFonts) {
+		t.Errorf("Expected %+v, but got %+v", expectedFonts, config.FontSettings)
+	}
+
+	state, err := LoadSessionState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadSessionState failed: %v", err)
+	}
+	if len(state.Tabs) != 2 || state.ActiveFile != "/notes/b.md" {
+		t.Errorf("Unexpected migrated session: %+v", state)
+	}
+
+	theorems, err := LoadTheorems(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	expectedTheorems := map[string]string{"Pythagoras": filepath.Join("geometry", "a.md")}
+	if !reflect.DeepEqual(theorems, expectedTheorems) {
+		t.Errorf("Expected %v, but got %v", expectedTheorems, theorems)
+	}
+
+	index, err := loadNoteIndex(tmpDir)
+	if err != nil {
+		t.Fatalf("loadNoteIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(index.Files["geometry/a.md"].Tags, []string{"geometry"}) {
+		t.Errorf("Unexpected migrated index: %+v", index)
+	}
+
+	history, err := GetHistory(tmpDir)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != "op1" || !reflect.DeepEqual(history[0].Changes, []FileChange{{Path: "a.md", Before: "x", After: "y"}}) {
+		t.Errorf("Unexpected migrated history: %+v", history)
+	}
+
+	for name, content := range legacy {
+		backup, err := os.ReadFile(filepath.Join(dir, name+".v0.bak"))
+		if err != nil {
+			t.Errorf("%s: backup was not created: %v", name, err)
+		} else if string(backup) != content {
+			t.Errorf("%s: expected backup %q, but got %q", name, content, backup)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if version, err := schemaVersion(data); err != nil || version != 1 {
+			t.Errorf("%s: expected version 1, but got %d (%v)", name, version, err)
+		}
+	}
+
+	// 移行済みのファイルは読み直しても書き換えない
+	migrated, err := os.ReadFile(filepath.Join(dir, theoremsFileName))
+	if err != nil {
+		t.Fatalf("Failed to read theorems.json: %v", err)
+	}
+	if _, err := LoadTheorems(tmpDir); err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	reloaded, err := os.ReadFile(filepath.Join(dir, theoremsFileName))
+	if err != nil {
+		t.Fatalf("Failed to read theorems.json: %v", err)
+	}
+	if string(reloaded) != string(migrated) {
+		t.Errorf("Migrated file was rewritten.\nGot:  %s\nWant: %s", reloaded, migrated)
+	}
+}
+
+func TestLoadSidecar_NewerVersion(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ensureSessionDirExists(tmpDir); err != nil {
+		t.Fatalf("Failed to create session dir: %v", err)
+	}
+	dir := filepath.Join(tmpDir, sessionDirPath)
+
+	tests := []struct {
+		file string
+		load func() error
+	}{
+		{projectConfigFile, func() error { _, err := LoadProjectConfig(tmpDir); return err }},
+		{sessionFileName, func() error { _, err := LoadSessionState(tmpDir); return err }},
+		{theoremsFileName, func() error { _, err := LoadTheorems(tmpDir); return err }},
+		{workspacesFileName, func() error { _, err := ListWorkspaces(tmpDir); return err }},
+		{historyFileName, func() error { _, err := GetHistory(tmpDir); return err }},
+	}
+	for _, tt := range tests {
+		content := `{"version":99}`
+		if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", tt.file, err)
+		}
+
+		err := tt.load()
+		var versionErr *SchemaVersionError
+		if !errors.Is(err, ErrUnsupportedSchemaVersion) || !errors.As(err, &versionErr) {
+			t.Errorf("%s: expected SchemaVersionError, but got %v", tt.file, err)
+			continue
+		}
+		if versionErr.File != tt.file || versionErr.Version != 99 || !strings.Contains(err.Error(), tt.file) {
+			t.Errorf("%s: unexpected error %v", tt.file, err)
+		}
+
+		// 新しいバージョンのファイルは書き換えない
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil || string(data) != content {
+			t.Errorf("%s: file was modified: %q (%v)", tt.file, data, err)
+		}
+	}
+}
+
+func TestLoadNoteIndex_NewerVersion(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeTestVault(t, tmpDir, map[string]string{"a.md": "#algebra\n"})
+	if err := ensureSessionDirExists(tmpDir); err != nil {
+		t.Fatalf("Failed to create session dir: %v", err)
+	}
+	path, _ := getIndexFilePath(tmpDir)
+	if err := os.WriteFile(path, []byte(`{"version":99,"notes":[]}`), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	// インデックスはノートから作り直せるので、新しいバージョンのものはエラーにせずに作り直す
+	index, err := loadNoteIndex(tmpDir)
+	if err != nil {
+		t.Fatalf("loadNoteIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(index.Files["a.md"].Tags, []string{"algebra"}) {
+		t.Errorf("Expected the index to be rebuilt, but got %+v", index)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if version, err := schemaVersion(data); err != nil || version != indexSchema.version() {
+		t.Errorf("Expected version %d, but got %d (%v)", indexSchema.version(), version, err)
+	}
+}
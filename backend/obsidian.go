@@ -0,0 +1,413 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrDestinationNotEmpty = errors.New("destination directory is not empty")
+
+var (
+	obsidianCalloutRegexp  = regexp.MustCompile(`^(\s*)>\s?\[!([A-Za-z-]+)\]([+-]?)\s*(.*)$`)
+	obsidianLinkRegexp     = regexp.MustCompile(`(!?)\[\[([^\]]+)\]\]`)
+	obsidianMdLinkRegexp   = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+	obsidianBlockIDRegexp  = regexp.MustCompile(`\s\^[A-Za-z0-9-]+$`)
+	obsidianCodeSpanRegexp = regexp.MustCompile("`[^`]*`")
+)
+
+// obsidianTheoremCallouts は <theorem> に変換するコールアウトの種類です
+var obsidianTheoremCallouts = []string{
+	"theorem", "lemma", "proposition", "corollary", "definition", "claim", "axiom",
+}
+
+// ObsidianImportIssue は変換できなかった箇所です。Line は 1 始まりで、ファイル全体の問題の場合は 0 です
+type ObsidianImportIssue struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ObsidianImportReport は取り込んだノートと添付ファイル、変換できなかった箇所を保持します
+type ObsidianImportReport struct {
+	Notes       []string              `json:"notes"`
+	Attachments []string              `json:"attachments"`
+	Issues      []ObsidianImportIssue `json:"issues"`
+}
+
+// String は取り込みの結果を、変換できなかった箇所を 1 行ずつ並べた文章にします
+func (r ObsidianImportReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "imported %d notes and %d attachments\n", len(r.Notes), len(r.Attachments))
+	for _, issue := range r.Issues {
+		if issue.Line > 0 {
+			fmt.Fprintf(&b, "%s:%d: %s\n", issue.Path, issue.Line, issue.Message)
+		} else {
+			fmt.Fprintf(&b, "%s: %s\n", issue.Path, issue.Message)
+		}
+	}
+	return b.String()
+}
+
+type obsidianImporter struct {
+	// notes はノートのルートからの相対パス (スラッシュ区切り、拡張子なし) です
+	notes []string
+	// attachments は添付ファイルの相対パスと、_images の中での名前の対応です
+	attachments map[string]string
+	report      ObsidianImportReport
+	current     string
+}
+
+// ImportObsidianVault は Obsidian の保管庫を destDir にコピーします。
+// 添付ファイルは _images に移し、[[...]] はルートからのパスに、> [!theorem] などのコールアウトは <theorem> に書き換えます。
+// destDir は存在しないか空である必要があります
+func ImportObsidianVault(srcDir string, destDir string) (ObsidianImportReport, error) {
+	if srcDir == "" || destDir == "" {
+		return ObsidianImportReport{}, os.ErrInvalid
+	}
+	if entries, err := os.ReadDir(destDir); err == nil && len(entries) > 0 {
+		return ObsidianImportReport{}, ErrDestinationNotEmpty
+	}
+
+	im := &obsidianImporter{
+		attachments: make(map[string]string),
+		report: ObsidianImportReport{
+			Notes:       []string{},
+			Attachments: []string{},
+			Issues:      []ObsidianImportIssue{},
+		},
+	}
+
+	var attachmentPaths []string
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// .obsidian (Obsidian の設定) や .trash は取り込まない
+		if p != srcDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.EqualFold(path.Ext(rel), ".md") {
+			im.notes = append(im.notes, strings.TrimSuffix(rel, path.Ext(rel)))
+		} else {
+			attachmentPaths = append(attachmentPaths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return ObsidianImportReport{}, err
+	}
+
+	// 添付ファイルは _images の直下に置くため、同じ名前のファイルには番号を付ける
+	used := make(map[string]bool)
+	for _, rel := range attachmentPaths {
+		name := path.Base(rel)
+		ext := path.Ext(name)
+		for i := 1; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s %d%s", strings.TrimSuffix(path.Base(rel), ext), i, ext)
+		}
+		used[strings.ToLower(name)] = true
+		im.attachments[rel] = name
+
+		dst := imagePath(destDir, name)
+		if err := copyFile(filepath.Join(srcDir, filepath.FromSlash(rel)), dst); err != nil {
+			return ObsidianImportReport{}, err
+		}
+		im.report.Attachments = append(im.report.Attachments, dst)
+		if name != path.Base(rel) {
+			im.current = rel
+			im.issue(0, "attachment was renamed to %s", name)
+		}
+	}
+
+	for _, note := range im.notes {
+		im.current = note + ".md"
+		content, err := ReadFile(filepath.Join(srcDir, filepath.FromSlash(im.current)))
+		if err != nil {
+			return ObsidianImportReport{}, err
+		}
+		lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+		converted := strings.Join(im.convertLines(lines, 1, true), "\n")
+
+		dst := filepath.Join(destDir, filepath.FromSlash(im.current))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return ObsidianImportReport{}, err
+		}
+		if err := os.WriteFile(dst, []byte(converted), 0644); err != nil {
+			return ObsidianImportReport{}, err
+		}
+		im.report.Notes = append(im.report.Notes, dst)
+	}
+
+	if err := RebuildIndex(destDir); err != nil {
+		return ObsidianImportReport{}, err
+	}
+	return im.report, nil
+}
+
+func (im *obsidianImporter) issue(line int, format string, args ...any) {
+	im.report.Issues = append(im.report.Issues, ObsidianImportIssue{Path: im.current, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// convertLines は行を変換します。firstLine は lines[0] の元のファイルでの行番号です
+func (im *obsidianImporter) convertLines(lines []string, firstLine int, top bool) []string {
+	result := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// フロントマターは Obsidian と同じ形式なのでそのまま残す
+		if top && i == 0 && trimmed == "---" {
+			end := slices.IndexFunc(lines[1:], func(l string) bool { return strings.TrimSpace(l) == "---" })
+			if end >= 0 {
+				result = append(result, lines[:end+2]...)
+				i = end + 1
+				continue
+			}
+		}
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			result = append(result, line)
+			continue
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			result = append(result, line)
+			continue
+		}
+
+		if m := obsidianCalloutRegexp.FindStringSubmatch(line); m != nil {
+			indent := m[1]
+			var body []string
+			for i+1 < len(lines) && strings.HasPrefix(strings.TrimPrefix(lines[i+1], indent), ">") {
+				i++
+				quoted := strings.TrimPrefix(strings.TrimPrefix(lines[i], indent), ">")
+				body = append(body, strings.TrimPrefix(quoted, " "))
+			}
+			start := firstLine + i - len(body)
+			converted := im.convertCallout(strings.ToLower(m[2]), m[4], im.convertLines(body, start+1, false), start)
+			for _, l := range converted {
+				result = append(result, indent+l)
+			}
+			continue
+		}
+		result = append(result, im.convertInline(line, firstLine+i))
+	}
+	return result
+}
+
+// convertCallout はコールアウトを定理、証明、または見出し付きの引用にします
+func (im *obsidianImporter) convertCallout(kind string, title string, body []string, line int) []string {
+	switch {
+	case slices.Contains(obsidianTheoremCallouts, kind):
+		title = im.convertInline(title, line)
+		name := strings.ReplaceAll(title, `"`, "'")
+		result := append([]string{`<theorem name="` + name + `">`}, body...)
+		return append(result, "</theorem>")
+
+	case kind == "proof":
+		if title == "" {
+			title = "証明"
+		}
+		result := append([]string{"<details>", "<summary>" + im.convertInline(title, line) + "</summary>", ""}, body...)
+		return append(result, "", "</details>")
+	}
+
+	im.issue(line, "callout [!%s] was kept as a blockquote", kind)
+	if title == "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	result := []string{"> **" + im.convertInline(title, line) + "**"}
+	for _, l := range body {
+		result = append(result, strings.TrimRight("> "+l, " "))
+	}
+	return result
+}
+
+// convertInline はコードスパンの外にあるリンクと埋め込みを書き換えます
+func (im *obsidianImporter) convertInline(line string, lineNo int) string {
+	line = obsidianBlockIDRegexp.ReplaceAllString(line, "")
+
+	var b strings.Builder
+	last := 0
+	convert := func(text string) string {
+		text = obsidianLinkRegexp.ReplaceAllStringFunc(text, func(m string) string {
+			sub := obsidianLinkRegexp.FindStringSubmatch(m)
+			return im.convertWikiLink(sub[2], sub[1] == "!", lineNo, m)
+		})
+		return obsidianMdLinkRegexp.ReplaceAllStringFunc(text, func(m string) string {
+			sub := obsidianMdLinkRegexp.FindStringSubmatch(m)
+			return im.convertMarkdownLink(sub[1] == "!", sub[2], sub[3], lineNo, m)
+		})
+	}
+	for _, span := range obsidianCodeSpanRegexp.FindAllStringIndex(line, -1) {
+		b.WriteString(convert(line[last:span[0]]))
+		b.WriteString(line[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(convert(line[last:]))
+	return b.String()
+}
+
+// convertWikiLink は [[...]] と ![[...]] の中身をこのアプリの形式にします。変換できない場合は original を返します
+func (im *obsidianImporter) convertWikiLink(inner string, embed bool, line int, original string) string {
+	target, display, hasDisplay := strings.Cut(inner, "|")
+	target, fragment, _ := strings.Cut(target, "#")
+	target = strings.TrimSpace(target)
+	if i := strings.LastIndex(fragment, "#"); i >= 0 {
+		// Obsidian の [[note#見出し#小見出し]] は最後の見出しだけを使う
+		fragment = fragment[i+1:]
+	}
+
+	if embed {
+		if name, ok := im.resolveAttachment(target, ""); ok {
+			if hasDisplay {
+				im.issue(line, "image size or caption %q of %s was dropped", display, target)
+			}
+			return "![[" + name + "]]"
+		}
+		if note, ok := im.resolveNote(target, "", line); ok {
+			im.issue(line, "embedded note %s was converted to a link", target)
+			return "[[" + note + fragmentSuffix(fragment) + "]]"
+		}
+		im.issue(line, "embedded file %s was not found", target)
+		return original
+	}
+
+	if strings.HasPrefix(fragment, "^") {
+		im.issue(line, "block reference %s#%s was converted to a link to the note", target, fragment)
+		fragment = ""
+	}
+	displaySuffix := ""
+	if hasDisplay {
+		displaySuffix = "|" + display
+	}
+	if target == "" {
+		return "[[" + fragmentSuffix(fragment) + displaySuffix + "]]"
+	}
+	if note, ok := im.resolveNote(target, "", line); ok {
+		return "[[" + note + fragmentSuffix(fragment) + displaySuffix + "]]"
+	}
+	if _, ok := im.resolveAttachment(target, ""); ok {
+		im.issue(line, "link to attachment %s is not supported", target)
+		return original
+	}
+	im.issue(line, "link target %s was not found", target)
+	return original
+}
+
+// convertMarkdownLink は [text](note.md) と ![alt](image.png) のうち、保管庫内のファイルを指すものを書き換えます
+func (im *obsidianImporter) convertMarkdownLink(image bool, text string, dest string, line int, original string) string {
+	if strings.Contains(dest, "://") || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "mailto:") {
+		return original
+	}
+	decoded, err := url.PathUnescape(dest)
+	if err != nil {
+		decoded = dest
+	}
+	target, fragment, _ := strings.Cut(decoded, "#")
+	dir := path.Dir(im.current)
+
+	if image {
+		if name, ok := im.resolveAttachment(target, dir); ok {
+			return "![[" + name + "]]"
+		}
+		im.issue(line, "image %s was not found", target)
+		return original
+	}
+	if note, ok := im.resolveNote(strings.TrimSuffix(target, ".md"), dir, line); ok {
+		if text == "" || text == target {
+			return "[[" + note + fragmentSuffix(fragment) + "]]"
+		}
+		return "[[" + note + fragmentSuffix(fragment) + "|" + text + "]]"
+	}
+	if name, ok := im.resolveAttachment(target, dir); ok {
+		im.issue(line, "link to attachment %s is not supported", name)
+	}
+	return original
+}
+
+func fragmentSuffix(fragment string) string {
+	if fragment == "" {
+		return ""
+	}
+	return "#" + fragment
+}
+
+// resolveNote は Obsidian のリンク先をノートの相対パス (拡張子なし) に解決します。
+// ノートのディレクトリからの相対パス、ルートからのパス、末尾が一致するパスの順に探し、
+// 候補が複数ある場合は Obsidian と同じくルートに近いものを選びます
+func (im *obsidianImporter) resolveNote(target string, dir string, line int) (string, bool) {
+	target = strings.TrimSuffix(target, ".md")
+	candidates := im.candidates(target, dir, im.notes)
+	if len(candidates) > 1 {
+		im.issue(line, "link target %s is ambiguous; using %s", target, candidates[0])
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	return candidates[0], true
+}
+
+func (im *obsidianImporter) resolveAttachment(target string, dir string) (string, bool) {
+	paths := make([]string, 0, len(im.attachments))
+	for rel := range im.attachments {
+		paths = append(paths, rel)
+	}
+	candidates := im.candidates(target, dir, paths)
+	if len(candidates) == 0 {
+		return "", false
+	}
+	return im.attachments[candidates[0]], true
+}
+
+func (im *obsidianImporter) candidates(target string, dir string, paths []string) []string {
+	if target == "" {
+		return nil
+	}
+	lower := strings.ToLower(strings.TrimPrefix(target, "/"))
+	if dir != "" {
+		relative := strings.ToLower(path.Join(dir, target))
+		for _, p := range paths {
+			if strings.ToLower(p) == relative {
+				return []string{p}
+			}
+		}
+	}
+
+	var candidates []string
+	for _, p := range paths {
+		if l := strings.ToLower(p); l == lower || strings.HasSuffix(l, "/"+lower) {
+			candidates = append(candidates, p)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		if d := strings.Count(a, "/") - strings.Count(b, "/"); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+	return candidates
+}
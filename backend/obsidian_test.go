@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportObsidianVault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "obsidian")
+	destDir := filepath.Join(tmpDir, "vault")
+	writeTestVault(t, srcDir, map[string]string{
+		"analysis/Limits.md": "---\ntags: [analysis]\n---\n# 極限\n\n" +
+			"> [!theorem] ε-δ 論法\n> 任意の $\\varepsilon > 0$ に対して\n> > [!proof]-\n> > [[Continuity#定義|連続性]] を使う。\n\n" +
+			"> [!note]\n> 補足\n\n" +
+			"![[graph.png|300]] ![図](../attachments/graph.png) [連続](Continuity.md)\n" +
+			"`[[Limits]]` [[Missing]] [[Continuity#^abc123]] 段落 ^def456\n\n" +
+			"```\n[[Limits]]\n```\n",
+		"Continuity.md":         "# 連続性\n\n## 定義\n",
+		"attachments/graph.png": "png",
+		"other/graph.png":       "png2",
+		".obsidian/app.json":    "{}",
+	})
+
+	report, err := ImportObsidianVault(srcDir, destDir)
+	if err != nil {
+		t.Fatalf("ImportObsidianVault failed: %v", err)
+	}
+
+	content, err := ReadFile(filepath.Join(destDir, "analysis", "Limits.md"))
+	if err != nil {
+		t.Fatalf("Failed to read imported note: %v", err)
+	}
+	expected := "---\ntags: [analysis]\n---\n# 極限\n\n" +
+		"<theorem name=\"ε-δ 論法\">\n任意の $\\varepsilon > 0$ に対して\n<details>\n<summary>証明</summary>\n\n[[Continuity#定義|連続性]] を使う。\n\n</details>\n</theorem>\n\n" +
+		"> **Note**\n> 補足\n\n" +
+		"![[graph.png]] ![[graph.png]] [[Continuity|連続]]\n" +
+		"`[[Limits]]` [[Missing]] [[Continuity]] 段落\n\n" +
+		"```\n[[Limits]]\n```\n"
+	if content != expected {
+		t.Errorf("Imported note is incorrect.\nGot:\n%s\nWant:\n%s", content, expected)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "_images", "graph 1.png")); err != nil {
+		t.Errorf("Attachment with a duplicate name was not renamed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, ".obsidian")); !os.IsNotExist(err) {
+		t.Errorf(".obsidian should not be imported")
+	}
+
+	expectedIssues := []ObsidianImportIssue{
+		{Path: "other/graph.png", Message: "attachment was renamed to graph 1.png"},
+		{Path: "analysis/Limits.md", Line: 11, Message: "callout [!note] was kept as a blockquote"},
+		{Path: "analysis/Limits.md", Line: 14, Message: `image size or caption "300" of graph.png was dropped`},
+		{Path: "analysis/Limits.md", Line: 15, Message: "link target Missing was not found"},
+		{Path: "analysis/Limits.md", Line: 15, Message: "block reference Continuity#^abc123 was converted to a link to the note"},
+	}
+	if !reflect.DeepEqual(report.Issues, expectedIssues) {
+		t.Errorf("Expected issues %+v, but got %+v", expectedIssues, report.Issues)
+	}
+	if !strings.HasPrefix(report.String(), "imported 2 notes and 2 attachments\nother/graph.png: attachment was renamed") {
+		t.Errorf("Unexpected report:\n%s", report.String())
+	}
+
+	theorems, err := LoadTheorems(destDir)
+	if err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	if theorems["ε-δ 論法"] != filepath.Join("analysis", "Limits.md") {
+		t.Errorf("Theorem was not indexed, got %v", theorems)
+	}
+
+	if _, err := ImportObsidianVault(srcDir, destDir); err != ErrDestinationNotEmpty {
+		t.Errorf("Expected ErrDestinationNotEmpty, but got %v", err)
+	}
+}
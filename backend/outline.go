@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	OutlineKindHeading = "heading"
+	OutlineKindTheorem = "theorem"
+	OutlineKindProof   = "proof"
+	OutlineKindDetails = "details"
+)
+
+// OutlineItem はドキュメントの構造を表す木の要素です。
+// StartLine と EndLine は 1 始まりで、EndLine の行を含みます
+type OutlineItem struct {
+	Kind      string        `json:"kind"`
+	Title     string        `json:"title"`
+	Level     int           `json:"level"`
+	Slug      string        `json:"slug"`
+	StartLine int           `json:"start_line"`
+	EndLine   int           `json:"end_line"`
+	Children  []OutlineItem `json:"children"`
+}
+
+// Slugger はプレビューの rehype-slug (github-slugger) と同じ規則で見出しの id を作ります。
+// 同じ id が続いた場合は -1, -2 ... を付けて区別します
+type Slugger struct {
+	occurrences map[string]int
+}
+
+func NewSlugger() *Slugger {
+	return &Slugger{occurrences: make(map[string]int)}
+}
+
+// Slug は text から重複しない id を作ります
+func (s *Slugger) Slug(text string) string {
+	result := slugify(text)
+	original := result
+	for {
+		if _, ok := s.occurrences[result]; !ok {
+			break
+		}
+		s.occurrences[original]++
+		result = original + "-" + strconv.Itoa(s.occurrences[original])
+	}
+	s.occurrences[result] = 0
+	return result
+}
+
+// slugify は小文字化したうえで、文字・数字・結合文字・'_'・'-'・空白以外を取り除き、空白を '-' にします
+func slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.Pc):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ParseOutline は見出し・定理ブロック・<details> による証明を入れ子にした木を返します。
+// 見出しは同じかより上位の見出しが現れるまで続き、定理や証明の中の見出しはそのブロックの子になります
+func ParseOutline(content string) []OutlineItem {
+	return parseOutline(content, getDefaultTheoremSettings())
+}
+
+func parseOutline(content string, theorems TheoremSettings) []OutlineItem {
+	doc := parseMarkdown(content, theorems)
+	return buildOutline(doc.Children, doc.EndLine, theorems)
+}
+
+// buildOutline は同じ階層のブロック要素から木を作ります。endLine は最後の見出しが続く行です
+func buildOutline(nodes []*Node, endLine int, theorems TheoremSettings) []OutlineItem {
+	type entry struct {
+		item  OutlineItem
+		level int
+	}
+	root := &OutlineItem{Children: []OutlineItem{}}
+	var stack []entry
+
+	closeTop := func(end int) {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		top.item.EndLine = end
+		parent := root
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1].item
+		}
+		parent.Children = append(parent.Children, top.item)
+	}
+	appendItem := func(item OutlineItem) {
+		parent := root
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1].item
+		}
+		parent.Children = append(parent.Children, item)
+	}
+
+	for _, node := range nodes {
+		switch node.Kind {
+		case NodeKindHeading:
+			for len(stack) > 0 && stack[len(stack)-1].level >= node.Level {
+				closeTop(node.StartLine - 1)
+			}
+			stack = append(stack, entry{level: node.Level, item: OutlineItem{
+				Kind:      OutlineKindHeading,
+				Title:     strings.TrimSpace(node.PlainText()),
+				Level:     node.Level,
+				Slug:      node.Slug,
+				StartLine: node.StartLine,
+				Children:  []OutlineItem{},
+			}})
+
+		case NodeKindTheorem, NodeKindDetails:
+			item := OutlineItem{
+				Kind:      OutlineKindTheorem,
+				Title:     node.Title,
+				Slug:      node.Slug,
+				StartLine: node.StartLine,
+				EndLine:   node.EndLine,
+			}
+			if node.Kind == NodeKindDetails {
+				item.Kind = OutlineKindDetails
+				if isProofSummary(node.Title, theorems) {
+					item.Kind = OutlineKindProof
+				}
+			}
+			// 中の見出しは閉じタグの前の行まで続く
+			innerEnd := node.StartLine
+			if len(node.Children) > 0 {
+				innerEnd = node.Children[len(node.Children)-1].EndLine
+			}
+			item.Children = buildOutline(node.Children, innerEnd, theorems)
+			appendItem(item)
+		}
+	}
+	for len(stack) > 0 {
+		closeTop(endLine)
+	}
+	return root.Children
+}
+
+// parseATXHeading は "## 見出し" 形式の行からレベルとテキストを取り出します
+func parseATXHeading(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	if level < len(line) && line[level] != ' ' && line[level] != '\t' {
+		return 0, "", false
+	}
+	text := strings.TrimSpace(line[level:])
+	// 閉じの # は空白が前にある場合のみ取り除く
+	if trimmed := strings.TrimRight(text, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		text = strings.TrimSpace(trimmed)
+	}
+	return level, text, true
+}
+
+// GetDocumentOutline はノートの見出し・定理・証明の構造を返します。定理環境と証明の見出しは rootDir の設定に従います
+func GetDocumentOutline(path string, rootDir string) ([]OutlineItem, error) {
+	content, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return parseOutline(content, theorems), nil
+}
+
+// findOutlineHeading は見出しのテキストか slug が一致する要素を探します
+func findOutlineHeading(items []OutlineItem, heading string) (OutlineItem, bool) {
+	slug := slugify(heading)
+	for _, item := range items {
+		if item.Kind != OutlineKindDetails && item.Kind != OutlineKindProof &&
+			(item.Title == heading || item.Slug == heading || item.Slug == slug) {
+			return item, true
+		}
+		if found, ok := findOutlineHeading(item.Children, heading); ok {
+			return found, true
+		}
+	}
+	return OutlineItem{}, false
+}
+
+// flattenOutline は木を文書の順に平坦化し、kind の要素だけを返します
+func flattenOutline(items []OutlineItem, kind string) []OutlineItem {
+	var result []OutlineItem
+	for _, item := range items {
+		if item.Kind == kind {
+			result = append(result, item)
+		}
+		result = append(result, flattenOutline(item.Children, kind)...)
+	}
+	return result
+}
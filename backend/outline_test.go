@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSlugger(t *testing.T) {
+	slugger := NewSlugger()
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"変数・条件", "変数条件"},
+		{"主張", "主張"},
+		{"主張", "主張-1"},
+		{"主張", "主張-2"},
+		{"x^2 の性質 (2)", "x2-の性質-2"},
+		{"snake_case & kebab-case", "snake_case--kebab-case"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := slugger.Slug(tt.text); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseOutline(t *testing.T) {
+	content := "---\n" + // 1
+		"title: test\n" + // 2
+		"---\n" + // 3
+		"# 位相空間\n" + // 4
+		"## **コンパクト** 性\n" + // 5
+		"<theorem name=\"ハイネ・ボレル\">\n" + // 6
+		"### 変数・条件\n" + // 7
+		"### 主張\n" + // 8
+		"</theorem>\n" + // 9
+		"\n" + // 10
+		"<details>\n" + // 11
+		"<summary>証明</summary>\n" + // 12
+		"</details>\n" + // 13
+		"```\n" + // 14
+		"# not a heading\n" + // 15
+		"```\n" + // 16
+		"## [[other|連結]] 性\n" + // 17
+		"<theorem name=\"Inline\">statement</theorem>" // 18
+
+	leaf := func(item OutlineItem) OutlineItem {
+		item.Children = []OutlineItem{}
+		return item
+	}
+	expected := []OutlineItem{
+		{Kind: OutlineKindHeading, Title: "位相空間", Level: 1, Slug: "位相空間", StartLine: 4, EndLine: 18, Children: []OutlineItem{
+			{Kind: OutlineKindHeading, Title: "コンパクト 性", Level: 2, Slug: "コンパクト-性", StartLine: 5, EndLine: 16, Children: []OutlineItem{
+				{Kind: OutlineKindTheorem, Title: "ハイネ・ボレル", Slug: "ハイネボレル", StartLine: 6, EndLine: 9, Children: []OutlineItem{
+					leaf(OutlineItem{Kind: OutlineKindHeading, Title: "変数・条件", Level: 3, Slug: "変数条件", StartLine: 7, EndLine: 7}),
+					leaf(OutlineItem{Kind: OutlineKindHeading, Title: "主張", Level: 3, Slug: "主張", StartLine: 8, EndLine: 8}),
+				}},
+				leaf(OutlineItem{Kind: OutlineKindProof, Title: "証明", StartLine: 11, EndLine: 13}),
+			}},
+			{Kind: OutlineKindHeading, Title: "連結 性", Level: 2, Slug: "連結-性", StartLine: 17, EndLine: 18, Children: []OutlineItem{
+				leaf(OutlineItem{Kind: OutlineKindTheorem, Title: "Inline", Slug: "inline", StartLine: 18, EndLine: 18}),
+			}},
+		}},
+	}
+
+	outline := ParseOutline(content)
+	if !reflect.DeepEqual(outline, expected) {
+		t.Errorf("ParseOutline returned unexpected tree.\nGot:  %+v\nWant: %+v", outline, expected)
+	}
+}
+
+func TestResolveLinkHeading(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filePath := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(filePath, []byte("# 定義\n\n## 例\n\n# 例\n"), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	link, err := ResolveLink(tmpDir, "note#例-1")
+	if err != nil {
+		t.Fatalf("ResolveLink failed: %v", err)
+	}
+	if link.Slug != "例-1" || link.Line != 5 {
+		t.Errorf("Expected the second 例 heading, but got %+v", link)
+	}
+
+	link, err = ResolveLink(tmpDir, "note#例")
+	if err != nil {
+		t.Fatalf("ResolveLink failed: %v", err)
+	}
+	if link.Slug != "例" || link.Line != 3 {
+		t.Errorf("Expected the first 例 heading, but got %+v", link)
+	}
+}
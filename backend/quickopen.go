@@ -0,0 +1,290 @@
+package backend
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	QuickOpenKindFile    = "file"
+	QuickOpenKindHeading = "heading"
+	QuickOpenKindTheorem = "theorem"
+
+	recentFilesLimit = 50
+)
+
+// QuickOpenItem はクイックオープンの候補を表します。
+// Positions は Label 内で一致した文字 (rune) の位置です
+type QuickOpenItem struct {
+	Kind      string `json:"kind"`
+	Label     string `json:"label"`
+	Detail    string `json:"detail"`
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"`
+}
+
+// RecentFiles はセッション中に開いたファイルを新しい順に保持します
+type RecentFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// Touch はファイルを最近開いたファイルの先頭に移動します
+func (r *RecentFiles) Touch(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path = filepath.Clean(path)
+	r.paths = slices.DeleteFunc(r.paths, func(p string) bool { return p == path })
+	r.paths = slices.Insert(r.paths, 0, path)
+	if len(r.paths) > recentFilesLimit {
+		r.paths = r.paths[:recentFilesLimit]
+	}
+}
+
+// List は最近開いたファイルを新しい順に返します
+func (r *RecentFiles) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.paths)
+}
+
+type quickOpenCandidate struct {
+	item   QuickOpenItem
+	target string
+}
+
+// QuickOpen はファイル・見出し・定理名をまとめてファジー検索し、スコア順に返します。
+// recent は最近開いたファイルの絶対パスを新しい順に並べたもので、一致したファイルとその中の候補が優先されます
+func QuickOpen(rootDir string, query string, recent []string, limit int) ([]QuickOpenItem, error) {
+	candidates, err := collectQuickOpenCandidates(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	recentRank := make(map[string]int, len(recent))
+	for i, p := range recent {
+		if _, ok := recentRank[filepath.Clean(p)]; !ok {
+			recentRank[filepath.Clean(p)] = i
+		}
+	}
+
+	terms := strings.Fields(normalizeFuzzyText(query))
+	results := []QuickOpenItem{}
+	for _, c := range candidates {
+		item := c.item
+		score, positions, ok := fuzzyMatchTerms(terms, c.target)
+		if !ok {
+			continue
+		}
+		if len(terms) == 0 && item.Kind != QuickOpenKindFile {
+			// 何も入力されていない場合はファイルのみを候補にする
+			continue
+		}
+		if rank, ok := recentRank[item.Path]; ok {
+			score += recencyBonus(rank, item.Kind)
+		}
+		item.Score = score
+		item.Positions = positions
+		if item.Positions == nil {
+			item.Positions = []int{}
+		}
+		results = append(results, item)
+	}
+
+	slices.SortStableFunc(results, func(a, b QuickOpenItem) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		if len(a.Label) != len(b.Label) {
+			return cmp.Compare(len(a.Label), len(b.Label))
+		}
+		return cmp.Compare(a.Label, b.Label)
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func recencyBonus(rank int, kind string) int {
+	bonus := 30 - rank*3
+	if bonus < 0 {
+		return 0
+	}
+	if kind != QuickOpenKindFile {
+		bonus /= 2
+	}
+	return bonus
+}
+
+func collectQuickOpenCandidates(rootDir string) ([]quickOpenCandidate, error) {
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []quickOpenCandidate
+	for _, path := range files {
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		candidates = append(candidates, quickOpenCandidate{
+			item: QuickOpenItem{
+				Kind:   QuickOpenKindFile,
+				Label:  rel,
+				Detail: rel,
+				Path:   path,
+			},
+			target: normalizeFuzzyText(rel),
+		})
+
+		content, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range flattenOutline(ParseOutline(content), OutlineKindHeading) {
+			candidates = append(candidates, quickOpenCandidate{
+				item: QuickOpenItem{
+					Kind:   QuickOpenKindHeading,
+					Label:  h.Title,
+					Detail: rel,
+					Path:   path,
+					Line:   h.StartLine,
+				},
+				target: normalizeFuzzyText(h.Title),
+			})
+		}
+	}
+
+	theorems, err := LoadTheorems(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	for name, rel := range theorems {
+		candidates = append(candidates, quickOpenCandidate{
+			item: QuickOpenItem{
+				Kind:   QuickOpenKindTheorem,
+				Label:  name,
+				Detail: filepath.ToSlash(rel),
+				Path:   filepath.Join(rootDir, rel),
+			},
+			target: normalizeFuzzyText(name),
+		})
+	}
+	return candidates, nil
+}
+
+// normalizeFuzzyText は大文字小文字・全角半角・カタカナひらがなの違いを吸収します
+func normalizeFuzzyText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\\", "/") {
+		switch {
+		case r >= '！' && r <= '～':
+			r = r - '！' + '!'
+		case r == '　':
+			r = ' '
+		case r >= 'ァ' && r <= 'ヶ':
+			r = r - 'ァ' + 'ぁ'
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fuzzyMatchTerms は空白で区切られたすべての語が target に含まれる場合にスコアを返します
+func fuzzyMatchTerms(terms []string, target string) (int, []int, bool) {
+	runes := []rune(target)
+	total := 0
+	var positions []int
+	for _, term := range terms {
+		score, pos, ok := fuzzyMatch([]rune(term), runes)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		positions = append(positions, pos...)
+	}
+	slices.Sort(positions)
+	positions = slices.Compact(positions)
+	return total, positions, true
+}
+
+// fuzzyMatch は pattern が target の部分列として現れるかを調べ、最もスコアの高い一致を返します。
+// 連続した一致やパスの区切り・単語の先頭での一致ほど高く評価されます
+func fuzzyMatch(pattern, target []rune) (int, []int, bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+
+	bestScore := 0
+	var bestPositions []int
+	found := false
+	for start := range target {
+		if target[start] != pattern[0] {
+			continue
+		}
+		score, positions, ok := fuzzyMatchFrom(pattern, target, start)
+		if !ok {
+			// これより後ろから始めても一致しない
+			break
+		}
+		if !found || score > bestScore {
+			bestScore, bestPositions, found = score, positions, true
+		}
+	}
+	return bestScore, bestPositions, found
+}
+
+func fuzzyMatchFrom(pattern, target []rune, start int) (int, []int, bool) {
+	positions := make([]int, 0, len(pattern))
+	score := 0
+	ti := start
+	for pi, r := range pattern {
+		for ti < len(target) && target[ti] != r {
+			ti++
+		}
+		if ti == len(target) {
+			return 0, nil, false
+		}
+
+		score += 1
+		if isFuzzyBoundary(target, ti) {
+			score += 8
+		}
+		if pi > 0 {
+			gap := ti - positions[pi-1] - 1
+			if gap == 0 {
+				score += 5
+			} else {
+				score -= min(gap, 5)
+			}
+		}
+		positions = append(positions, ti)
+		ti++
+	}
+	if start == 0 {
+		score += 4
+	}
+	return score, positions, true
+}
+
+func isFuzzyBoundary(target []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch target[i-1] {
+	case '/', ' ', '_', '-', '.', '・', '（', '(', '「':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQuickOpen(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "topology"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	compact := filepath.Join(tmpDir, "topology", "compact.md")
+	connected := filepath.Join(tmpDir, "topology", "connected.md")
+	algebra := filepath.Join(tmpDir, "algebra.md")
+	files := map[string]string{
+		compact:   "# コンパクト空間\n\n<theorem name=\"ハイネ・ボレルの定理\">\n</theorem>",
+		connected: "# 連結性\n```\n# not a heading\n```",
+		algebra:   "# Group Homomorphism",
+	}
+	for path, content := range files {
+		if err := WriteFile(path, content, tmpDir); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// Path segments
+	items, err := QuickOpen(tmpDir, "top/comp", nil, 0)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	if len(items) == 0 || items[0].Path != compact || items[0].Kind != QuickOpenKindFile {
+		t.Errorf("Expected topology/compact.md first, but got %v", items)
+	}
+
+	// Japanese text, katakana and hiragana are treated as equal
+	items, err = QuickOpen(tmpDir, "はいね", nil, 0)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Kind != QuickOpenKindTheorem || items[0].Label != "ハイネ・ボレルの定理" {
+		t.Fatalf("Expected the theorem to match, but got %v", items)
+	}
+	if !reflect.DeepEqual(items[0].Positions, []int{0, 1, 2}) {
+		t.Errorf("Unexpected match positions: %v", items[0].Positions)
+	}
+
+	// Headings inside code blocks are ignored
+	items, err = QuickOpen(tmpDir, "not a heading", nil, 0)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	for _, item := range items {
+		if item.Kind == QuickOpenKindHeading {
+			t.Errorf("Heading inside code block was matched: %v", item)
+		}
+	}
+
+	items, err = QuickOpen(tmpDir, "homo", nil, 0)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Kind != QuickOpenKindHeading || items[0].Line != 1 || items[0].Path != algebra {
+		t.Errorf("Expected the heading to match, but got %v", items)
+	}
+}
+
+func TestQuickOpenRecentFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	first := filepath.Join(tmpDir, "note1.md")
+	second := filepath.Join(tmpDir, "note2.md")
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	var recent RecentFiles
+	recent.Touch(first)
+	recent.Touch(second)
+	recent.Touch(first)
+	if !reflect.DeepEqual(recent.List(), []string{first, second}) {
+		t.Fatalf("Unexpected recent files: %v", recent.List())
+	}
+
+	items, err := QuickOpen(tmpDir, "note", []string{second}, 0)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Path != second {
+		t.Errorf("Expected recently opened note2.md first, but got %v", items)
+	}
+
+	// An empty query lists files only
+	items, err = QuickOpen(tmpDir, "", recent.List(), 1)
+	if err != nil {
+		t.Fatalf("QuickOpen failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Path != first {
+		t.Errorf("Expected note1.md for empty query, but got %v", items)
+	}
+}
@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// ReplaceOptions は一括置換の条件を保持します
+type ReplaceOptions struct {
+	Query         string `json:"query"`
+	Replacement   string `json:"replacement"`
+	UseRegex      bool   `json:"use_regex"`
+	CaseSensitive bool   `json:"case_sensitive"`
+}
+
+// ReplaceMatch は置換対象となる一箇所を表します。Line と Column は 1 始まりです
+type ReplaceMatch struct {
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	LineText    string `json:"line_text"`
+	Match       string `json:"match"`
+	Replacement string `json:"replacement"`
+}
+
+// ReplacePreview は一つのファイルに対する置換結果のプレビューです
+type ReplacePreview struct {
+	Path    string         `json:"path"`
+	Matches []ReplaceMatch `json:"matches"`
+}
+
+func compileReplacePattern(opts ReplaceOptions) (*regexp.Regexp, error) {
+	if opts.Query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	pattern := opts.Query
+	if !opts.UseRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func replaceContent(re *regexp.Regexp, content string, opts ReplaceOptions) string {
+	if opts.UseRegex {
+		return re.ReplaceAllString(content, opts.Replacement)
+	}
+	return re.ReplaceAllLiteralString(content, opts.Replacement)
+}
+
+func findReplaceMatches(re *regexp.Regexp, content string, opts ReplaceOptions) []ReplaceMatch {
+	indexes := re.FindAllStringSubmatchIndex(content, -1)
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	matches := make([]ReplaceMatch, 0, len(indexes))
+	line := 0
+	for _, loc := range indexes {
+		start, end := loc[0], loc[1]
+		for line+1 < len(lineStarts) && lineStarts[line+1] <= start {
+			line++
+		}
+
+		lineEnd := strings.IndexByte(content[lineStarts[line]:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStarts[line]
+		}
+
+		replacement := opts.Replacement
+		if opts.UseRegex {
+			replacement = string(re.ExpandString(nil, opts.Replacement, content, loc))
+		}
+
+		matches = append(matches, ReplaceMatch{
+			Line:        line + 1,
+			Column:      utf8.RuneCountInString(content[lineStarts[line]:start]) + 1,
+			LineText:    strings.TrimSuffix(content[lineStarts[line]:lineEnd], "\r"),
+			Match:       content[start:end],
+			Replacement: replacement,
+		})
+	}
+	return matches
+}
+
+// PreviewReplace は rootDir 以下の Markdown ファイルに対する置換結果をファイルごとに返します。
+// ファイルは変更されません
+func PreviewReplace(rootDir string, opts ReplaceOptions) ([]ReplacePreview, error) {
+	re, err := compileReplacePattern(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := []ReplacePreview{}
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		matches := findReplaceMatches(re, content, opts)
+		if len(matches) == 0 {
+			continue
+		}
+		previews = append(previews, ReplacePreview{Path: path, Matches: matches})
+	}
+	return previews, nil
+}
+
+// ApplyReplace は置換を実行し、一つの取り消し可能な操作として履歴に記録します。
+// paths が空でない場合は、そのファイルのみを対象にします
+func ApplyReplace(rootDir string, opts ReplaceOptions, paths []string) (Operation, error) {
+	re, err := compileReplacePattern(opts)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	var changes []FileChange
+	for _, path := range files {
+		if len(paths) > 0 && !containsPath(paths, path) {
+			continue
+		}
+		content, err := ReadFile(path)
+		if err != nil {
+			return Operation{}, err
+		}
+		replaced := replaceContent(re, content, opts)
+		if replaced == content {
+			continue
+		}
+		changes = append(changes, FileChange{Path: path, Before: content, After: replaced})
+	}
+
+	return applyChanges(rootDir, fmt.Sprintf("Replace %q with %q", opts.Query, opts.Replacement), changes)
+}
+
+// applyChanges は変更を WriteFile 経由で書き込み、履歴に記録します。
+// 途中で書き込みに失敗した場合も、書き込み済みの変更は取り消せるように記録します
+func applyChanges(rootDir string, description string, changes []FileChange) (Operation, error) {
+	if len(changes) == 0 {
+		return Operation{Description: description, Changes: []FileChange{}}, nil
+	}
+
+	for i, change := range changes {
+		if err := WriteFile(change.Path, change.After, rootDir); err != nil {
+			if i > 0 {
+				_, _ = recordOperation(rootDir, description, changes[:i])
+			}
+			return Operation{}, err
+		}
+	}
+
+	return recordOperation(rootDir, description, changes)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPreviewReplace(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filePath := filepath.Join(tmpDir, "note.md")
+	err = os.WriteFile(filePath, []byte("集合 $A_1$ と $A_2$\n次の行"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create note.md: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(tmpDir, "other.txt"), []byte("$A_1$"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create other.txt: %v", err)
+	}
+
+	previews, err := PreviewReplace(tmpDir, ReplaceOptions{
+		Query:         `A_(\d)`,
+		Replacement:   `X_{$1}`,
+		UseRegex:      true,
+		CaseSensitive: true,
+	})
+	if err != nil {
+		t.Fatalf("PreviewReplace failed: %v", err)
+	}
+
+	expected := []ReplacePreview{
+		{
+			Path: filePath,
+			Matches: []ReplaceMatch{
+				{Line: 1, Column: 5, LineText: "集合 $A_1$ と $A_2$", Match: "A_1", Replacement: "X_{1}"},
+				{Line: 1, Column: 13, LineText: "集合 $A_1$ と $A_2$", Match: "A_2", Replacement: "X_{2}"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(previews, expected) {
+		t.Errorf("PreviewReplace returned unexpected result.\nGot:  %v\nWant: %v", previews, expected)
+	}
+
+	// Preview must not modify files
+	content, _ := os.ReadFile(filePath)
+	if string(content) != "集合 $A_1$ と $A_2$\n次の行" {
+		t.Errorf("PreviewReplace modified the file: %q", content)
+	}
+
+	if _, err := PreviewReplace(tmpDir, ReplaceOptions{}); err != ErrEmptyQuery {
+		t.Errorf("Expected ErrEmptyQuery for empty query, but got %v", err)
+	}
+}
+
+func TestApplyReplaceAndUndo(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file1 := filepath.Join(tmpDir, "a.md")
+	file2 := filepath.Join(tmpDir, "sub", "b.md")
+	original1 := "<theorem name=\"Old Lemma\">\n</theorem>"
+	original2 := "See [[a.md|old lemma]]"
+	if err := WriteFile(file1, original1, tmpDir); err != nil {
+		t.Fatalf("Failed to write a.md: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file2), 0755); err != nil {
+		t.Fatalf("Failed to create sub dir: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte(original2), 0644); err != nil {
+		t.Fatalf("Failed to write b.md: %v", err)
+	}
+
+	op, err := ApplyReplace(tmpDir, ReplaceOptions{Query: "old lemma", Replacement: "New Lemma"}, nil)
+	if err != nil {
+		t.Fatalf("ApplyReplace failed: %v", err)
+	}
+	if len(op.Changes) != 2 {
+		t.Fatalf("Expected 2 changed files, but got %d", len(op.Changes))
+	}
+
+	content, _ := ReadFile(file2)
+	if content != "See [[a.md|New Lemma]]" {
+		t.Errorf("Unexpected content after replace: %q", content)
+	}
+
+	// The theorem index must follow the renamed theorem
+	theorems, err := LoadTheorems(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadTheorems failed: %v", err)
+	}
+	if !reflect.DeepEqual(theorems, map[string]string{"New Lemma": "a.md"}) {
+		t.Errorf("Theorem index was not updated: %v", theorems)
+	}
+
+	if _, err := UndoLastOperation(tmpDir); err != nil {
+		t.Fatalf("UndoLastOperation failed: %v", err)
+	}
+	content1, _ := ReadFile(file1)
+	content2, _ := ReadFile(file2)
+	if content1 != original1 || content2 != original2 {
+		t.Errorf("Undo did not restore files.\nGot:  %q, %q\nWant: %q, %q", content1, content2, original1, original2)
+	}
+
+	if _, err := UndoLastOperation(tmpDir); err != ErrNothingToUndo {
+		t.Errorf("Expected ErrNothingToUndo, but got %v", err)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filePath := filepath.Join(tmpDir, "a.md")
+	if err := os.WriteFile(filePath, []byte("foo"), 0644); err != nil {
+		t.Fatalf("Failed to write a.md: %v", err)
+	}
+
+	if _, err := ApplyReplace(tmpDir, ReplaceOptions{Query: "foo", Replacement: "bar"}, []string{filePath}); err != nil {
+		t.Fatalf("ApplyReplace failed: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("edited"), 0644); err != nil {
+		t.Fatalf("Failed to edit a.md: %v", err)
+	}
+
+	if _, err := UndoLastOperation(tmpDir); err != ErrUndoConflict {
+		t.Errorf("Expected ErrUndoConflict, but got %v", err)
+	}
+	content, _ := ReadFile(filePath)
+	if content != "edited" {
+		t.Errorf("Undo must not overwrite edited file, but got %q", content)
+	}
+}
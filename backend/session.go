@@ -0,0 +1,158 @@
+package backend
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+)
+
+const (
+	// sessionVersion は session.json の形式のバージョンです。sessionSchema の変換の数と一致させます
+	sessionVersion = 1
+
+	ViewModeEditor  = "editor"
+	ViewModeSplit   = "split"
+	ViewModePreview = "preview"
+
+	// defaultEditorWidth は分割表示のエディタの幅 (%) の既定値です
+	defaultEditorWidth = 50
+)
+
+// CursorPosition はエディタのカーソルの位置です。Line と Column は 1 始まりです
+type CursorPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// SessionTab は開いているタブ 1 つの状態です。スクロール位置はエディタとプレビューそれぞれのピクセル数です
+type SessionTab struct {
+	Path             string         `json:"path"`
+	Cursor           CursorPosition `json:"cursor"`
+	EditorScrollTop  float64        `json:"editor_scroll_top"`
+	PreviewScrollTop float64        `json:"preview_scroll_top"`
+}
+
+// SessionState はプロジェクトを開き直したときに復元するエディタの状態です。
+// EditorWidth は分割表示でのエディタの幅 (%) です
+type SessionState struct {
+	Version     int          `json:"version"`
+	ActiveFile  string       `json:"active_file"`
+	ViewMode    string       `json:"view_mode"`
+	EditorWidth float64      `json:"editor_width"`
+	Tabs        []SessionTab `json:"tabs"`
+}
+
+func defaultSessionState() SessionState {
+	return SessionState{
+		Version:     sessionVersion,
+		ViewMode:    ViewModeSplit,
+		EditorWidth: defaultEditorWidth,
+		Tabs:        []SessionTab{},
+	}
+}
+
+// newSessionState は初めて開くプロジェクトの状態を、設定の既定の表示モードで返します
+func newSessionState(rootDir string) SessionState {
+	state := defaultSessionState()
+	// 設定が壊れていてもプロジェクトは開けるように、読み込めない場合は分割表示のままにする
+	if config, err := LoadProjectConfig(rootDir); err == nil {
+		state.ViewMode = config.EditorSettings.DefaultViewMode
+	}
+	return state
+}
+
+// SaveSessionState はエディタの状態を session.json に保存します
+func SaveSessionState(rootDir string, state SessionState) error {
+	if err := ensureSessionDirExists(rootDir); err != nil {
+		return err
+	}
+	path, err := getSessionFilePath(rootDir)
+	if err != nil {
+		return err
+	}
+
+	state = normalizeSessionState(state)
+	state.Version = sessionVersion
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadSessionState は session.json からエディタの状態を読み込みます。
+// ファイルがない場合は設定の既定の表示モードで始まる状態を、古い形式の場合は変換した状態を返します
+func LoadSessionState(rootDir string) (SessionState, error) {
+	if rootDir == "" {
+		return defaultSessionState(), nil
+	}
+	path, err := getSessionFilePath(rootDir)
+	if err != nil {
+		return SessionState{}, err
+	}
+	data, err := loadSidecar(rootDir, path, sessionSchema)
+	if err != nil {
+		return SessionState{}, err
+	}
+	if data == nil {
+		return newSessionState(rootDir), nil
+	}
+
+	var state SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return SessionState{}, err
+	}
+	return normalizeSessionState(state), nil
+}
+
+// migrateSessionV0 は開いていたファイルのパスの配列だけを保存していた session.json を変換します
+func migrateSessionV0(rootDir string, data []byte) ([]byte, error) {
+	var filePaths []string
+	if err := json.Unmarshal(data, &filePaths); err != nil {
+		return nil, err
+	}
+	state := defaultSessionState()
+	state.Version = 1
+	for _, path := range filePaths {
+		state.Tabs = append(state.Tabs, SessionTab{Path: path})
+	}
+	// 以前のフロントエンドはパスの順にタブを開いていたので、最後のタブがアクティブになっていた
+	if len(filePaths) > 0 {
+		state.ActiveFile = filePaths[len(filePaths)-1]
+	}
+	return json.MarshalIndent(normalizeSessionState(state), "", "  ")
+}
+
+// normalizeSessionState は壊れた値や範囲外の値を既定値に戻します。
+// 同じファイルのタブは最初のものだけを残し、アクティブなファイルがタブにない場合は最初のタブにします
+func normalizeSessionState(state SessionState) SessionState {
+	switch state.ViewMode {
+	case ViewModeEditor, ViewModeSplit, ViewModePreview:
+	default:
+		state.ViewMode = ViewModeSplit
+	}
+	if state.EditorWidth <= 0 || state.EditorWidth >= 100 {
+		state.EditorWidth = defaultEditorWidth
+	}
+
+	tabs := make([]SessionTab, 0, len(state.Tabs))
+	for _, tab := range state.Tabs {
+		if tab.Path == "" || slices.ContainsFunc(tabs, func(t SessionTab) bool { return t.Path == tab.Path }) {
+			continue
+		}
+		tab.Cursor.Line = max(tab.Cursor.Line, 1)
+		tab.Cursor.Column = max(tab.Cursor.Column, 1)
+		tab.EditorScrollTop = max(tab.EditorScrollTop, 0)
+		tab.PreviewScrollTop = max(tab.PreviewScrollTop, 0)
+		tabs = append(tabs, tab)
+	}
+	state.Tabs = tabs
+
+	if !slices.ContainsFunc(tabs, func(t SessionTab) bool { return t.Path == state.ActiveFile }) {
+		state.ActiveFile = ""
+		if len(tabs) > 0 {
+			state.ActiveFile = tabs[0].Path
+		}
+	}
+	return state
+}
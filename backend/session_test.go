@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoadSessionState(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	state := SessionState{
+		ActiveFile:  "/notes/b.md",
+		ViewMode:    ViewModePreview,
+		EditorWidth: 35,
+		Tabs: []SessionTab{
+			{Path: "/notes/a.md", Cursor: CursorPosition{Line: 10, Column: 4}, EditorScrollTop: 120, PreviewScrollTop: 300},
+			{Path: "/notes/b.md", Cursor: CursorPosition{Line: 1, Column: 1}},
+		},
+	}
+	if err := SaveSessionState(tmpDir, state); err != nil {
+		t.Fatalf("SaveSessionState failed: %v", err)
+	}
+	loaded, err := LoadSessionState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadSessionState failed: %v", err)
+	}
+	state.Version = sessionVersion
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("Expected %+v, but got %+v", state, loaded)
+	}
+
+	// 開いているファイルの一覧だけを保存しても、残ったタブの状態は引き継がれる
+	if err := SaveSession(tmpDir, []string{"/notes/a.md", "/notes/c.md"}); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	loaded, err = LoadSessionState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadSessionState failed: %v", err)
+	}
+	expectedTabs := []SessionTab{state.Tabs[0], {Path: "/notes/c.md", Cursor: CursorPosition{Line: 1, Column: 1}}}
+	if !reflect.DeepEqual(loaded.Tabs, expectedTabs) || loaded.ActiveFile != "/notes/a.md" || loaded.ViewMode != ViewModePreview {
+		t.Errorf("Unexpected state after SaveSession: %+v", loaded)
+	}
+}
+
+func TestLoadSessionState_Migration(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ensureSessionDirExists(tmpDir); err != nil {
+		t.Fatalf("Failed to create session dir: %v", err)
+	}
+	sessionFilePath, _ := getSessionFilePath(tmpDir)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected SessionState
+		err      error
+	}{
+		{
+			name:    "legacy array",
+			content: `["/notes/a.md","/notes/b.md","/notes/a.md"]`,
+			expected: SessionState{
+				Version:     sessionVersion,
+				ActiveFile:  "/notes/a.md",
+				ViewMode:    ViewModeSplit,
+				EditorWidth: defaultEditorWidth,
+				Tabs: []SessionTab{
+					{Path: "/notes/a.md", Cursor: CursorPosition{Line: 1, Column: 1}},
+					{Path: "/notes/b.md", Cursor: CursorPosition{Line: 1, Column: 1}},
+				},
+			},
+		},
+		{
+			name:     "empty file",
+			content:  "",
+			expected: defaultSessionState(),
+		},
+		{
+			name:    "invalid values",
+			content: `{"version":1,"active_file":"/gone.md","view_mode":"zen","editor_width":150,"tabs":[{"path":"/notes/a.md","editor_scroll_top":-5}]}`,
+			expected: SessionState{
+				Version:     sessionVersion,
+				ActiveFile:  "/notes/a.md",
+				ViewMode:    ViewModeSplit,
+				EditorWidth: defaultEditorWidth,
+				Tabs:        []SessionTab{{Path: "/notes/a.md", Cursor: CursorPosition{Line: 1, Column: 1}}},
+			},
+		},
+		{
+			name:    "newer version",
+			content: `{"version":99,"tabs":[]}`,
+			err:     ErrUnsupportedSchemaVersion,
+		},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(sessionFilePath, []byte(tt.content), 0644); err != nil {
+			t.Fatalf("Failed to write session file: %v", err)
+		}
+		state, err := LoadSessionState(tmpDir)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, but got %v", tt.name, tt.err, err)
+			continue
+		}
+		if tt.err == nil && !reflect.DeepEqual(state, tt.expected) {
+			t.Errorf("%s: expected %+v, but got %+v", tt.name, tt.expected, state)
+		}
+	}
+
+	// 古い形式でも LoadSession は従来どおりパスの一覧を返す
+	if err := os.WriteFile(sessionFilePath, []byte(`["/notes/a.md"]`), 0644); err != nil {
+		t.Fatalf("Failed to write session file: %v", err)
+	}
+	paths, err := LoadSession(tmpDir)
+	if err != nil || !reflect.DeepEqual(paths, []string{"/notes/a.md"}) {
+		t.Errorf("Expected [/notes/a.md], but got %v (%v)", paths, err)
+	}
+}
+
+func TestLoadSessionState_DefaultViewMode(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"editor_settings": map[string]any{"default_view_mode": ViewModePreview}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	state, err := LoadSessionState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadSessionState failed: %v", err)
+	}
+	if state.ViewMode != ViewModePreview {
+		t.Errorf("Expected a new session to start in %q, but got %q", ViewModePreview, state.ViewMode)
+	}
+
+	// 保存したセッションの表示モードは既定の表示モードより優先される
+	state.ViewMode = ViewModeEditor
+	if err := SaveSessionState(tmpDir, state); err != nil {
+		t.Fatalf("SaveSessionState failed: %v", err)
+	}
+	state, err = LoadSessionState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadSessionState failed: %v", err)
+	}
+	if state.ViewMode != ViewModeEditor {
+		t.Errorf("Expected the saved view mode %q, but got %q", ViewModeEditor, state.ViewMode)
+	}
+}
@@ -0,0 +1,424 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+
+	minFontSize         = 6
+	maxFontSize         = 72
+	maxTabSize          = 16
+	maxAutosaveInterval = 3600
+)
+
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// ProjectConfigError は config.json が JSON として読めないか、正しくない設定を含んでいることを表します
+type ProjectConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ProjectConfigError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *ProjectConfigError) Unwrap() error {
+	return e.Err
+}
+
+// spellcheckLanguageRegexp は en-US のような BCP 47 の言語タグです
+var spellcheckLanguageRegexp = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// EditorSettings はエディタの表示と動作の設定です。
+// AutosaveInterval は自動保存の間隔 (秒) で、0 の場合は自動保存しません。SpellcheckLanguage が空の場合はスペルチェックしません
+type EditorSettings struct {
+	Theme              string `json:"theme"`
+	TabSize            int    `json:"tab_size"`
+	LineWrapping       bool   `json:"line_wrapping"`
+	LineNumbers        bool   `json:"line_numbers"`
+	AutosaveInterval   int    `json:"autosave_interval"`
+	DefaultViewMode    string `json:"default_view_mode"`
+	SpellcheckLanguage string `json:"spellcheck_language"`
+}
+
+func getDefaultEditorSettings() EditorSettings {
+	return EditorSettings{
+		Theme:            ThemeSystem,
+		TabSize:          4,
+		LineWrapping:     true,
+		LineNumbers:      true,
+		AutosaveInterval: 0,
+		DefaultViewMode:  ViewModeSplit,
+	}
+}
+
+// ValidateProjectConfig は設定の値がすべて範囲内にあることを確かめます
+func ValidateProjectConfig(config ProjectConfig) error {
+	font := config.FontSettings
+	editor := config.EditorSettings
+	switch {
+	case font.EditorFontSize < minFontSize || font.EditorFontSize > maxFontSize:
+		return invalidSetting("font_settings.editor_font_size", "must be between %d and %d", minFontSize, maxFontSize)
+	case font.PreviewFontSize < minFontSize || font.PreviewFontSize > maxFontSize:
+		return invalidSetting("font_settings.preview_font_size", "must be between %d and %d", minFontSize, maxFontSize)
+	case !slices.Contains([]string{ThemeLight, ThemeDark, ThemeSystem}, editor.Theme):
+		return invalidSetting("editor_settings.theme", "must be %q, %q or %q", ThemeLight, ThemeDark, ThemeSystem)
+	case editor.TabSize < 1 || editor.TabSize > maxTabSize:
+		return invalidSetting("editor_settings.tab_size", "must be between 1 and %d", maxTabSize)
+	case editor.AutosaveInterval < 0 || editor.AutosaveInterval > maxAutosaveInterval:
+		return invalidSetting("editor_settings.autosave_interval", "must be between 0 and %d seconds", maxAutosaveInterval)
+	case !slices.Contains([]string{ViewModeEditor, ViewModeSplit, ViewModePreview}, editor.DefaultViewMode):
+		return invalidSetting("editor_settings.default_view_mode", "must be %q, %q or %q", ViewModeEditor, ViewModeSplit, ViewModePreview)
+	case editor.SpellcheckLanguage != "" && !spellcheckLanguageRegexp.MatchString(editor.SpellcheckLanguage):
+		return invalidSetting("editor_settings.spellcheck_language", "must be empty or a language tag such as en-US")
+	}
+	return validateTheoremSettings(config.TheoremSettings)
+}
+
+func invalidSetting(key string, format string, args ...any) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidSettings, key, fmt.Sprintf(format, args...))
+}
+
+// 設定は既定値、グローバル設定、プロジェクトの config.json、ノートのフロントマターの順に重ねて決まります。
+// 既定値以外の層は config.json と同じ形の JSON オブジェクトのうち、上書きするキーだけを持ちます
+const (
+	SettingSourceDefault = "default"
+	SettingSourceGlobal  = "global"
+	SettingSourceProject = "project"
+	SettingSourceNote    = "note"
+)
+
+// EffectiveSettings は重ねた結果の設定です。Sources は "editor_settings.tab_size" のような設定のキーから、
+// 値を決めた層 (SettingSource*) への対応です。Warnings は無視した不正な上書きです
+type EffectiveSettings struct {
+	Config   ProjectConfig     `json:"config"`
+	Sources  map[string]string `json:"sources"`
+	Warnings []string          `json:"warnings"`
+}
+
+// ResolveSettings は global の上にプロジェクトの設定と、notePath が空でない場合はそのノートのフロントマターの設定を重ねます。
+// ノートでは "editor_settings.tab_size: 2" のように、ドットで区切ったキーで設定を上書きします
+func ResolveSettings(rootDir string, global map[string]any, notePath string) (EffectiveSettings, error) {
+	effective := EffectiveSettings{Sources: make(map[string]string), Warnings: []string{}}
+	merged := defaultSettingsLayer()
+	overlaySettings(merged, merged, SettingSourceDefault, effective.Sources, "")
+
+	if len(global) > 0 {
+		if _, err := patchSettingsLayer(nil, global); err != nil {
+			effective.Warnings = append(effective.Warnings, fmt.Sprintf("global settings are ignored: %v", err))
+		} else {
+			overlaySettings(merged, global, SettingSourceGlobal, effective.Sources, "")
+		}
+	}
+
+	if rootDir != "" {
+		project, err := loadProjectSettingsLayer(rootDir)
+		if err != nil {
+			return EffectiveSettings{}, err
+		}
+		if err := checkProjectSettingsLayer(rootDir, project); err != nil {
+			return EffectiveSettings{}, err
+		}
+		overlaySettings(merged, project, SettingSourceProject, effective.Sources, "")
+	}
+
+	if notePath != "" {
+		content, err := ReadFile(notePath)
+		if err != nil {
+			return EffectiveSettings{}, err
+		}
+		note, warnings := noteSettingsLayer(content)
+		effective.Warnings = append(effective.Warnings, warnings...)
+		overlaySettings(merged, note, SettingSourceNote, effective.Sources, "")
+	}
+
+	config, err := decodeSettings(merged)
+	if err != nil {
+		return EffectiveSettings{}, err
+	}
+	effective.Config = config
+	return effective, nil
+}
+
+// PatchProjectConfig は patch に含まれる設定だけをプロジェクトの config.json で上書きし、既定値に重ねた設定を返します。
+// patch は {"editor_settings": {"tab_size": 2}} のように一部のキーだけを指定でき、値が null のキーは上書きをやめて下の層の値に戻します。
+// 存在しないキーや型の違う値、範囲外の値を指定した場合は何も保存せずに ErrInvalidSettings を返します
+func PatchProjectConfig(rootDir string, patch map[string]any) (ProjectConfig, error) {
+	if rootDir == "" {
+		return ProjectConfig{}, os.ErrInvalid
+	}
+	layer, err := loadProjectSettingsLayer(rootDir)
+	if err != nil {
+		return ProjectConfig{}, err
+	}
+	layer, err = patchSettingsLayer(layer, patch)
+	if err != nil {
+		return ProjectConfig{}, err
+	}
+	if err := saveProjectSettingsLayer(rootDir, layer); err != nil {
+		return ProjectConfig{}, err
+	}
+	return LoadProjectConfig(rootDir)
+}
+
+func loadProjectSettingsLayer(rootDir string) (map[string]any, error) {
+	path, err := getProjectConfigPath(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := loadSidecar(rootDir, path, configSchema)
+	if err != nil {
+		return nil, &ProjectConfigError{Path: path, Err: err}
+	}
+	return decodeProjectSettingsLayer(path, data)
+}
+
+// decodeProjectSettingsLayer は現在の形式の config.json の内容を上書きの層にします。data が nil の場合は空の層です
+func decodeProjectSettingsLayer(path string, data []byte) (map[string]any, error) {
+	layer := make(map[string]any)
+	if data == nil {
+		return layer, nil
+	}
+	if err := json.Unmarshal(data, &layer); err != nil {
+		return nil, &ProjectConfigError{Path: path, Err: err}
+	}
+	delete(layer, "version")
+	return layer, nil
+}
+
+// checkProjectConfigData は config.json の内容 data が読み込める正しい設定かどうかを確かめます。
+// 古い形式の内容はメモリの上で変換して確かめ、ファイルの変換や書き戻しはしません
+func checkProjectConfigData(rootDir string, data []byte) error {
+	path, err := getProjectConfigPath(rootDir)
+	if err != nil {
+		return err
+	}
+	var migrated []byte
+	if len(bytes.TrimSpace(data)) > 0 {
+		if migrated, _, err = migrateSidecar(rootDir, configSchema, data); err != nil {
+			return &ProjectConfigError{Path: path, Err: err}
+		}
+	}
+	layer, err := decodeProjectSettingsLayer(path, migrated)
+	if err != nil {
+		return err
+	}
+	return checkProjectSettingsLayer(rootDir, layer)
+}
+
+// checkProjectSettingsLayer は config.json の設定を既定値に重ねても正しい設定になることを確かめます。
+// アプリの外で編集された config.json の誤りを、既定値で黙って置き換えずにエラーにするために使います
+func checkProjectSettingsLayer(rootDir string, layer map[string]any) error {
+	settings := cloneSettings(layer)
+	// マクロは設定ではなく LoadMathMacros が読む
+	delete(settings, macrosConfigKey)
+	if _, err := patchSettingsLayer(nil, settings); err != nil {
+		path, _ := getProjectConfigPath(rootDir)
+		return &ProjectConfigError{Path: path, Err: err}
+	}
+	return nil
+}
+
+func saveProjectSettingsLayer(rootDir string, layer map[string]any) error {
+	if err := os.MkdirAll(filepath.Join(rootDir, projectConfigDir), 0755); err != nil {
+		return err
+	}
+	path, err := getProjectConfigPath(rootDir)
+	if err != nil {
+		return err
+	}
+	file := cloneSettings(layer)
+	file["version"] = configSchema.version()
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	recordAppWrite(path, data)
+	// 監視が書き込み途中の内容を読んで壊れた設定として通知しないように、一度に置き換える
+	return writeFileAtomic(path, data, 0644)
+}
+
+// noteSettingsLayer はフロントマターのうち、ドットで区切った設定のキーを上書きの層にします。
+// フロントマターの値は文字列なので、既定値の型に合わせて変換します。変換できない値や範囲外の値は警告にして無視します
+func noteSettingsLayer(content string) (map[string]any, []string) {
+	layer := make(map[string]any)
+	var warnings []string
+	fm, _, ok := splitFrontmatter(content)
+	if !ok {
+		return layer, warnings
+	}
+	values, err := parseFrontmatter(fm)
+	if err != nil {
+		return layer, warnings
+	}
+
+	defaults := defaultSettingsLayer()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		group, name, found := strings.Cut(key, ".")
+		groupDefaults, isGroup := defaults[group].(map[string]any)
+		if !found || !isGroup {
+			// 設定ではないフロントマター
+			continue
+		}
+		raw, isString := values[key].(string)
+		value, err := parseSettingValue(groupDefaults[name], raw)
+		if !isString {
+			err = errors.New("must be a single value")
+		}
+		if err == nil {
+			var next map[string]any
+			next, err = patchSettingsLayer(layer, map[string]any{group: map[string]any{name: value}})
+			if err == nil {
+				layer = next
+				continue
+			}
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: %v", key, err))
+	}
+	return layer, warnings
+}
+
+// parseSettingValue はフロントマターの文字列を、既定値 def と同じ JSON の型の値に変換します
+func parseSettingValue(def any, raw string) (any, error) {
+	switch def.(type) {
+	case bool:
+		switch strings.ToLower(raw) {
+		case "true", "yes", "on":
+			return true, nil
+		case "false", "no", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", raw)
+	case float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return n, nil
+	case nil:
+		return nil, errors.New("is not a known setting")
+	}
+	return raw, nil
+}
+
+// patchSettingsLayer は layer に patch を適用した新しい層を返します。layer は変更しません。
+// 適用した結果を既定値に重ねても正しい設定になることを確かめます
+func patchSettingsLayer(layer map[string]any, patch map[string]any) (map[string]any, error) {
+	next := cloneSettings(layer)
+	if err := applySettingsPatch(next, patch, defaultSettingsLayer(), ""); err != nil {
+		return nil, err
+	}
+	merged := defaultSettingsLayer()
+	overlaySettings(merged, next, "", nil, "")
+	config, err := decodeSettings(merged)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateProjectConfig(config); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// applySettingsPatch は patch の値を dst に上書きします。schema は既定値の層で、存在しないキーを拒否するために使います
+func applySettingsPatch(dst map[string]any, patch map[string]any, schema map[string]any, prefix string) error {
+	for key, value := range patch {
+		def, ok := schema[key]
+		if !ok {
+			return invalidSetting(prefix+key, "is not a known setting")
+		}
+		defMap, defIsMap := def.(map[string]any)
+		valueMap, valueIsMap := value.(map[string]any)
+		switch {
+		case value == nil:
+			delete(dst, key)
+		case defIsMap && valueIsMap:
+			current, _ := dst[key].(map[string]any)
+			if current == nil {
+				current = make(map[string]any)
+			}
+			if err := applySettingsPatch(current, valueMap, defMap, prefix+key+"."); err != nil {
+				return err
+			}
+			if len(current) == 0 {
+				delete(dst, key)
+			} else {
+				dst[key] = current
+			}
+		case defIsMap || valueIsMap:
+			return invalidSetting(prefix+key, "has the wrong type")
+		default:
+			dst[key] = value
+		}
+	}
+	return nil
+}
+
+// overlaySettings は layer の値を dst に上書きし、上書きした値の層を sources に記録します
+func overlaySettings(dst map[string]any, layer map[string]any, source string, sources map[string]string, prefix string) {
+	for key, value := range layer {
+		if valueMap, ok := value.(map[string]any); ok {
+			current, ok := dst[key].(map[string]any)
+			if !ok {
+				current = make(map[string]any)
+				dst[key] = current
+			}
+			overlaySettings(current, valueMap, source, sources, prefix+key+".")
+			continue
+		}
+		dst[key] = value
+		if sources != nil {
+			sources[prefix+key] = source
+		}
+	}
+}
+
+func defaultSettingsLayer() map[string]any {
+	data, _ := json.Marshal(getDefaultProjectConfig())
+	var layer map[string]any
+	_ = json.Unmarshal(data, &layer)
+	return layer
+}
+
+// cloneSettings は層を深くコピーします
+func cloneSettings(layer map[string]any) map[string]any {
+	clone := make(map[string]any, len(layer))
+	for key, value := range layer {
+		if valueMap, ok := value.(map[string]any); ok {
+			value = cloneSettings(valueMap)
+		}
+		clone[key] = value
+	}
+	return clone
+}
+
+func decodeSettings(merged map[string]any) (ProjectConfig, error) {
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return ProjectConfig{}, err
+	}
+	var config ProjectConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ProjectConfig{}, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPatchProjectConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	config, err := PatchProjectConfig(tmpDir, map[string]any{
+		"editor_settings": map[string]any{"tab_size": 2, "theme": ThemeDark, "spellcheck_language": "en-US"},
+		"font_settings":   map[string]any{"editor_font_size": 16},
+	})
+	if err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	expected := getDefaultProjectConfig()
+	expected.EditorSettings.TabSize = 2
+	expected.EditorSettings.Theme = ThemeDark
+	expected.EditorSettings.SpellcheckLanguage = "en-US"
+	expected.FontSettings.EditorFontSize = 16
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, config)
+	}
+	loaded, err := LoadProjectConfig(tmpDir)
+	if err != nil || !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected saved config %+v, but got %+v (%v)", expected, loaded, err)
+	}
+
+	// config.json には上書きした値だけを保存する
+	layer, err := loadProjectSettingsLayer(tmpDir)
+	if err != nil {
+		t.Fatalf("loadProjectSettingsLayer failed: %v", err)
+	}
+	expectedLayer := map[string]any{
+		"editor_settings": map[string]any{"tab_size": float64(2), "theme": ThemeDark, "spellcheck_language": "en-US"},
+		"font_settings":   map[string]any{"editor_font_size": float64(16)},
+	}
+	if !reflect.DeepEqual(layer, expectedLayer) {
+		t.Errorf("Expected project layer %v, but got %v", expectedLayer, layer)
+	}
+
+	invalid := []struct {
+		name  string
+		patch map[string]any
+	}{
+		{"unknown key", map[string]any{"editor_settings": map[string]any{"vim_mode": true}}},
+		{"unknown group", map[string]any{"keybindings": map[string]any{}}},
+		{"wrong type", map[string]any{"editor_settings": map[string]any{"tab_size": "wide"}}},
+		{"object replaced by value", map[string]any{"editor_settings": 4}},
+		{"tab size out of range", map[string]any{"editor_settings": map[string]any{"tab_size": 0}}},
+		{"unknown theme", map[string]any{"editor_settings": map[string]any{"theme": "solarized"}}},
+		{"negative autosave", map[string]any{"editor_settings": map[string]any{"autosave_interval": -1}}},
+		{"unknown view mode", map[string]any{"editor_settings": map[string]any{"default_view_mode": "zen"}}},
+		{"invalid language", map[string]any{"editor_settings": map[string]any{"spellcheck_language": "english!"}}},
+		{"font too small", map[string]any{"font_settings": map[string]any{"preview_font_size": 1}}},
+	}
+	for _, tt := range invalid {
+		if _, err := PatchProjectConfig(tmpDir, tt.patch); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("%s: expected ErrInvalidSettings, but got %v", tt.name, err)
+		}
+	}
+	// 不正な変更は保存しない
+	loaded, err = LoadProjectConfig(tmpDir)
+	if err != nil || !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Invalid patch changed the config: %+v (%v)", loaded, err)
+	}
+
+	// null は上書きをやめて既定値に戻す
+	config, err = PatchProjectConfig(tmpDir, map[string]any{"font_settings": map[string]any{"editor_font_size": nil}})
+	if err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	expected.FontSettings.EditorFontSize = getDefaultProjectConfig().FontSettings.EditorFontSize
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, config)
+	}
+	if layer, _ := loadProjectSettingsLayer(tmpDir); layer["font_settings"] != nil {
+		t.Errorf("Expected font_settings to be removed, but got %v", layer)
+	}
+}
+
+func TestResolveSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	global := map[string]any{
+		"editor_settings": map[string]any{"theme": ThemeDark, "tab_size": float64(8)},
+		"font_settings":   map[string]any{"preview_font_size": float64(18)},
+	}
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"editor_settings": map[string]any{"tab_size": 2}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	notePath := filepath.Join(tmpDir, "note.md")
+	note := "---\ntitle: Note\neditor_settings.line_wrapping: no\neditor_settings.tab_size: wide\neditor_settings.vim_mode: true\n---\n# Note\n"
+	if err := os.WriteFile(notePath, []byte(note), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	effective, err := ResolveSettings(tmpDir, global, notePath)
+	if err != nil {
+		t.Fatalf("ResolveSettings failed: %v", err)
+	}
+	expected := getDefaultProjectConfig()
+	expected.EditorSettings.Theme = ThemeDark
+	expected.EditorSettings.TabSize = 2
+	expected.EditorSettings.LineWrapping = false
+	expected.FontSettings.PreviewFontSize = 18
+	if !reflect.DeepEqual(effective.Config, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, effective.Config)
+	}
+
+	expectedSources := map[string]string{
+		"editor_settings.theme":           SettingSourceGlobal,
+		"editor_settings.tab_size":        SettingSourceProject,
+		"editor_settings.line_wrapping":   SettingSourceNote,
+		"editor_settings.line_numbers":    SettingSourceDefault,
+		"font_settings.preview_font_size": SettingSourceGlobal,
+		"font_settings.editor_font_size":  SettingSourceDefault,
+	}
+	for key, source := range expectedSources {
+		if effective.Sources[key] != source {
+			t.Errorf("%s: expected source %q, but got %q", key, source, effective.Sources[key])
+		}
+	}
+	if len(effective.Warnings) != 2 {
+		t.Errorf("Expected 2 warnings for the invalid note settings, but got %v", effective.Warnings)
+	}
+
+	// 不正なグローバル設定は警告にして無視する
+	effective, err = ResolveSettings(tmpDir, map[string]any{"editor_settings": map[string]any{"theme": "neon"}}, "")
+	if err != nil {
+		t.Fatalf("ResolveSettings failed: %v", err)
+	}
+	if effective.Config.EditorSettings.Theme != ThemeSystem || len(effective.Warnings) != 1 {
+		t.Errorf("Expected invalid global settings to be ignored, but got %+v", effective)
+	}
+}
+
+func TestConfigManager_DefaultSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "config.json")
+	cm, err := newConfigManager(path)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	if err := cm.PatchDefaultSettings(map[string]any{"editor_settings": map[string]any{"tab_size": 3}}); err != nil {
+		t.Fatalf("PatchDefaultSettings failed: %v", err)
+	}
+	if err := cm.PatchDefaultSettings(map[string]any{"editor_settings": map[string]any{"tab_size": 100}}); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings, but got %v", err)
+	}
+
+	// 別の ConfigManager で読み直しても残っている
+	cm, err = newConfigManager(path)
+	if err != nil {
+		t.Fatalf("newConfigManager failed: %v", err)
+	}
+	expected := map[string]any{"editor_settings": map[string]any{"tab_size": float64(3)}}
+	if settings := cm.GetDefaultSettings(); !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %v, but got %v", expected, settings)
+	}
+}
+
+func TestLoadProjectConfig_MissingEditorSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, projectConfigDir), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	path, _ := getProjectConfigPath(tmpDir)
+	content := `{"version":1,"font_settings":{"editor_font_family":"mono","editor_font_size":16,"preview_font_family":"serif","preview_font_size":18}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadProjectConfig(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(config.EditorSettings, getDefaultEditorSettings()) {
+		t.Errorf("Expected default editor settings, but got %+v", config.EditorSettings)
+	}
+	if err := ValidateProjectConfig(config); err != nil {
+		t.Errorf("Expected valid config, but got %v", err)
+	}
+}
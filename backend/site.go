@@ -0,0 +1,546 @@
+package backend
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	siteAssetsDirName    = "assets"
+	siteIndexFileName    = "index.html"
+	siteTheoremsFileName = "theorems.html"
+
+	katexCDN     = "https://cdn.jsdelivr.net/npm/katex@0.16.22/dist/"
+	highlightCDN = "https://cdn.jsdelivr.net/npm/@highlightjs/cdn-assets@11.11.1/"
+	mermaidCDN   = "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs"
+)
+
+var (
+	cssImportRegexp = regexp.MustCompile(`(?m)^@import [^;]*;\n?`)
+	cssDeepRegexp   = regexp.MustCompile(`:deep\(([^)]*)\)`)
+)
+
+// siteStyle はプレビュー (MarkdownEditor.vue) のスタイルのうち、ページの表示に必要なものです
+const siteStyle = `:root {
+  --bg-color: #ffffff;
+  --sidebar-bg: #f5f5f5;
+  --sidebar-header-bg: #e8e8e8;
+  --text-color: #333333;
+  --border-color: #dddddd;
+  --accent-color: #0078d7;
+}
+
+body {
+  margin: 0;
+  color: var(--text-color);
+  background-color: var(--bg-color);
+  font-family: sans-serif;
+  line-height: 1.6;
+}
+
+.site-nav {
+  display: flex;
+  gap: 1em;
+  padding: 0.5em 1em;
+  background-color: var(--sidebar-header-bg);
+  border-bottom: 1px solid var(--border-color);
+}
+
+.site-nav a {
+  color: var(--text-color);
+  text-decoration: none;
+}
+
+.markdown-preview {
+  max-width: 900px;
+  margin: 0 auto;
+  padding: 1em 2em;
+}
+
+.markdown-preview a {
+  color: var(--accent-color);
+}
+
+.markdown-preview blockquote {
+  margin: 1em 0;
+  padding-left: 1em;
+  border-left: 4px solid var(--accent-color);
+  background-color: var(--sidebar-bg);
+  color: #666;
+}
+
+.markdown-preview code {
+  background-color: var(--sidebar-bg);
+  padding: 0.2em 0.4em;
+  border-radius: 3px;
+  font-family: 'Consolas', 'Monaco', 'Courier New', monospace;
+  font-size: 0.9em;
+}
+
+.markdown-preview pre {
+  background-color: var(--sidebar-bg);
+  padding: 1em;
+  border-radius: 5px;
+  overflow-x: auto;
+}
+
+.markdown-preview pre code {
+  background-color: transparent;
+  padding: 0;
+}
+
+.markdown-preview table {
+  border-collapse: collapse;
+  margin: 1em 0;
+}
+
+.markdown-preview th,
+.markdown-preview td {
+  border: 1px solid var(--border-color);
+  padding: 0.5em;
+}
+
+.markdown-preview th {
+  background-color: var(--sidebar-header-bg);
+}
+
+.markdown-preview img {
+  max-width: 100%;
+}
+
+.markdown-preview .mermaid {
+  padding: 1em;
+  text-align: center;
+}
+
+.markdown-preview .card-link {
+  display: flex;
+  background-color: #ffffff;
+  border: 1px solid #b1b8bd;
+  border-radius: 8px;
+  overflow: hidden;
+  text-decoration: none;
+  color: #14171a;
+}
+
+.markdown-preview .card-content {
+  flex: 1;
+  padding: 10px;
+  min-width: 0;
+}
+
+.markdown-preview .card-title {
+  font-weight: 600;
+  margin: 0 0 4px;
+}
+
+.markdown-preview .card-description,
+.markdown-preview .card-footer {
+  color: #657786;
+  margin: 0;
+}
+
+.markdown-preview .card-favicon {
+  width: 16px;
+  height: 16px;
+  margin-right: 8px;
+}
+
+.markdown-preview .card-thumbnail {
+  width: 130px;
+  flex-shrink: 0;
+}
+
+.markdown-preview .card-thumbnail img {
+  width: 100%;
+  height: 100%;
+  object-fit: cover;
+}
+
+.markdown-preview .theorem {
+  margin-top: 10px;
+  padding: 0.5em;
+  border: 3px solid var(--accent-color);
+  border-radius: 5px;
+  box-shadow: 0 8px 10px rgba(0, 0, 0, 0.1);
+  background-color: var(--sidebar-bg);
+}
+
+.markdown-preview .theorem-title {
+  font-weight: bold;
+  margin: 0;
+  padding: 5px 5px 0 5px;
+  font-size: 1.5em;
+  border-bottom: 2px solid var(--text-color);
+}
+
+.markdown-preview .theorem-label {
+  margin-right: 0.5em;
+}
+
+.markdown-preview .theorem-content p,
+.markdown-preview .theorem-content ul,
+.markdown-preview .theorem-content ol {
+  margin: 0;
+}
+
+.markdown-preview .theorem-content h3 {
+  margin-bottom: 5px;
+}
+
+.markdown-preview .tag {
+  color: #657786;
+  font-size: 0.9em;
+}
+`
+
+// siteScript は KaTeX とハイライトを読み込んだ後に、数式とコードを描画します
+const siteScript = `document.addEventListener('DOMContentLoaded', () => {
+  document.querySelectorAll('code.language-math').forEach((el) => {
+    const displayMode = el.classList.contains('math-display');
+    const target = displayMode ? el.parentElement : el;
+    const container = document.createElement(displayMode ? 'div' : 'span');
+    katex.render(el.textContent, container, { displayMode, throwOnError: false });
+    target.replaceWith(container);
+  });
+  hljs.highlightAll();
+});
+`
+
+// SiteExportOptions は HTML サイトのエクスポートの対象と出力先を指定します。
+// Paths が空の場合はプロジェクト内のすべてのノートを出力し、Title が空の場合はプロジェクトのディレクトリ名を使います
+type SiteExportOptions struct {
+	Paths     []string `json:"paths"`
+	OutputDir string   `json:"output_dir"`
+	Title     string   `json:"title"`
+}
+
+type sitePage struct {
+	path    string
+	rel     string
+	htmlRel string
+	title   string
+	doc     *Node
+	entry   NoteIndexEntry
+}
+
+// ExportSite はノートを HTML に変換し、閲覧用のサイトとして出力します。
+// [[...]] は出力したページへの相対 URL に、![[...]] は _images のコピーへの参照になります。
+// styles の .css (プレビューの KaTeX とハイライトのスタイル) はサイトのスタイルにまとめられます。
+// 数式とハイライトは KaTeX と highlight.js を CDN から読み込んでブラウザで描画します
+func ExportSite(rootDir string, opts SiteExportOptions, styles fs.FS) (ExportResult, error) {
+	if opts.OutputDir == "" {
+		return ExportResult{}, ErrEmptyOutputDir
+	}
+	paths, err := resolveExportPaths(rootDir, opts.Paths)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if opts.Title == "" {
+		opts.Title = filepath.Base(rootDir)
+	}
+
+	result := ExportResult{Files: []string{}, Warnings: []string{}}
+	macros, warnings, err := loadMathMacroSet(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	result.Warnings = append(result.Warnings, warnings...)
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	pages := make(map[string]*sitePage, len(paths))
+	ordered := make([]*sitePage, 0, len(paths))
+	for _, p := range paths {
+		content, err := ReadFile(p)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		rel, err := filepath.Rel(rootDir, p)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		rel = filepath.ToSlash(rel)
+		page := &sitePage{
+			path:    p,
+			rel:     rel,
+			htmlRel: siteHTMLPath(rel),
+			doc:     parseMarkdown(content, theorems),
+			entry:   buildIndexEntry(content, theorems),
+		}
+		page.title = noteTitle(page.rel, page.doc, page.entry.Metadata.Title)
+		pages[p] = page
+		ordered = append(ordered, page)
+	}
+
+	write := func(rel string, data string) error {
+		dst := filepath.Join(opts.OutputDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, []byte(data), 0644); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, dst)
+		return nil
+	}
+
+	style, err := bundleSiteStyles(styles)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if err := write(siteAssetsDirName+"/style.css", style); err != nil {
+		return ExportResult{}, err
+	}
+	if err := write(siteAssetsDirName+"/site.js", siteScript); err != nil {
+		return ExportResult{}, err
+	}
+	if err := copySiteImages(rootDir, opts.OutputDir); err != nil {
+		return ExportResult{}, err
+	}
+
+	for _, page := range ordered {
+		renderOpts := HTMLRenderOptions{
+			ExpandMath: macros.expand,
+			Theorems:   theorems,
+			ImageURL: func(name string) string {
+				return relativeURL(page.htmlRel, imagesDirName+"/"+filepath.ToSlash(name))
+			},
+			WikiLinkURL: func(node *Node) (string, bool) {
+				target, err := page.path, error(nil)
+				if node.Destination != "" {
+					target, err = resolveLinkPath(rootDir, node.Destination)
+				}
+				targetPage, ok := pages[target]
+				if err != nil || !ok {
+					result.Warnings = append(result.Warnings, fmt.Sprintf("%s: link [[%s]] does not point to an exported note", page.rel, node.Literal))
+					return "", false
+				}
+				href := relativeURL(page.htmlRel, targetPage.htmlRel)
+				if node.Fragment != "" {
+					if item, ok := findOutlineHeading(buildOutline(targetPage.doc.Children, targetPage.doc.EndLine, theorems), node.Fragment); ok {
+						href += "#" + item.Slug
+					}
+				}
+				return href, true
+			},
+		}
+		body := RenderHTML(page.doc, renderOpts)
+
+		hasMermaid := false
+		page.doc.Walk(func(node *Node) bool {
+			hasMermaid = hasMermaid || node.Kind == NodeKindMermaid
+			return !hasMermaid
+		})
+		if err := write(page.htmlRel, renderSitePage(opts.Title, theorems.CatalogTitle, page.title, page.htmlRel, body, hasMermaid)); err != nil {
+			return ExportResult{}, err
+		}
+	}
+
+	if err := write(siteIndexFileName, renderSitePage(opts.Title, theorems.CatalogTitle, opts.Title, siteIndexFileName, renderSiteIndex(ordered), false)); err != nil {
+		return ExportResult{}, err
+	}
+	if err := write(siteTheoremsFileName, renderSitePage(opts.Title, theorems.CatalogTitle, theorems.CatalogTitle, siteTheoremsFileName, renderSiteTheorems(ordered), false)); err != nil {
+		return ExportResult{}, err
+	}
+	return result, nil
+}
+
+// noteTitle はフロントマターの title、最初の見出し、ファイル名の順にノートのタイトルを決めます
+func noteTitle(rel string, doc *Node, metadataTitle string) string {
+	if metadataTitle != "" {
+		return metadataTitle
+	}
+	for _, node := range doc.Children {
+		if node.Kind == NodeKindHeading && node.Level == 1 {
+			return strings.TrimSpace(node.PlainText())
+		}
+	}
+	return strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+}
+
+// bundleSiteStyles はサイトのスタイルに、styles のスタイルを Vue の :deep() と外部の @import を取り除いて加えます
+func bundleSiteStyles(styles fs.FS) (string, error) {
+	var b strings.Builder
+	b.WriteString(siteStyle)
+	if styles == nil {
+		return b.String(), nil
+	}
+
+	var names []string
+	err := fs.WalkDir(styles, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && path.Ext(name) == ".css" {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		data, err := fs.ReadFile(styles, name)
+		if err != nil {
+			return "", err
+		}
+		css := cssImportRegexp.ReplaceAllString(string(data), "")
+		css = cssDeepRegexp.ReplaceAllString(css, "$1")
+		b.WriteString("\n/* " + path.Base(name) + " */\n" + css)
+	}
+	return b.String(), nil
+}
+
+func copySiteImages(rootDir string, outDir string) error {
+	src := filepath.Join(rootDir, imagesDirName)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(rootDir, p)
+		if err != nil {
+			return err
+		}
+		return copyFile(p, filepath.Join(outDir, rel))
+	})
+}
+
+// siteHTMLPath はノートの出力先のパスを返します。
+// ルートの index.md などサイトが生成するページと名前が重なる場合は、拡張子を残して区別します
+func siteHTMLPath(rel string) string {
+	htmlRel := strings.TrimSuffix(rel, path.Ext(rel)) + ".html"
+	if htmlRel == siteIndexFileName || htmlRel == siteTheoremsFileName {
+		return rel + ".html"
+	}
+	return htmlRel
+}
+
+// relativeURL は from のページから to への相対 URL を返します。どちらも出力先のルートからのスラッシュ区切りのパスです
+func relativeURL(from string, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		rel = to
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		if segment != ".." {
+			segments[i] = url.PathEscape(segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// renderSitePage は body をサイトのページにします。ナビゲーションには目次と、catalogTitle の名前で定理一覧へのリンクを置きます
+func renderSitePage(siteTitle string, catalogTitle string, title string, rel string, body string, hasMermaid bool) string {
+	asset := func(name string) string {
+		return html.EscapeString(relativeURL(rel, name))
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"ja\">\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	if title == siteTitle {
+		b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	} else {
+		b.WriteString("<title>" + html.EscapeString(title) + " - " + html.EscapeString(siteTitle) + "</title>\n")
+	}
+	b.WriteString("<link rel=\"stylesheet\" href=\"" + katexCDN + "katex.min.css\">\n")
+	b.WriteString("<link rel=\"stylesheet\" href=\"" + highlightCDN + "styles/github.min.css\">\n")
+	b.WriteString("<link rel=\"stylesheet\" href=\"" + asset(siteAssetsDirName+"/style.css") + "\">\n")
+	b.WriteString("<script defer src=\"" + katexCDN + "katex.min.js\"></script>\n")
+	b.WriteString("<script defer src=\"" + highlightCDN + "highlight.min.js\"></script>\n")
+	b.WriteString("<script defer src=\"" + asset(siteAssetsDirName+"/site.js") + "\"></script>\n")
+	if hasMermaid {
+		b.WriteString("<script type=\"module\">import mermaid from '" + mermaidCDN + "'; mermaid.run({ querySelector: '.mermaid' });</script>\n")
+	}
+	b.WriteString("</head>\n<body>\n<nav class=\"site-nav\">")
+	b.WriteString("<a href=\"" + asset(siteIndexFileName) + "\">" + html.EscapeString(siteTitle) + "</a>")
+	b.WriteString("<a href=\"" + asset(siteTheoremsFileName) + "\">" + html.EscapeString(catalogTitle) + "</a></nav>\n")
+	b.WriteString("<article class=\"markdown-preview\">\n" + body + "\n</article>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// renderSiteIndex はノートをディレクトリごとにまとめた目次を作ります。ルートのノートを先に並べます
+func renderSiteIndex(pages []*sitePage) string {
+	groups := make(map[string][]*sitePage)
+	var dirs []string
+	for _, page := range pages {
+		dir := path.Dir(page.rel)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], page)
+	}
+	slices.SortFunc(dirs, func(a, b string) int {
+		if (a == ".") != (b == ".") {
+			if a == "." {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	parts := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		var b strings.Builder
+		if dir != "." {
+			b.WriteString("<h2>" + html.EscapeString(dir) + "</h2>\n")
+		}
+		b.WriteString("<ul>\n")
+		for _, page := range groups[dir] {
+			b.WriteString("<li><a href=\"" + html.EscapeString(relativeURL(siteIndexFileName, page.htmlRel)) + "\">" +
+				html.EscapeString(page.title) + "</a></li>\n")
+		}
+		b.WriteString("</ul>")
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, "\n")
+}
+
+// renderSiteTheorems はノートごとの定理の一覧を作ります
+func renderSiteTheorems(pages []*sitePage) string {
+	var b strings.Builder
+	for _, page := range pages {
+		var theorems []*Node
+		page.doc.Walk(func(node *Node) bool {
+			if node.Kind == NodeKindTheorem && node.Title != "" {
+				theorems = append(theorems, node)
+			}
+			return true
+		})
+		if len(theorems) == 0 {
+			continue
+		}
+
+		href := relativeURL(siteTheoremsFileName, page.htmlRel)
+		b.WriteString("<h2><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(page.title) + "</a></h2>\n<ul>\n")
+		for i, theorem := range theorems {
+			b.WriteString("<li><a href=\"" + html.EscapeString(href+"#"+theorem.Slug) + "\">" + html.EscapeString(theorem.Title) + "</a>")
+			// インデックスの定理は名前のある定理ブロックと同じ順に並んでいる
+			if i < len(page.entry.Theorems) {
+				for _, tag := range page.entry.Theorems[i].Tags {
+					b.WriteString(" <span class=\"tag\">#" + html.EscapeString(tag) + "</span>")
+				}
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
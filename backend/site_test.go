@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExportSite(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rootDir := filepath.Join(tmpDir, "vault")
+	outDir := filepath.Join(tmpDir, "site")
+	files := map[string]string{
+		"index.md": "# トップ\n\n[[topology/compact#主張|主張へ]] と [[missing]]\n",
+		"topology/compact.md": "---\ntitle: コンパクト性\ntags: [topology]\n---\n" +
+			"<theorem name=\"ハイネ・ボレル\">\n### 主張\n#exam\n</theorem>\n\n" +
+			"[[index]] ![[図 1.png]]\n",
+		"_images/図 1.png": "png",
+	}
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	styles := fstest.MapFS{
+		"katex.css": {Data: []byte("@import 'katex/dist/katex.min.css';\n.markdown-preview :deep(.katex) {\n  font-size: 1.1em;\n}\n")},
+	}
+
+	result, err := ExportSite(rootDir, SiteExportOptions{OutputDir: outDir, Title: "講義ノート"}, styles)
+	if err != nil {
+		t.Fatalf("ExportSite failed: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "[[missing]]") {
+		t.Errorf("Expected a warning for the missing link, but got %v", result.Warnings)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	contains := func(name string, wants ...string) {
+		content := read(name)
+		for _, want := range wants {
+			if !strings.Contains(content, want) {
+				t.Errorf("%s does not contain %q:\n%s", name, want, content)
+			}
+		}
+	}
+
+	contains("index.html",
+		"<title>講義ノート</title>",
+		`<li><a href="index.md.html">トップ</a></li>`,
+		"<h2>topology</h2>\n<ul>\n<li><a href=\"topology/compact.html\">コンパクト性</a></li>",
+	)
+	contains("topology/compact.html",
+		`<title>コンパクト性 - 講義ノート</title>`,
+		`href="../assets/style.css"`,
+		`<h4 class="theorem-title" id="ハイネボレル"><span class="theorem-label">定理 1</span>ハイネ・ボレル</h4>`,
+		`<a href="../index.md.html">index</a>`,
+		`<img src="../_images/%E5%9B%B3%201.png" alt="図 1.png">`,
+	)
+	contains("index.md.html", `<a href="topology/compact.html#主張">主張へ</a>`)
+	contains("theorems.html",
+		`<h2><a href="topology/compact.html">コンパクト性</a></h2>`,
+		`<li><a href="topology/compact.html#ハイネボレル">ハイネ・ボレル</a> <span class="tag">#exam</span> <span class="tag">#topology</span></li>`,
+	)
+	contains("assets/style.css", ".markdown-preview .theorem {", ".markdown-preview .katex {")
+	if strings.Contains(read("assets/style.css"), "@import") {
+		t.Errorf("External @import should be removed from the bundled style")
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "_images", "図 1.png")); err != nil {
+		t.Errorf("Image was not copied: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"os"
+	"slices"
+	"unicode/utf8"
+)
+
+// VaultStats はプロジェクト全体の集計です。Tags は異なるタグの数で、Characters はノートの文字数の合計です
+type VaultStats struct {
+	Notes      int `json:"notes"`
+	Characters int `json:"characters"`
+	Theorems   int `json:"theorems"`
+	Proofs     int `json:"proofs"`
+	Tags       int `json:"tags"`
+	Links      int `json:"links"`
+	Images     int `json:"images"`
+}
+
+// GetVaultStats はプロジェクト内のノート、定理、証明、タグ、リンク、画像の数を数えます
+func GetVaultStats(rootDir string) (VaultStats, error) {
+	if rootDir == "" {
+		return VaultStats{}, os.ErrInvalid
+	}
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return VaultStats{}, err
+	}
+	theorems, err := loadTheoremSettings(rootDir)
+	if err != nil {
+		return VaultStats{}, err
+	}
+
+	stats := VaultStats{Notes: len(files)}
+	var tags []string
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return VaultStats{}, err
+		}
+		stats.Characters += utf8.RuneCountInString(content)
+
+		entry := buildIndexEntry(content, theorems)
+		stats.Theorems += len(entry.Theorems)
+		for _, tag := range entry.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		parseMarkdown(content, theorems).Walk(func(node *Node) bool {
+			switch node.Kind {
+			case NodeKindDetails:
+				if isProofSummary(node.Title, theorems) {
+					stats.Proofs++
+				}
+			case NodeKindWikiLink, NodeKindLink:
+				stats.Links++
+			case NodeKindEmbed, NodeKindImage:
+				stats.Images++
+			}
+			return true
+		})
+	}
+	stats.Tags = len(tags)
+	return stats, nil
+}
@@ -0,0 +1,30 @@
+package backend
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGetVaultStats(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeTestVault(t, tmpDir, map[string]string{
+		"a.md":         "#algebra\n\n<theorem name=\"A\">\n主張\n</theorem>\n\n<details>\n<summary>証明</summary>\n\n明らか\n</details>\n\n[[b]] ![[x.png]]\n",
+		"sub/b.md":     "#algebra #topology\n\n<theorem name=\"B\">\n主張\n</theorem>\n\n[link](https://example.com)\n",
+		".hidden/c.md": "無視される",
+	})
+
+	stats, err := GetVaultStats(tmpDir)
+	if err != nil {
+		t.Fatalf("GetVaultStats failed: %v", err)
+	}
+	expected := VaultStats{Notes: 2, Theorems: 2, Proofs: 1, Tags: 2, Links: 2, Images: 1}
+	expected.Characters = stats.Characters
+	if stats != expected || stats.Characters == 0 {
+		t.Errorf("Expected %+v, but got %+v", expected, stats)
+	}
+}
@@ -0,0 +1,378 @@
+package backend
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidTag = errors.New("invalid tag name")
+
+var (
+	// linkDestinationRegexp は [text](#heading) のようなリンク先です
+	linkDestinationRegexp = regexp.MustCompile(`\]\([^)\n]*\)`)
+	// wikiLinkSpanRegexp は [[#heading]] のような wiki リンクです
+	wikiLinkSpanRegexp = regexp.MustCompile(`\[\[[^\]\n]*\]\]`)
+)
+
+// TagCount はタグとそれが付けられたファイル数を保持します
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TaggedTheorem はタグ検索で見つかった定理です
+type TaggedTheorem struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// TagSearchResult はタグが付けられたファイルと定理を保持します。パスはすべて絶対パスです
+type TagSearchResult struct {
+	Files    []string        `json:"files"`
+	Theorems []TaggedTheorem `json:"theorems"`
+}
+
+type tagSpan struct {
+	Start int
+	End   int
+	Tag   string
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '_' || r == '-' || r == '/'
+}
+
+// isValidTag はタグ名として使えるかを判定します。数字だけのタグ (#1 など) は認めません
+func isValidTag(tag string) bool {
+	if tag == "" || strings.HasPrefix(tag, "/") || strings.HasSuffix(tag, "/") {
+		return false
+	}
+	hasNonDigit := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return false
+		}
+		if !unicode.IsDigit(r) {
+			hasNonDigit = true
+		}
+	}
+	return hasNonDigit
+}
+
+func normalizeTag(tag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(tag), "#")
+}
+
+// tagMatches は tag が query そのものか、その下位タグ (query/...) であるかを判定します
+func tagMatches(tag, query string) bool {
+	return tag == query || strings.HasPrefix(tag, query+"/")
+}
+
+// maskCodeAndMath はコードブロック・インラインコード・数式・エスケープ文字を空白に置き換えます。
+// 改行とバイト位置は保たれるので、結果の位置をそのまま元の文字列に使えます
+func maskCodeAndMath(content string) string {
+	b := []byte(content)
+	mask := func(from, to int) {
+		for i := from; i < to && i < len(b); i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	lineEnd := func(from int) int {
+		if i := strings.IndexByte(content[from:], '\n'); i >= 0 {
+			return from + i
+		}
+		return len(content)
+	}
+
+	i := 0
+	lineStart := true
+	for i < len(content) {
+		if lineStart {
+			lineStart = false
+			end := lineEnd(i)
+			trimmed := strings.TrimLeft(content[i:end], " ")
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				fence := trimmed[:3]
+				j := end
+				for j < len(content) {
+					next := lineEnd(j + 1)
+					if strings.HasPrefix(strings.TrimSpace(content[j+1:next]), fence) {
+						j = next
+						break
+					}
+					j = next
+				}
+				mask(i, j)
+				i = j
+				continue
+			}
+		}
+
+		switch c := content[i]; c {
+		case '\n':
+			lineStart = true
+			i++
+		case '\\':
+			mask(i, i+2)
+			i += 2
+		case '`':
+			n := 1
+			for i+n < len(content) && content[i+n] == '`' {
+				n++
+			}
+			run := strings.Repeat("`", n)
+			if j := strings.Index(content[i+n:], run); j >= 0 {
+				mask(i, i+n+j+n)
+				i += n + j + n
+			} else {
+				i += n
+			}
+		case '$':
+			if strings.HasPrefix(content[i:], "$$") {
+				if j := strings.Index(content[i+2:], "$$"); j >= 0 {
+					mask(i, i+2+j+2)
+					i += 2 + j + 2
+					continue
+				}
+				i += 2
+				continue
+			}
+			end := lineEnd(i)
+			if j := strings.IndexByte(content[i+1:end], '$'); j > 0 {
+				mask(i, i+1+j+1)
+				i += 1 + j + 1
+			} else {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return string(b)
+}
+
+// maskLinkTargets はリンク先と wiki リンクを空白に置き換えます。maskCodeAndMath と同じくバイト位置は保たれます
+func maskLinkTargets(content string) string {
+	blank := func(s string) string { return strings.Repeat(" ", len(s)) }
+	content = linkDestinationRegexp.ReplaceAllStringFunc(content, blank)
+	return wikiLinkSpanRegexp.ReplaceAllStringFunc(content, blank)
+}
+
+// findHashtags は本文中の #tag を列挙します。コードと数式の中、リンク先、見出し記号は対象外です
+func findHashtags(content string) []tagSpan {
+	masked := maskLinkTargets(maskCodeAndMath(content))
+	if _, offset, ok := splitFrontmatter(content); ok {
+		masked = strings.Repeat(" ", offset) + masked[offset:]
+	}
+
+	var spans []tagSpan
+	for i := 0; i < len(masked); i++ {
+		if masked[i] != '#' {
+			continue
+		}
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(masked[:i])
+			if !unicode.IsSpace(prev) && prev != '(' && prev != '[' && prev != ',' && prev != '、' {
+				continue
+			}
+		}
+		end := i + 1
+		for end < len(masked) {
+			r, size := utf8.DecodeRuneInString(masked[end:])
+			if !isTagRune(r) {
+				break
+			}
+			end += size
+		}
+		tag := strings.TrimRight(masked[i+1:end], "/")
+		if isValidTag(tag) {
+			spans = append(spans, tagSpan{Start: i, End: i + 1 + len(tag), Tag: tag})
+		}
+		i = end - 1
+	}
+	return spans
+}
+
+// extractTags はフロントマターの tags と本文中のハッシュタグを重複なく返します
+func extractTags(content string) []string {
+	var tags []string
+	if metadata, err := ParseNoteMetadata(content); err == nil {
+		tags = append(tags, metadata.Tags...)
+	}
+	for _, span := range findHashtags(content) {
+		tags = append(tags, span.Tag)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// ListTags はプロジェクト内のタグを、付けられたファイル数とともに返します
+func ListTags(rootDir string) ([]TagCount, error) {
+	index, err := loadNoteIndex(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range index.Files {
+		for _, tag := range entry.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, TagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(result, func(a, b TagCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return result, nil
+}
+
+// FindByTag はタグ (下位タグを含む) が付けられたファイルと定理を返します。
+// 定理ブロック内に書かれたタグはその定理にだけ付きます
+func FindByTag(rootDir string, tag string) (TagSearchResult, error) {
+	tag = normalizeTag(tag)
+	result := TagSearchResult{Files: []string{}, Theorems: []TaggedTheorem{}}
+
+	index, err := loadNoteIndex(rootDir)
+	if err != nil {
+		return result, err
+	}
+
+	for rel, entry := range index.Files {
+		path := filepath.Join(rootDir, filepath.FromSlash(rel))
+		fileMatched := slices.ContainsFunc(entry.Tags, func(t string) bool { return tagMatches(t, tag) })
+		if fileMatched {
+			result.Files = append(result.Files, path)
+		}
+		for _, theorem := range entry.Theorems {
+			if slices.ContainsFunc(theorem.Tags, func(t string) bool { return tagMatches(t, tag) }) {
+				result.Theorems = append(result.Theorems, TaggedTheorem{Name: theorem.Name, Path: path})
+			}
+		}
+	}
+
+	slices.Sort(result.Files)
+	slices.SortFunc(result.Theorems, func(a, b TaggedTheorem) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Name, b.Name))
+	})
+	return result, nil
+}
+
+// RenameTag はプロジェクト全体でタグ (下位タグを含む) の名前を変更し、取り消し可能な操作として記録します
+func RenameTag(rootDir string, oldTag string, newTag string) (Operation, error) {
+	oldTag, newTag = normalizeTag(oldTag), normalizeTag(newTag)
+	if !isValidTag(oldTag) || !isValidTag(newTag) {
+		return Operation{}, ErrInvalidTag
+	}
+
+	files, err := listMarkdownFiles(rootDir)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	var changes []FileChange
+	for _, path := range files {
+		content, err := ReadFile(path)
+		if err != nil {
+			return Operation{}, err
+		}
+		renamed := renameTagInContent(content, oldTag, newTag)
+		if renamed != content {
+			changes = append(changes, FileChange{Path: path, Before: content, After: renamed})
+		}
+	}
+
+	return applyChanges(rootDir, fmt.Sprintf("Rename tag #%s to #%s", oldTag, newTag), changes)
+}
+
+func renameTag(tag, oldTag, newTag string) string {
+	if tagMatches(tag, oldTag) {
+		return newTag + tag[len(oldTag):]
+	}
+	return tag
+}
+
+func renameTagInContent(content string, oldTag string, newTag string) string {
+	var b strings.Builder
+	last := 0
+
+	// フロントマターの tags: の値を書き換える
+	if fm, _, ok := splitFrontmatter(content); ok {
+		fmStart := strings.IndexByte(content, '\n') + 1
+		b.WriteString(content[:fmStart])
+		b.WriteString(renameFrontmatterTags(fm, oldTag, newTag))
+		last = fmStart + len(fm)
+	}
+
+	for _, span := range findHashtags(content) {
+		if !tagMatches(span.Tag, oldTag) {
+			continue
+		}
+		b.WriteString(content[last:span.Start])
+		b.WriteString("#" + renameTag(span.Tag, oldTag, newTag))
+		last = span.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+func renameFrontmatterTags(fm string, oldTag string, newTag string) string {
+	lines := strings.SplitAfter(fm, "\n")
+	inTags := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if line != "" && line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(trimmed, "- ") {
+			key, rest, found := strings.Cut(line, ":")
+			inTags = found && strings.TrimSpace(key) == "tags"
+			if !inTags {
+				continue
+			}
+			lines[i] = key + ":" + renameTagTokens(rest, oldTag, newTag)
+			continue
+		}
+		if inTags {
+			lines[i] = renameTagTokens(line, oldTag, newTag)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// renameTagTokens は YAML の値に含まれるタグ名を置き換えます。区切り文字や引用符はそのまま残します
+func renameTagTokens(s string, oldTag string, newTag string) string {
+	var b strings.Builder
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isTagRune(r) || r == '-' && (i+size >= len(s) || s[i+size] == ' ') {
+			b.WriteRune(r)
+			i += size
+			continue
+		}
+		end := i
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if !isTagRune(r) {
+				break
+			}
+			end += size
+		}
+		b.WriteString(renameTag(s[i:end], oldTag, newTag))
+		i = end
+	}
+	return b.String()
+}
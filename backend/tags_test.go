@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	content := "---\n" +
+		"title: 位相空間\n" +
+		"tags: [algebra, \"#group-theory\"]\n" +
+		"---\n" +
+		"# Heading\n" +
+		"#topology と #位相/コンパクト, (#paren)\n" +
+		"`#code` $#math$ \\#escaped #123 a#b\n" +
+		"```\n#fenced\n```\n" +
+		"$$\n#display\n$$\n" +
+		"[[file#heading]] #topology\n" +
+		"[see](#proof-of-lemma) [[#Heading]] [[#Heading|#alias]] [#bracket]\n"
+
+	expected := []string{"algebra", "bracket", "group-theory", "paren", "topology", "位相/コンパクト"}
+	if tags := extractTags(content); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("extractTags returned unexpected tags.\nGot:  %v\nWant: %v", tags, expected)
+	}
+
+	blockList := "---\ntags:\n  - analysis\n  - measure\n---\nbody"
+	if tags := extractTags(blockList); !reflect.DeepEqual(tags, []string{"analysis", "measure"}) {
+		t.Errorf("extractTags failed for block list: %v", tags)
+	}
+}
+
+func TestTagIndex(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file1 := filepath.Join(tmpDir, "compact.md")
+	file2 := filepath.Join(tmpDir, "group.md")
+	content1 := "---\ntags: [math/topology]\n---\n<theorem name=\"Heine-Borel\">\n</theorem>"
+	content2 := "#algebra\n<theorem name=\"Lagrange\">\n#exam\n</theorem>\n<theorem name=\"Cauchy\">\n</theorem>"
+	if err := WriteFile(file1, content1, tmpDir); err != nil {
+		t.Fatalf("Failed to write %s: %v", file1, err)
+	}
+	if err := WriteFile(file2, content2, tmpDir); err != nil {
+		t.Fatalf("Failed to write %s: %v", file2, err)
+	}
+
+	tags, err := ListTags(tmpDir)
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	expectedTags := []TagCount{{Tag: "algebra", Count: 1}, {Tag: "exam", Count: 1}, {Tag: "math/topology", Count: 1}}
+	if !reflect.DeepEqual(tags, expectedTags) {
+		t.Errorf("ListTags returned unexpected result.\nGot:  %v\nWant: %v", tags, expectedTags)
+	}
+
+	// Parent tags match nested tags
+	result, err := FindByTag(tmpDir, "#math")
+	if err != nil {
+		t.Fatalf("FindByTag failed: %v", err)
+	}
+	expected := TagSearchResult{
+		Files:    []string{file1},
+		Theorems: []TaggedTheorem{{Name: "Heine-Borel", Path: file1}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("FindByTag returned unexpected result.\nGot:  %v\nWant: %v", result, expected)
+	}
+
+	// Tags inside a theorem block apply to that theorem only
+	result, err = FindByTag(tmpDir, "exam")
+	if err != nil {
+		t.Fatalf("FindByTag failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Theorems, []TaggedTheorem{{Name: "Lagrange", Path: file2}}) {
+		t.Errorf("Unexpected theorems for #exam: %v", result.Theorems)
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filePath := filepath.Join(tmpDir, "note.md")
+	original := "---\ntags: [math/topology, topology]\naliases:\n  - topology\n---\n#math/topology #mathematics `#math`\n[proof](#math) [[#math]]\n"
+	if err := WriteFile(filePath, original, tmpDir); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	if _, err := RenameTag(tmpDir, "math", "数学"); err != nil {
+		t.Fatalf("RenameTag failed: %v", err)
+	}
+
+	content, _ := ReadFile(filePath)
+	expected := "---\ntags: [数学/topology, topology]\naliases:\n  - topology\n---\n#数学/topology #mathematics `#math`\n[proof](#math) [[#math]]\n"
+	if content != expected {
+		t.Errorf("RenameTag produced unexpected content.\nGot:  %q\nWant: %q", content, expected)
+	}
+
+	tags, err := ListTags(tmpDir)
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	expectedTags := []TagCount{{Tag: "mathematics", Count: 1}, {Tag: "topology", Count: 1}, {Tag: "数学/topology", Count: 1}}
+	if !reflect.DeepEqual(tags, expectedTags) {
+		t.Errorf("Index was not updated after rename.\nGot:  %v\nWant: %v", tags, expectedTags)
+	}
+
+	if _, err := RenameTag(tmpDir, "math", "bad tag"); err != ErrInvalidTag {
+		t.Errorf("Expected ErrInvalidTag, but got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ConfigPollInterval は config.json の変更を調べる間隔です
+const ConfigPollInterval = time.Second
+
+// appWrites はアプリ自身が .theorem-note のファイルに書き込み、監視がまだ読んでいない内容を書き込んだ順に持ちます。
+// 設定の保存や形式の変換による書き込みを、アプリの外での変更として通知しないために使います
+var appWrites = struct {
+	sync.Mutex
+	pending map[string][][]byte
+}{pending: make(map[string][][]byte)}
+
+// recordAppWrite はアプリが path に data を書き込むことを、書き込む前に記録します
+func recordAppWrite(path string, data []byte) {
+	appWrites.Lock()
+	defer appWrites.Unlock()
+	appWrites.pending[path] = append(appWrites.pending[path], bytes.Clone(data))
+}
+
+// consumeAppWrite は監視が読んだ path の新しい内容 data が、アプリ自身の書き込みかどうかを返します。
+// 一致した記録とそれより前の記録 (続けて書き込んだため読まれなかったもの) は消すので、
+// 後でアプリの外から同じ内容に戻された場合は変更として通知されます。
+// 一致しない場合はアプリの外で上書きされたので、残っている記録もすべて消します
+func consumeAppWrite(path string, data []byte) bool {
+	appWrites.Lock()
+	defer appWrites.Unlock()
+	pending := appWrites.pending[path]
+	i := slices.IndexFunc(pending, func(written []byte) bool { return bytes.Equal(written, data) })
+	if i < 0 || i == len(pending)-1 {
+		delete(appWrites.pending, path)
+	} else {
+		appWrites.pending[path] = pending[i+1:]
+	}
+	return i >= 0
+}
+
+// ProjectConfigWatcher はプロジェクトの config.json と macros.tex の変更を監視します。
+// git やテキストエディタによるアプリの外での変更も拾えるように、ファイルの内容を一定の間隔で読み直して比べます
+type ProjectConfigWatcher struct {
+	rootDir    string
+	path       string
+	macrosPath string
+	onChange   func(err error)
+	stop       chan struct{}
+	done       chan struct{}
+	once       sync.Once
+}
+
+// WatchProjectConfig は rootDir の config.json の監視を始めます。内容が変わるたびに設定を検証し、
+// 正しい場合は nil を、読み込めない場合や正しくない値を含む場合はその理由 (*ProjectConfigError など) を onChange に渡します。
+// 検証ではファイルの形式の変換や書き戻しをせず、アプリ自身が書き込んだ内容への変更は通知しません。
+// macros.tex が変わった場合も、マクロを読み直せるように config.json の検証結果を通知します。
+// onChange は監視用のゴルーチンから呼ばれます
+func WatchProjectConfig(rootDir string, interval time.Duration, onChange func(err error)) (*ProjectConfigWatcher, error) {
+	path, err := getProjectConfigPath(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	w := &ProjectConfigWatcher{
+		rootDir:    rootDir,
+		path:       path,
+		macrosPath: filepath.Join(filepath.Dir(path), macrosFileName),
+		onChange:   onChange,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	last, err := os.ReadFile(w.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	lastMacros, err := os.ReadFile(w.macrosPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	go w.run(last, lastMacros, interval)
+	return w, nil
+}
+
+func (w *ProjectConfigWatcher) run(last []byte, lastMacros []byte, interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(w.path)
+		if err != nil && !os.IsNotExist(err) {
+			// 書き込み中などで一時的に読めない場合は次の確認で読み直す
+			continue
+		}
+		macros, err := os.ReadFile(w.macrosPath)
+		if err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		configChanged := !bytes.Equal(data, last) && !consumeAppWrite(w.path, data)
+		macrosChanged := !bytes.Equal(macros, lastMacros)
+		last, lastMacros = data, macros
+		if !configChanged && !macrosChanged {
+			continue
+		}
+
+		// 削除された場合は既定値に戻ったものとして通知する
+		w.onChange(checkProjectConfigData(w.rootDir, data))
+	}
+}
+
+// Close は監視をやめ、実行中の onChange が終わるまで待ちます
+func (w *ProjectConfigWatcher) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchProjectConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, projectConfigDir), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	path, _ := getProjectConfigPath(tmpDir)
+
+	changes := make(chan error, 10)
+	watcher, err := WatchProjectConfig(tmpDir, 10*time.Millisecond, func(err error) { changes <- err })
+	if err != nil {
+		t.Fatalf("WatchProjectConfig failed: %v", err)
+	}
+	defer watcher.Close()
+
+	wait := func(content string) error {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		select {
+		case err := <-changes:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Change of %q was not detected", content)
+			return nil
+		}
+	}
+
+	if err := wait(`{"version":1,"editor_settings":{"tab_size":2}}`); err != nil {
+		t.Errorf("Expected a valid config, but got %v", err)
+	}
+	if config, err := LoadProjectConfig(tmpDir); err != nil || config.EditorSettings.TabSize != 2 {
+		t.Errorf("Expected the edited tab size, but got %+v (%v)", config.EditorSettings, err)
+	}
+
+	var configErr *ProjectConfigError
+	err = wait(`{"version":1,"editor_settings":{"tab_size":2}`)
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &configErr) || configErr.Path != path || !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected a ProjectConfigError for broken JSON, but got %v", err)
+	}
+
+	err = wait(`{"version":1,"editor_settings":{"tab_size":100}}`)
+	if !errors.As(err, &configErr) || !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected a ProjectConfigError for an out-of-range value, but got %v", err)
+	}
+	if _, err := LoadProjectConfig(tmpDir); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected LoadProjectConfig to report the invalid value, but got %v", err)
+	}
+
+	// マクロは設定ではないので検証の対象外
+	if err := wait(`{"version":1,"macros":{"\\R":"\\mathbb{R}"}}`); err != nil {
+		t.Errorf("Expected a valid config, but got %v", err)
+	}
+
+	err = wait(`{"version":99}`)
+	if !errors.As(err, &configErr) || !errors.Is(err, ErrUnsupportedSchemaVersion) {
+		t.Errorf("Expected a ProjectConfigError for a newer version, but got %v", err)
+	}
+
+	// 古い形式の内容は検証だけして、変換して書き戻さない
+	legacy := `{"editor_settings":{"tab_size":8}}`
+	if err := wait(legacy); err != nil {
+		t.Errorf("Expected a valid config, but got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != legacy {
+		t.Errorf("Expected the config to be left as written, but got %q", data)
+	}
+	if matches, _ := filepath.Glob(path + ".*.bak"); len(matches) > 0 {
+		t.Errorf("Expected no backup, but got %v", matches)
+	}
+
+	macrosPath := filepath.Join(tmpDir, projectConfigDir, macrosFileName)
+	if err := os.WriteFile(macrosPath, []byte(`\newcommand{\N}{\mathbb{N}}`), 0644); err != nil {
+		t.Fatalf("Failed to write macros: %v", err)
+	}
+	select {
+	case err := <-changes:
+		if err != nil {
+			t.Errorf("Expected a valid config after editing macros.tex, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change of macros.tex was not detected")
+	}
+
+	// アプリ自身の書き込みは、古い形式からの変換も含めて通知しない
+	if _, err := PatchProjectConfig(tmpDir, map[string]any{"editor_settings": map[string]any{"tab_size": 4}}); err != nil {
+		t.Fatalf("PatchProjectConfig failed: %v", err)
+	}
+	select {
+	case err := <-changes:
+		t.Errorf("Expected no change for the app's own write, but got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// アプリが書き込んだ内容でも、アプリの外で変更した後に戻した場合は通知する (git checkout など)
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if err := wait(`{"version":1,"editor_settings":{"tab_size":6}}`); err != nil {
+		t.Errorf("Expected a valid config, but got %v", err)
+	}
+	if err := wait(string(saved)); err != nil {
+		t.Errorf("Expected a valid config, but got %v", err)
+	}
+	if config, err := LoadProjectConfig(tmpDir); err != nil || config.EditorSettings.TabSize != 4 {
+		t.Errorf("Expected the reverted tab size, but got %+v (%v)", config.EditorSettings, err)
+	}
+
+	watcher.Close()
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	select {
+	case err := <-changes:
+		t.Errorf("Expected no change after Close, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
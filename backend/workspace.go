@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const workspacesFileName = "workspaces.json"
+
+var (
+	ErrWorkspaceNotFound    = errors.New("workspace not found")
+	ErrWorkspaceExists      = errors.New("workspace already exists")
+	ErrInvalidWorkspaceName = errors.New("invalid workspace name")
+)
+
+// Workspace は名前を付けて保存したタブの組とエディタの状態です
+type Workspace struct {
+	Name    string       `json:"name"`
+	Session SessionState `json:"session"`
+}
+
+// WorkspaceInfo はワークスペースの一覧の 1 項目です。Active は現在開いているワークスペースかどうかです
+type WorkspaceInfo struct {
+	Name   string `json:"name"`
+	Tabs   int    `json:"tabs"`
+	Active bool   `json:"active"`
+}
+
+// workspaceFile は workspaces.json の内容です。
+// 開いているワークスペースの最新の状態は session.json にあり、Workspaces にはほかのワークスペースに切り替えたときに書き戻します
+type workspaceFile struct {
+	Version    int         `json:"version"`
+	Active     string      `json:"active"`
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+func getWorkspacesFilePath(rootDir string) (string, error) {
+	if rootDir == "" {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(rootDir, sessionDirPath, workspacesFileName), nil
+}
+
+func loadWorkspaceFile(rootDir string) (workspaceFile, error) {
+	path, err := getWorkspacesFilePath(rootDir)
+	if err != nil {
+		return workspaceFile{}, err
+	}
+	file := workspaceFile{Version: workspacesSchema.version(), Workspaces: []Workspace{}}
+	data, err := loadSidecar(rootDir, path, workspacesSchema)
+	if err != nil {
+		return workspaceFile{}, err
+	}
+	if data == nil {
+		return file, nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return workspaceFile{}, err
+	}
+	if file.Workspaces == nil {
+		file.Workspaces = []Workspace{}
+	}
+	return file, nil
+}
+
+func saveWorkspaceFile(rootDir string, file workspaceFile) error {
+	if err := ensureSessionDirExists(rootDir); err != nil {
+		return err
+	}
+	path, err := getWorkspacesFilePath(rootDir)
+	if err != nil {
+		return err
+	}
+	file.Version = workspacesSchema.version()
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (f *workspaceFile) find(name string) int {
+	return slices.IndexFunc(f.Workspaces, func(w Workspace) bool { return w.Name == name })
+}
+
+func normalizeWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrInvalidWorkspaceName
+	}
+	return name, nil
+}
+
+// ListWorkspaces は保存したワークスペースを、保存した順に返します
+func ListWorkspaces(rootDir string) ([]WorkspaceInfo, error) {
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]WorkspaceInfo, 0, len(file.Workspaces))
+	for _, workspace := range file.Workspaces {
+		infos = append(infos, WorkspaceInfo{
+			Name:   workspace.Name,
+			Tabs:   len(workspace.Session.Tabs),
+			Active: workspace.Name == file.Active,
+		})
+	}
+	return infos, nil
+}
+
+// GetActiveWorkspace は最後に開いていたワークスペースの名前を返します。ワークスペースを使っていない場合は空文字列です。
+// その状態は session.json にあるので、LoadSessionState で復元できます
+func GetActiveWorkspace(rootDir string) (string, error) {
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return "", err
+	}
+	return file.Active, nil
+}
+
+// SaveWorkspaceAs は現在のエディタの状態を name という名前で保存し、開いているワークスペースにします。
+// 同じ名前のワークスペースがある場合は上書きします
+func SaveWorkspaceAs(rootDir string, name string) error {
+	name, err := normalizeWorkspaceName(name)
+	if err != nil {
+		return err
+	}
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return err
+	}
+	state, err := LoadSessionState(rootDir)
+	if err != nil {
+		return err
+	}
+
+	if i := file.find(name); i >= 0 {
+		file.Workspaces[i].Session = state
+	} else {
+		file.Workspaces = append(file.Workspaces, Workspace{Name: name, Session: state})
+	}
+	file.Active = name
+	return saveWorkspaceFile(rootDir, file)
+}
+
+// SwitchWorkspace は現在の状態を開いているワークスペースに書き戻してから、name のワークスペースの状態を
+// session.json に読み込みます。戻り値は復元するエディタの状態です
+func SwitchWorkspace(rootDir string, name string) (SessionState, error) {
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return SessionState{}, err
+	}
+	target := file.find(name)
+	if target < 0 {
+		return SessionState{}, ErrWorkspaceNotFound
+	}
+
+	if current := file.find(file.Active); current >= 0 && current != target {
+		state, err := LoadSessionState(rootDir)
+		if err != nil {
+			return SessionState{}, err
+		}
+		file.Workspaces[current].Session = state
+	}
+
+	state := file.Workspaces[target].Session
+	if err := SaveSessionState(rootDir, state); err != nil {
+		return SessionState{}, err
+	}
+	file.Active = name
+	if err := saveWorkspaceFile(rootDir, file); err != nil {
+		return SessionState{}, err
+	}
+	return LoadSessionState(rootDir)
+}
+
+// RenameWorkspace はワークスペースの名前を変更します
+func RenameWorkspace(rootDir string, oldName string, newName string) error {
+	newName, err := normalizeWorkspaceName(newName)
+	if err != nil {
+		return err
+	}
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return err
+	}
+	i := file.find(oldName)
+	if i < 0 {
+		return ErrWorkspaceNotFound
+	}
+	if oldName == newName {
+		return nil
+	}
+	if file.find(newName) >= 0 {
+		return ErrWorkspaceExists
+	}
+
+	file.Workspaces[i].Name = newName
+	if file.Active == oldName {
+		file.Active = newName
+	}
+	return saveWorkspaceFile(rootDir, file)
+}
+
+// DeleteWorkspace はワークスペースを削除します。開いているワークスペースを削除した場合も、
+// 現在のタブはそのまま残り、どのワークスペースにも属さない状態になります
+func DeleteWorkspace(rootDir string, name string) error {
+	file, err := loadWorkspaceFile(rootDir)
+	if err != nil {
+		return err
+	}
+	i := file.find(name)
+	if i < 0 {
+		return ErrWorkspaceNotFound
+	}
+	file.Workspaces = slices.Delete(file.Workspaces, i, i+1)
+	if file.Active == name {
+		file.Active = ""
+	}
+	return saveWorkspaceFile(rootDir, file)
+}
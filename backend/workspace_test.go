@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	lecture := SessionState{ActiveFile: "/notes/a.md", ViewMode: ViewModeEditor, Tabs: []SessionTab{{Path: "/notes/a.md"}}}
+	if err := SaveSessionState(tmpDir, lecture); err != nil {
+		t.Fatalf("SaveSessionState failed: %v", err)
+	}
+	if err := SaveWorkspaceAs(tmpDir, " 講義準備 "); err != nil {
+		t.Fatalf("SaveWorkspaceAs failed: %v", err)
+	}
+
+	paper := SessionState{ActiveFile: "/notes/p.md", ViewMode: ViewModePreview, Tabs: []SessionTab{{Path: "/notes/p.md"}, {Path: "/notes/q.md"}}}
+	if err := SaveSessionState(tmpDir, paper); err != nil {
+		t.Fatalf("SaveSessionState failed: %v", err)
+	}
+	if err := SaveWorkspaceAs(tmpDir, "論文"); err != nil {
+		t.Fatalf("SaveWorkspaceAs failed: %v", err)
+	}
+
+	// 論文のワークスペースでタブを追加してから切り替えると、追加したタブも書き戻される
+	if err := SaveSession(tmpDir, []string{"/notes/p.md", "/notes/q.md", "/notes/r.md"}); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	state, err := SwitchWorkspace(tmpDir, "講義準備")
+	if err != nil {
+		t.Fatalf("SwitchWorkspace failed: %v", err)
+	}
+	if state.ActiveFile != "/notes/a.md" || state.ViewMode != ViewModeEditor || len(state.Tabs) != 1 {
+		t.Errorf("Unexpected state after switching: %+v", state)
+	}
+	if loaded, _ := LoadSessionState(tmpDir); !reflect.DeepEqual(loaded, state) {
+		t.Errorf("Expected session.json to hold the switched workspace, but got %+v", loaded)
+	}
+
+	infos, err := ListWorkspaces(tmpDir)
+	if err != nil {
+		t.Fatalf("ListWorkspaces failed: %v", err)
+	}
+	expected := []WorkspaceInfo{{Name: "講義準備", Tabs: 1, Active: true}, {Name: "論文", Tabs: 3}}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, infos)
+	}
+
+	if err := RenameWorkspace(tmpDir, "講義準備", "論文"); err != ErrWorkspaceExists {
+		t.Errorf("Expected ErrWorkspaceExists, but got %v", err)
+	}
+	if err := RenameWorkspace(tmpDir, "講義準備", "試験対策"); err != nil {
+		t.Fatalf("RenameWorkspace failed: %v", err)
+	}
+	if active, _ := GetActiveWorkspace(tmpDir); active != "試験対策" {
+		t.Errorf("Expected the active workspace to follow the rename, but got %q", active)
+	}
+	if err := SaveWorkspaceAs(tmpDir, "  "); err != ErrInvalidWorkspaceName {
+		t.Errorf("Expected ErrInvalidWorkspaceName, but got %v", err)
+	}
+
+	if err := DeleteWorkspace(tmpDir, "試験対策"); err != nil {
+		t.Fatalf("DeleteWorkspace failed: %v", err)
+	}
+	if active, _ := GetActiveWorkspace(tmpDir); active != "" {
+		t.Errorf("Expected no active workspace after deleting it, but got %q", active)
+	}
+	if err := DeleteWorkspace(tmpDir, "試験対策"); err != ErrWorkspaceNotFound {
+		t.Errorf("Expected ErrWorkspaceNotFound, but got %v", err)
+	}
+	if _, err := SwitchWorkspace(tmpDir, "試験対策"); err != ErrWorkspaceNotFound {
+		t.Errorf("Expected ErrWorkspaceNotFound, but got %v", err)
+	}
+	state, err = SwitchWorkspace(tmpDir, "論文")
+	if err != nil || len(state.Tabs) != 3 {
+		t.Errorf("Expected the paper workspace with 3 tabs, but got %+v (%v)", state, err)
+	}
+}
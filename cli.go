@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kavos113/theorem-note-wails/backend"
+)
+
+// 終了コードは grep と同じく、0 が成功、1 が見つかった問題 (壊れたリンク、検索結果なしなど)、2 がエラーです
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitError    = 2
+)
+
+// errFindings はコマンドは成功したが、終了コード 1 で知らせるべき結果があったことを表します
+var errFindings = errors.New("findings reported")
+
+// cliCommand はウィンドウを開かずに backend の機能を使うサブコマンドです
+type cliCommand struct {
+	usage       string
+	description string
+	run         func(c *cli, flags *flag.FlagSet, args []string) error
+}
+
+var cliCommands = map[string]cliCommand{
+	"index": {
+		usage:       "index [-json] <root>",
+		description: "定理とノートのインデックスを作り直します",
+		run:         runIndexCommand,
+	},
+	"check-links": {
+		usage:       "check-links [-json] <root>",
+		description: "解決できない [[...]] リンクと ![[...]] の埋め込みを報告します",
+		run:         runCheckLinksCommand,
+	},
+	"search": {
+		usage:       "search [-json] [-regex] [-case-sensitive] <root> <query>",
+		description: "ノートの本文を検索します",
+		run:         runSearchCommand,
+	},
+	"export": {
+		usage:       "export [-json] [-strict] -format <latex|site|epub|catalog|flashcards|vault> -o <path> [options] <root> [notes...]",
+		description: "ノートを書き出します。notes はルートからの相対パスです",
+		run:         runExportCommand,
+	},
+	"stats": {
+		usage:       "stats [-json] <root>",
+		description: "ノート、定理、リンクなどの数を表示します",
+		run:         runStatsCommand,
+	},
+}
+
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	styles fs.FS
+	json   bool
+}
+
+// runCLI は args[0] がサブコマンドの場合に実行して終了コードを返します。
+// 引数がない場合と、OS が付けるフラグ (- で始まる引数) だけの場合は ok が false で、呼び出し側は GUI を起動します。
+// 知らないサブコマンドは使い方を表示して終了コード 2 を返します
+func runCLI(args []string, stdout io.Writer, stderr io.Writer, styles fs.FS) (code int, ok bool) {
+	if len(args) == 0 {
+		return exitOK, false
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCLIUsage(stdout)
+		return exitOK, true
+	}
+	// macOS の -psn_... のように、OS が GUI アプリに付けるフラグ
+	if strings.HasPrefix(args[0], "-") {
+		return exitOK, false
+	}
+	command, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Error: unknown command %q\n\n", args[0])
+		printCLIUsage(stderr)
+		return exitError, true
+	}
+
+	c := &cli{stdout: stdout, stderr: stderr, styles: styles}
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&c.json, "json", false, "結果を JSON で出力します")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: theorem-note-wails %s\n", command.usage)
+		flags.PrintDefaults()
+	}
+
+	err := command.run(c, flags, args[1:])
+	switch {
+	case err == nil:
+		return exitOK, true
+	case errors.Is(err, errFindings):
+		return exitFindings, true
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK, true
+	}
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	return exitError, true
+}
+
+func printCLIUsage(w io.Writer) {
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(w, "usage: theorem-note-wails <command> [arguments]")
+	fmt.Fprintln(w, "引数なしで起動するとエディタを開きます。")
+	fmt.Fprintln(w)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n      %s\n", cliCommands[name].usage, cliCommands[name].description)
+	}
+}
+
+// parse はフラグを読み、残りの位置引数が min 個以上 max 個以下であることを確かめます。max が負の場合は上限なしです
+func (c *cli) parse(flags *flag.FlagSet, args []string, min int, max int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	rest := flags.Args()
+	if len(rest) < min || (max >= 0 && len(rest) > max) {
+		flags.Usage()
+		return nil, fmt.Errorf("expected %d argument(s), but got %d", min, len(rest))
+	}
+	return rest, nil
+}
+
+// writeJSON は -json が指定された場合に v を標準出力に書き、true を返します
+func (c *cli) writeJSON(v any) (bool, error) {
+	if !c.json {
+		return false, nil
+	}
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return true, enc.Encode(v)
+}
+
+func rootArg(arg string) (string, error) {
+	return filepath.Abs(arg)
+}
+
+func runIndexCommand(c *cli, flags *flag.FlagSet, args []string) error {
+	rest, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rootDir, err := rootArg(rest[0])
+	if err != nil {
+		return err
+	}
+	if err := backend.RebuildIndex(rootDir); err != nil {
+		return err
+	}
+	stats, err := backend.GetVaultStats(rootDir)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Notes    int `json:"notes"`
+		Theorems int `json:"theorems"`
+	}{stats.Notes, stats.Theorems}
+	if ok, err := c.writeJSON(result); ok {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "indexed %d notes, %d theorems\n", result.Notes, result.Theorems)
+	return nil
+}
+
+func runCheckLinksCommand(c *cli, flags *flag.FlagSet, args []string) error {
+	rest, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rootDir, err := rootArg(rest[0])
+	if err != nil {
+		return err
+	}
+	broken, err := backend.CheckLinks(rootDir)
+	if err != nil {
+		return err
+	}
+	if ok, err := c.writeJSON(broken); !ok {
+		for _, link := range broken {
+			fmt.Fprintf(c.stdout, "%s:%d: [[%s]]: %s\n", link.Path, link.Line, link.Target, link.Reason)
+		}
+	} else if err != nil {
+		return err
+	}
+	if len(broken) > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+func runSearchCommand(c *cli, flags *flag.FlagSet, args []string) error {
+	var opts backend.ReplaceOptions
+	flags.BoolVar(&opts.UseRegex, "regex", false, "query を正規表現として扱います")
+	flags.BoolVar(&opts.CaseSensitive, "case-sensitive", false, "大文字と小文字を区別します")
+	rest, err := c.parse(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	rootDir, err := rootArg(rest[0])
+	if err != nil {
+		return err
+	}
+	opts.Query = rest[1]
+
+	previews, err := backend.PreviewReplace(rootDir, opts)
+	if err != nil {
+		return err
+	}
+	if ok, err := c.writeJSON(previews); !ok {
+		for _, preview := range previews {
+			rel, _ := filepath.Rel(rootDir, preview.Path)
+			for _, match := range preview.Matches {
+				fmt.Fprintf(c.stdout, "%s:%d:%d: %s\n", filepath.ToSlash(rel), match.Line, match.Column, match.LineText)
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	if len(previews) == 0 {
+		return errFindings
+	}
+	return nil
+}
+
+func runExportCommand(c *cli, flags *flag.FlagSet, args []string) error {
+	format := flags.String("format", "", "latex, site, epub, catalog, flashcards, vault のいずれか")
+	output := flags.String("o", "", "出力先 (latex と site はディレクトリ、それ以外はファイル)")
+	strict := flags.Bool("strict", false, "警告がある場合に終了コード 1 で終了します")
+	title := flags.String("title", "", "site と epub のタイトル")
+	author := flags.String("author", "", "epub の著者")
+	language := flags.String("lang", "", "epub の言語")
+	groupBy := flags.String("group-by", backend.CatalogGroupByFile, "catalog のまとめ方 (file, tag)")
+	fileFormat := flags.String("file-format", "", "catalog (markdown, csv, json) と flashcards (tsv, csv) の形式")
+	rest, err := c.parse(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	rootDir, err := rootArg(rest[0])
+	if err != nil {
+		return err
+	}
+	paths := rest[1:]
+	if *output == "" {
+		flags.Usage()
+		return errors.New("-o is required")
+	}
+	if (*format == "catalog" || *format == "flashcards" || *format == "vault") && len(paths) > 0 {
+		return fmt.Errorf("%s export does not take note paths", *format)
+	}
+
+	var result backend.ExportResult
+	switch *format {
+	case "latex":
+		result, err = backend.ExportLaTeX(rootDir, backend.LaTeXExportOptions{Paths: paths, OutputDir: *output})
+	case "site":
+		result, err = backend.ExportSite(rootDir, backend.SiteExportOptions{Paths: paths, OutputDir: *output, Title: *title}, c.styles)
+	case "epub":
+		result, err = backend.ExportEPUB(rootDir, backend.EPUBExportOptions{
+			Paths:      paths,
+			OutputPath: *output,
+			Title:      *title,
+			Author:     *author,
+			Language:   *language,
+		})
+	case "catalog":
+		result, err = backend.ExportTheoremCatalog(rootDir, backend.CatalogOptions{GroupBy: *groupBy, Format: *fileFormat, OutputPath: *output})
+	case "flashcards":
+		result, err = backend.ExportFlashcards(rootDir, backend.FlashcardOptions{Format: *fileFormat, OutputPath: *output})
+	case "vault":
+		result, err = backend.ExportVault(rootDir, *output)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ok, err := c.writeJSON(result); !ok {
+		for _, file := range result.Files {
+			fmt.Fprintln(c.stdout, file)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintln(c.stderr, "warning: "+warning)
+		}
+	} else if err != nil {
+		return err
+	}
+	if *strict && len(result.Warnings) > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+func runStatsCommand(c *cli, flags *flag.FlagSet, args []string) error {
+	rest, err := c.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rootDir, err := rootArg(rest[0])
+	if err != nil {
+		return err
+	}
+	stats, err := backend.GetVaultStats(rootDir)
+	if err != nil {
+		return err
+	}
+	if ok, err := c.writeJSON(stats); ok {
+		return err
+	}
+	rows := [][2]any{
+		{"notes", stats.Notes},
+		{"characters", stats.Characters},
+		{"theorems", stats.Theorems},
+		{"proofs", stats.Proofs},
+		{"tags", stats.Tags},
+		{"links", stats.Links},
+		{"images", stats.Images},
+	}
+	var b strings.Builder
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%-12s%d\n", row[0], row[1])
+	}
+	_, err = io.WriteString(c.stdout, b.String())
+	return err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kavos113/theorem-note-wails/backend"
+)
+
+// TestMain はコマンドの出力が開発者のグローバル設定で変わらないように、ユーザーの設定ディレクトリを一時ディレクトリにします
+func TestMain(m *testing.M) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create temp dir: %v\n", err)
+		os.Exit(1)
+	}
+	// os.UserConfigDir は Linux では XDG_CONFIG_HOME、macOS では HOME、Windows では AppData を使う
+	for _, key := range []string{"XDG_CONFIG_HOME", "HOME", "AppData"} {
+		os.Setenv(key, tmpDir)
+	}
+	code := m.Run()
+	os.RemoveAll(tmpDir)
+	os.Exit(code)
+}
+
+// copyTestVault は testdata/vault を tmpDir にコピーします。コマンドがインデックスを書き込むので、testdata を直接使いません
+func copyTestVault(t *testing.T, tmpDir string) string {
+	t.Helper()
+	rootDir := filepath.Join(tmpDir, "vault")
+	if err := os.CopyFS(rootDir, os.DirFS(filepath.Join("testdata", "vault"))); err != nil {
+		t.Fatalf("Failed to copy test vault: %v", err)
+	}
+	return rootDir
+}
+
+func runTestCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code, ok := runCLI(args, &stdout, &stderr, os.DirFS(filepath.Join("frontend", "src", "assets", "styles")))
+	if !ok {
+		t.Fatalf("Expected %q to be handled as a command", args)
+	}
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunCLI_GUI(t *testing.T) {
+	for _, args := range [][]string{nil, {"-psn_0_12345"}} {
+		var stdout, stderr bytes.Buffer
+		if _, ok := runCLI(args, &stdout, &stderr, nil); ok {
+			t.Errorf("Expected %q to start the GUI", args)
+		}
+		if stdout.Len() > 0 || stderr.Len() > 0 {
+			t.Errorf("Expected no output for %q, but got %q %q", args, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestRunCLI_Usage(t *testing.T) {
+	code, stdout, _ := runTestCLI(t, "help")
+	if code != exitOK || !strings.Contains(stdout, "check-links [-json] <root>") {
+		t.Errorf("Expected usage with exit code 0, but got %d:\n%s", code, stdout)
+	}
+
+	code, stdout, stderr := runTestCLI(t, "frobnicate", "notes")
+	if code != exitError {
+		t.Errorf("Expected exit code %d for an unknown command, but got %d", exitError, code)
+	}
+	if stdout != "" || !strings.Contains(stderr, `unknown command "frobnicate"`) || !strings.Contains(stderr, "usage: theorem-note-wails <command>") {
+		t.Errorf("Expected the error and usage on stderr, but got %q %q", stdout, stderr)
+	}
+}
+
+func TestRunCLI_ExitCodes(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	rootDir := copyTestVault(t, tmpDir)
+	outDir := filepath.Join(tmpDir, "out")
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"index", rootDir}, exitOK},
+		{[]string{"check-links", rootDir}, exitOK},
+		{[]string{"search", rootDir, "コンパクト"}, exitOK},
+		{[]string{"search", rootDir, "存在しない語"}, exitFindings},
+		{[]string{"stats", rootDir}, exitOK},
+		{[]string{"export", "-format", "catalog", "-o", filepath.Join(outDir, "catalog.md"), rootDir}, exitOK},
+		{[]string{"export", "-format", "latex", "-o", filepath.Join(outDir, "latex"), rootDir, "topology.md"}, exitOK},
+		{[]string{"stats"}, exitError},
+		{[]string{"search", "-regex", rootDir, "("}, exitError},
+		{[]string{"export", "-format", "pdf", "-o", outDir, rootDir}, exitError},
+		{[]string{"export", "-format", "latex", rootDir}, exitError},
+		{[]string{"index", "-unknown-flag", rootDir}, exitError},
+	}
+	for _, tt := range tests {
+		code, _, stderr := runTestCLI(t, tt.args...)
+		if code != tt.code {
+			t.Errorf("%q: expected exit code %d, but got %d (stderr: %q)", tt.args, tt.code, code, stderr)
+		}
+	}
+}
+
+func TestRunCLI_JSON(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	rootDir := copyTestVault(t, tmpDir)
+
+	decode := func(output string, v any) {
+		t.Helper()
+		if err := json.Unmarshal([]byte(output), v); err != nil {
+			t.Fatalf("Failed to decode %q: %v", output, err)
+		}
+	}
+
+	code, stdout, _ := runTestCLI(t, "index", "-json", rootDir)
+	var indexed struct {
+		Notes    int `json:"notes"`
+		Theorems int `json:"theorems"`
+	}
+	decode(stdout, &indexed)
+	if code != exitOK || indexed.Notes != 2 || indexed.Theorems != 2 {
+		t.Errorf("Expected 2 notes and 2 theorems, but got %+v (exit code %d)", indexed, code)
+	}
+
+	code, stdout, _ = runTestCLI(t, "stats", "-json", rootDir)
+	var stats backend.VaultStats
+	decode(stdout, &stats)
+	expectedStats := backend.VaultStats{Notes: 2, Characters: stats.Characters, Theorems: 2, Proofs: 1, Tags: 2, Links: 1}
+	if code != exitOK || !reflect.DeepEqual(stats, expectedStats) {
+		t.Errorf("Expected %+v, but got %+v (exit code %d)", expectedStats, stats, code)
+	}
+
+	code, stdout, _ = runTestCLI(t, "search", "-json", rootDir, "コンパクト")
+	var previews []backend.ReplacePreview
+	decode(stdout, &previews)
+	if code != exitOK || len(previews) != 1 || previews[0].Path != filepath.Join(rootDir, "topology.md") || previews[0].Matches[0].Line != 7 {
+		t.Errorf("Expected one match in topology.md, but got %+v (exit code %d)", previews, code)
+	}
+
+	code, stdout, _ = runTestCLI(t, "search", "-json", rootDir, "存在しない語")
+	decode(stdout, &previews)
+	if code != exitFindings || len(previews) != 0 {
+		t.Errorf("Expected no matches with exit code %d, but got %+v (exit code %d)", exitFindings, previews, code)
+	}
+
+	code, stdout, _ = runTestCLI(t, "check-links", "-json", rootDir)
+	var broken []backend.BrokenLink
+	decode(stdout, &broken)
+	if code != exitOK || len(broken) != 0 {
+		t.Errorf("Expected no broken links, but got %+v (exit code %d)", broken, code)
+	}
+
+	brokenNote := "[[missing]]\n\n[[topology#存在しない見出し]]\n"
+	if err := os.WriteFile(filepath.Join(rootDir, "broken.md"), []byte(brokenNote), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+	code, stdout, _ = runTestCLI(t, "check-links", "-json", rootDir)
+	decode(stdout, &broken)
+	expectedBroken := []backend.BrokenLink{
+		{Path: "broken.md", Line: 1, Target: "missing", Reason: backend.BrokenLinkNoteNotFound},
+		{Path: "broken.md", Line: 3, Target: "topology#存在しない見出し", Reason: backend.BrokenLinkHeadingNotFound},
+	}
+	if code != exitFindings || !reflect.DeepEqual(broken, expectedBroken) {
+		t.Errorf("Expected %+v with exit code %d, but got %+v (exit code %d)", expectedBroken, exitFindings, broken, code)
+	}
+}
+
+func TestRunCLI_CheckLinksIsReadOnly(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "testdir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	rootDir := copyTestVault(t, tmpDir)
+
+	// エイリアスへのリンクも、インデックスを作らずに解決する
+	note := "---\naliases: [位相]\n---\n# 空間\n"
+	if err := os.WriteFile(filepath.Join(rootDir, "alias.md"), []byte("[[位相#空間]]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "space.md"), []byte(note), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+	code, stdout, stderr := runTestCLI(t, "check-links", rootDir)
+	if code != exitOK {
+		t.Errorf("Expected no broken links, but got %q %q (exit code %d)", stdout, stderr, code)
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, ".theorem-note")); !os.IsNotExist(err) {
+		t.Errorf("Expected check-links not to write to the vault, but got %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-import { EditorState } from '@codemirror/state';
+import { Compartment, EditorState, type Extension } from '@codemirror/state';
 import { EditorView } from '@codemirror/view';
 import { basicSetup } from 'codemirror';
 import { markdown, markdownLanguage } from '@codemirror/lang-markdown';
@@ -6,6 +6,7 @@ import { languages } from '@codemirror/language-data';
 import { oneDark } from '@codemirror/theme-one-dark';
 import { autocompletion, CompletionContext, CompletionResult } from '@codemirror/autocomplete';
 import { LoadTheorems } from '../../wailsjs/go/main/App';
+import type { backend } from '../../wailsjs/go/models';
 
 export interface CodeMirrorInstance {
   view: EditorView;
@@ -13,8 +14,47 @@ export interface CodeMirrorInstance {
   getContent: () => string;
   destroy: () => void;
   setEditorStyle: (style: Record<string, string>) => void;
+  // カーソルの位置 (行と列は 1 始まり)
+  getCursor: () => { line: number; column: number };
+  setCursor: (line: number, column: number) => void;
+  // テーマ・タブの幅・折り返し・行番号・スペルチェックを設定に合わせる
+  applyEditorSettings: (settings: backend.EditorSettings) => void;
 }
 
+// theme が system のときは OS のダークモードに合わせる
+const isDarkEditorTheme = (theme: string): boolean => {
+  if (theme === 'system') {
+    return window.matchMedia('(prefers-color-scheme: dark)').matches;
+  }
+  return theme === 'dark';
+};
+
+// basicSetup は行番号のガターを必ず含むので、非表示にするときはガターごと隠す
+const hiddenGutters = EditorView.theme({
+  '.cm-gutters': {
+    display: 'none'
+  }
+});
+
+const editorSettingsExtensions = (settings: backend.EditorSettings) => ({
+  theme: isDarkEditorTheme(settings.theme) ? oneDark : [],
+  tabSize: EditorState.tabSize.of(settings.tab_size),
+  lineWrapping: settings.line_wrapping ? EditorView.lineWrapping : [],
+  lineNumbers: settings.line_numbers ? [] : hiddenGutters,
+  spellcheck: EditorView.contentAttributes.of(
+    settings.spellcheck_language
+      ? { spellcheck: 'true', lang: settings.spellcheck_language }
+      : { spellcheck: 'false' }
+  )
+});
+
+// 補完に出すプロジェクトの数式のマクロ。補完のたびに読み込まず、設定やマクロが変わったときに setCompletionMacros で更新する
+let completionMacros: backend.MathMacro[] = [];
+
+export const setCompletionMacros = (macros: backend.MathMacro[]): void => {
+  completionMacros = macros;
+};
+
 const theoremAutocompletion =
   (rootDir: string) =>
   async (context: CompletionContext): Promise<CompletionResult | null> => {
@@ -375,7 +415,15 @@ const katexAutocompletion = () => {
     }
     const word = match.text.slice(1); // Remove the leading '\\'
 
-    const filteredOptions = latexCommands.filter((cmd) =>
+    // プロジェクトのマクロは引数の数だけ {} を付けて補完する
+    const macroOptions = completionMacros.map((macro) => ({
+      label: macro.name,
+      apply: macro.name.slice(1) + '{}'.repeat(macro.args),
+      detail: macro.body,
+      type: 'function'
+    }));
+
+    const filteredOptions = [...macroOptions, ...latexCommands].filter((cmd) =>
       cmd.label.toLowerCase().startsWith(`\\${word.toLowerCase()}`)
     );
 
@@ -391,9 +439,20 @@ export const createCodeMirrorEditor = (
   container: HTMLElement,
   initialContent: string,
   onChange: (content: string) => void,
-  isDarkTheme = false,
-  rootDir: string
+  editorSettings: backend.EditorSettings,
+  rootDir: string,
+  onCursorChange?: () => void
 ): CodeMirrorInstance => {
+  // 設定が変わったときに作り直さず差し替えられるように、設定ごとに Compartment に入れる
+  const compartments = {
+    theme: new Compartment(),
+    tabSize: new Compartment(),
+    lineWrapping: new Compartment(),
+    lineNumbers: new Compartment(),
+    spellcheck: new Compartment()
+  };
+  const settingsExtensions = editorSettingsExtensions(editorSettings);
+
   const editorTheme = EditorView.theme({
     '&': {
       height: '100%',
@@ -524,7 +583,7 @@ export const createCodeMirrorEditor = (
     }
   });
 
-  const extensions = [
+  const extensions: Extension[] = [
     basicSetup,
     markdown({
       base: markdownLanguage,
@@ -534,17 +593,21 @@ export const createCodeMirrorEditor = (
       if (update.docChanged) {
         onChange(update.state.doc.toString());
       }
+      if (update.selectionSet && onCursorChange) {
+        onCursorChange();
+      }
     }),
     editorTheme,
     autocompletion({
       override: [theoremAutocompletion(rootDir), katexAutocompletion()]
-    })
+    }),
+    compartments.theme.of(settingsExtensions.theme),
+    compartments.tabSize.of(settingsExtensions.tabSize),
+    compartments.lineWrapping.of(settingsExtensions.lineWrapping),
+    compartments.lineNumbers.of(settingsExtensions.lineNumbers),
+    compartments.spellcheck.of(settingsExtensions.spellcheck)
   ];
 
-  if (isDarkTheme) {
-    extensions.push(oneDark);
-  }
-
   const state = EditorState.create({
     doc: initialContent,
     extensions
@@ -585,6 +648,30 @@ export const createCodeMirrorEditor = (
           cmElement.style.fontSize = style.fontSize;
         }
       }
+    },
+    getCursor: () => {
+      const head = view.state.selection.main.head;
+      const line = view.state.doc.lineAt(head);
+      return { line: line.number, column: head - line.from + 1 };
+    },
+    setCursor: (line: number, column: number) => {
+      // 前回からファイルが短くなっている場合は、範囲内の最も近い位置に合わせる
+      const doc = view.state.doc;
+      const target = doc.line(Math.min(Math.max(line, 1), doc.lines));
+      const anchor = target.from + Math.min(Math.max(column, 1) - 1, target.length);
+      view.dispatch({ selection: { anchor } });
+    },
+    applyEditorSettings: (settings: backend.EditorSettings) => {
+      const next = editorSettingsExtensions(settings);
+      view.dispatch({
+        effects: [
+          compartments.theme.reconfigure(next.theme),
+          compartments.tabSize.reconfigure(next.tabSize),
+          compartments.lineWrapping.reconfigure(next.lineWrapping),
+          compartments.lineNumbers.reconfigure(next.lineNumbers),
+          compartments.spellcheck.reconfigure(next.spellcheck)
+        ]
+      });
     }
   };
 };
@@ -13,8 +13,15 @@ import mermaid from 'mermaid';
 import type { Element, Root, ElementContent } from 'hast';
 import type { Root as RemarkRoot, Text } from 'mdast';
 import rehypeRaw from 'rehype-raw';
+import type { backend } from '../../wailsjs/go/models';
 
 let projectRoot = '';
+// KaTeX の macros オプションに渡す、プロジェクトのマクロ
+let mathMacros: Record<string, string> = {};
+// プロジェクトの定理環境。<theorem> 以外のタグも定理として表示する
+let theoremEnvironments: backend.TheoremEnvironment[] = [
+  { tag: 'theorem', label: '定理', counter: 'theorem', template: '' }
+];
 
 const IMAGE_PREFIX = '/_images/';
 
@@ -30,6 +37,14 @@ export const getProjectRoot = (): string => {
   return projectRoot;
 };
 
+export const setMathMacros = (macros: backend.MathMacro[]): void => {
+  mathMacros = Object.fromEntries(macros.map((macro) => [macro.name, macro.body]));
+};
+
+export const setTheoremSettings = (settings: backend.TheoremSettings): void => {
+  theoremEnvironments = settings.environments;
+};
+
 const rehypeMermaid = () => {
   return (tree: Root) => {
     visit(tree, 'element', (node: Element, index?: number, parent?: Root | Element) => {
@@ -243,7 +258,8 @@ const remarkInternalLinks = () => {
 const rehypeTheoremMarkdown = () => {
   return (tree: Root) => {
     visit(tree, 'element', (node: Element, index?: number, parent?: Root | Element) => {
-      if (node.tagName === 'theorem' && parent?.children && index !== undefined) {
+      const environment = theoremEnvironments.find((env) => env.tag === node.tagName);
+      if (environment && parent?.children && index !== undefined) {
         // theorem要素のname属性を取得
         const nameAttr = node.properties?.name as string;
 
@@ -277,14 +293,16 @@ const rehypeTheoremMarkdown = () => {
           type: 'element',
           tagName: 'div',
           properties: {
-            className: ['theorem']
+            className: ['theorem'],
+            dataEnvironment: environment.tag
           },
           children: [
             {
               type: 'element',
               tagName: 'h4',
-              properties: { className: ['theorem-title'] },
-              children: [{ type: 'text', value: nameAttr || 'Theorem' }]
+              // 名前のない定理の見出しは、slug を付けた後に番号付きの表示名だけになる
+              properties: { className: ['theorem-title'], dataUntitled: !nameAttr },
+              children: [{ type: 'text', value: nameAttr || environment.label }]
             },
             {
               type: 'element',
@@ -299,6 +317,37 @@ const rehypeTheoremMarkdown = () => {
   };
 };
 
+// 定理の見出しの先頭に「定理 1」のような番号付きの表示名を加える。
+// rehype-slug の後に加えるので、見出しの id には影響しない
+const rehypeTheoremLabel = () => {
+  return (tree: Root) => {
+    const counters = new Map<string, number>();
+    visit(tree, 'element', (node: Element) => {
+      const environment = theoremEnvironments.find(
+        (env) => env.tag === node.properties?.dataEnvironment
+      );
+      const title = node.children[0];
+      if (!environment || title?.type !== 'element' || title.tagName !== 'h4') return;
+
+      // counter が同じ環境は通し番号を共有し、空の環境には番号を付けない
+      let label = environment.label;
+      if (environment.counter) {
+        const count = (counters.get(environment.counter) ?? 0) + 1;
+        counters.set(environment.counter, count);
+        label += ` ${count}`;
+      }
+      const labelNode: Element = {
+        type: 'element',
+        tagName: 'span',
+        properties: { className: ['theorem-label'] },
+        children: [{ type: 'text', value: label }]
+      };
+      title.children = title.properties?.dataUntitled ? [labelNode] : [labelNode, ...title.children];
+      delete title.properties.dataUntitled;
+    });
+  };
+};
+
 export const markdownToHtml = async (markdown: string): Promise<string> => {
   const parsed = await unified()
     .use(remarkParse)
@@ -310,7 +359,9 @@ export const markdownToHtml = async (markdown: string): Promise<string> => {
     .use(rehypeRaw)
     .use(rehypeTheoremMarkdown)
     .use(rehypeSlug)
-    .use(rehypeKatex)
+    .use(rehypeTheoremLabel)
+    // KaTeX は \gdef などで macros を書き換えるので、毎回コピーを渡す
+    .use(rehypeKatex, { macros: { ...mathMacros } })
     .use(rehypeHighlight)
     .use(rehypeMermaid)
     .use(rehypeCardLink)
@@ -2,30 +2,156 @@
 // This file is automatically generated. DO NOT EDIT
 import { backend } from '../models';
 
+export function ApplyReplace(
+  arg1: string,
+  arg2: backend.ReplaceOptions,
+  arg3: Array<string>
+): Promise<backend.Operation>;
+
+export function CheckCitations(arg1: string): Promise<Array<backend.BibIssue>>;
+
 export function CreateDirectory(arg1: string): Promise<void>;
 
-export function CreateFile(arg1: string): Promise<void>;
+export function CreateFile(arg1: string, arg2: string): Promise<void>;
+
+export function DeleteWorkspace(arg1: string, arg2: string): Promise<void>;
+
+export function ExportEPUB(
+  arg1: string,
+  arg2: backend.EPUBExportOptions
+): Promise<backend.ExportResult>;
+
+export function ExportFlashcards(
+  arg1: string,
+  arg2: backend.FlashcardOptions
+): Promise<backend.ExportResult>;
+
+export function ExportLaTeX(
+  arg1: string,
+  arg2: backend.LaTeXExportOptions
+): Promise<backend.ExportResult>;
+
+export function ExportSite(
+  arg1: string,
+  arg2: backend.SiteExportOptions
+): Promise<backend.ExportResult>;
+
+export function ExportTheoremCatalog(
+  arg1: string,
+  arg2: backend.CatalogOptions
+): Promise<backend.ExportResult>;
+
+export function ExportVault(arg1: string, arg2: string): Promise<backend.ExportResult>;
+
+export function FindByTag(arg1: string, arg2: string): Promise<backend.TagSearchResult>;
+
+export function GetActiveWorkspace(arg1: string): Promise<string>;
+
+export function GetCitationCompletions(arg1: string): Promise<Array<backend.CitationCompletion>>;
+
+export function GetDocumentOutline(arg1: string, arg2: string): Promise<Array<backend.OutlineItem>>;
+
+export function GetEffectiveSettings(
+  arg1: string,
+  arg2: string
+): Promise<backend.EffectiveSettings>;
 
 export function GetFileTree(arg1: string): Promise<Array<backend.FileItem>>;
 
 export function GetFontSettings(arg1: string): Promise<backend.FontSettings>;
 
+export function GetHistory(arg1: string): Promise<Array<backend.Operation>>;
+
 export function GetLastOpened(): Promise<string>;
 
+export function GetMathMacros(arg1: string): Promise<backend.MathMacros>;
+
 export function GetNewDirectoryFileTree(): Promise<Array<backend.FileItem>>;
 
+export function GetNoteBibliography(arg1: string, arg2: string): Promise<backend.NoteBibliography>;
+
+export function GetRecentProjects(): Promise<Array<backend.RecentProject>>;
+
+export function GetSettings(arg1: string): Promise<backend.ProjectConfig>;
+
+export function GetTheoremCatalog(arg1: string, arg2: string): Promise<Array<backend.CatalogGroup>>;
+
 export function Greet(arg1: string): Promise<string>;
 
+export function ImportObsidianVault(
+  arg1: string,
+  arg2: string
+): Promise<backend.ObsidianImportReport>;
+
+export function ImportVault(
+  arg1: string,
+  arg2: string,
+  arg3: backend.ImportOptions
+): Promise<backend.ImportResult>;
+
+export function ListTags(arg1: string): Promise<Array<backend.TagCount>>;
+
+export function ListWorkspaces(arg1: string): Promise<Array<backend.WorkspaceInfo>>;
+
 export function LoadSession(arg1: string): Promise<Array<string>>;
 
+export function LoadSessionState(arg1: string): Promise<backend.SessionState>;
+
 export function LoadTheorems(arg1: string): Promise<Record<string, string>>;
 
-export function ReadFile(arg1: string): Promise<string>;
+export function OpenRecentProject(arg1: string): Promise<Array<backend.FileItem>>;
+
+export function PatchDefaultSettings(
+  arg1: string,
+  arg2: Record<string, any>
+): Promise<backend.ProjectConfig>;
+
+export function PatchSettings(
+  arg1: string,
+  arg2: Record<string, any>
+): Promise<backend.ProjectConfig>;
+
+export function PinRecentProject(arg1: string, arg2: boolean): Promise<void>;
+
+export function PreviewReplace(
+  arg1: string,
+  arg2: backend.ReplaceOptions
+): Promise<Array<backend.ReplacePreview>>;
+
+export function QuickOpen(
+  arg1: string,
+  arg2: string,
+  arg3: number
+): Promise<Array<backend.QuickOpenItem>>;
+
+export function ReadFile(arg1: string): Promise<backend.NoteFile>;
+
+export function RebuildIndex(arg1: string): Promise<void>;
+
+export function RemoveRecentProject(arg1: string): Promise<void>;
+
+export function RenameRecentProject(arg1: string, arg2: string): Promise<void>;
+
+export function RenameTag(arg1: string, arg2: string, arg3: string): Promise<backend.Operation>;
+
+export function RenameWorkspace(arg1: string, arg2: string, arg3: string): Promise<void>;
+
+export function ResolveLink(arg1: string, arg2: string): Promise<backend.ResolvedLink>;
 
 export function SaveFontSettings(arg1: string, arg2: backend.FontSettings): Promise<void>;
 
 export function SaveSession(arg1: string, arg2: Array<string>): Promise<void>;
 
+export function SaveSessionState(arg1: string, arg2: backend.SessionState): Promise<void>;
+
+export function SaveWorkspaceAs(arg1: string, arg2: string): Promise<void>;
+
 export function SetLastOpened(arg1: string): Promise<void>;
 
+export function SwitchWorkspace(arg1: string, arg2: string): Promise<backend.SessionState>;
+
+export function UndoLastOperation(arg1: string): Promise<backend.Operation>;
+
+export function WatchProjectConfig(arg1: string): Promise<void>;
+
 export function WriteFile(arg1: string, arg2: string, arg3: string): Promise<void>;
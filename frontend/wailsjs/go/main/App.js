@@ -2,12 +2,68 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ApplyReplace(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ApplyReplace'](arg1, arg2, arg3);
+}
+
+export function CheckCitations(arg1) {
+  return window['go']['main']['App']['CheckCitations'](arg1);
+}
+
 export function CreateDirectory(arg1) {
   return window['go']['main']['App']['CreateDirectory'](arg1);
 }
 
-export function CreateFile(arg1) {
-  return window['go']['main']['App']['CreateFile'](arg1);
+export function CreateFile(arg1, arg2) {
+  return window['go']['main']['App']['CreateFile'](arg1, arg2);
+}
+
+export function DeleteWorkspace(arg1, arg2) {
+  return window['go']['main']['App']['DeleteWorkspace'](arg1, arg2);
+}
+
+export function ExportEPUB(arg1, arg2) {
+  return window['go']['main']['App']['ExportEPUB'](arg1, arg2);
+}
+
+export function ExportFlashcards(arg1, arg2) {
+  return window['go']['main']['App']['ExportFlashcards'](arg1, arg2);
+}
+
+export function ExportLaTeX(arg1, arg2) {
+  return window['go']['main']['App']['ExportLaTeX'](arg1, arg2);
+}
+
+export function ExportSite(arg1, arg2) {
+  return window['go']['main']['App']['ExportSite'](arg1, arg2);
+}
+
+export function ExportTheoremCatalog(arg1, arg2) {
+  return window['go']['main']['App']['ExportTheoremCatalog'](arg1, arg2);
+}
+
+export function ExportVault(arg1, arg2) {
+  return window['go']['main']['App']['ExportVault'](arg1, arg2);
+}
+
+export function FindByTag(arg1, arg2) {
+  return window['go']['main']['App']['FindByTag'](arg1, arg2);
+}
+
+export function GetActiveWorkspace(arg1) {
+  return window['go']['main']['App']['GetActiveWorkspace'](arg1);
+}
+
+export function GetCitationCompletions(arg1) {
+  return window['go']['main']['App']['GetCitationCompletions'](arg1);
+}
+
+export function GetDocumentOutline(arg1, arg2) {
+  return window['go']['main']['App']['GetDocumentOutline'](arg1, arg2);
+}
+
+export function GetEffectiveSettings(arg1, arg2) {
+  return window['go']['main']['App']['GetEffectiveSettings'](arg1, arg2);
 }
 
 export function GetFileTree(arg1) {
@@ -18,30 +74,122 @@ export function GetFontSettings(arg1) {
   return window['go']['main']['App']['GetFontSettings'](arg1);
 }
 
+export function GetHistory(arg1) {
+  return window['go']['main']['App']['GetHistory'](arg1);
+}
+
 export function GetLastOpened() {
   return window['go']['main']['App']['GetLastOpened']();
 }
 
+export function GetMathMacros(arg1) {
+  return window['go']['main']['App']['GetMathMacros'](arg1);
+}
+
 export function GetNewDirectoryFileTree() {
   return window['go']['main']['App']['GetNewDirectoryFileTree']();
 }
 
+export function GetNoteBibliography(arg1, arg2) {
+  return window['go']['main']['App']['GetNoteBibliography'](arg1, arg2);
+}
+
+export function GetRecentProjects() {
+  return window['go']['main']['App']['GetRecentProjects']();
+}
+
+export function GetSettings(arg1) {
+  return window['go']['main']['App']['GetSettings'](arg1);
+}
+
+export function GetTheoremCatalog(arg1, arg2) {
+  return window['go']['main']['App']['GetTheoremCatalog'](arg1, arg2);
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ImportObsidianVault(arg1, arg2) {
+  return window['go']['main']['App']['ImportObsidianVault'](arg1, arg2);
+}
+
+export function ImportVault(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ImportVault'](arg1, arg2, arg3);
+}
+
+export function ListTags(arg1) {
+  return window['go']['main']['App']['ListTags'](arg1);
+}
+
+export function ListWorkspaces(arg1) {
+  return window['go']['main']['App']['ListWorkspaces'](arg1);
+}
+
 export function LoadSession(arg1) {
   return window['go']['main']['App']['LoadSession'](arg1);
 }
 
+export function LoadSessionState(arg1) {
+  return window['go']['main']['App']['LoadSessionState'](arg1);
+}
+
 export function LoadTheorems(arg1) {
   return window['go']['main']['App']['LoadTheorems'](arg1);
 }
 
+export function OpenRecentProject(arg1) {
+  return window['go']['main']['App']['OpenRecentProject'](arg1);
+}
+
+export function PatchDefaultSettings(arg1, arg2) {
+  return window['go']['main']['App']['PatchDefaultSettings'](arg1, arg2);
+}
+
+export function PatchSettings(arg1, arg2) {
+  return window['go']['main']['App']['PatchSettings'](arg1, arg2);
+}
+
+export function PinRecentProject(arg1, arg2) {
+  return window['go']['main']['App']['PinRecentProject'](arg1, arg2);
+}
+
+export function PreviewReplace(arg1, arg2) {
+  return window['go']['main']['App']['PreviewReplace'](arg1, arg2);
+}
+
+export function QuickOpen(arg1, arg2, arg3) {
+  return window['go']['main']['App']['QuickOpen'](arg1, arg2, arg3);
+}
+
 export function ReadFile(arg1) {
   return window['go']['main']['App']['ReadFile'](arg1);
 }
 
+export function RebuildIndex(arg1) {
+  return window['go']['main']['App']['RebuildIndex'](arg1);
+}
+
+export function RemoveRecentProject(arg1) {
+  return window['go']['main']['App']['RemoveRecentProject'](arg1);
+}
+
+export function RenameRecentProject(arg1, arg2) {
+  return window['go']['main']['App']['RenameRecentProject'](arg1, arg2);
+}
+
+export function RenameTag(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RenameTag'](arg1, arg2, arg3);
+}
+
+export function RenameWorkspace(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RenameWorkspace'](arg1, arg2, arg3);
+}
+
+export function ResolveLink(arg1, arg2) {
+  return window['go']['main']['App']['ResolveLink'](arg1, arg2);
+}
+
 export function SaveFontSettings(arg1, arg2) {
   return window['go']['main']['App']['SaveFontSettings'](arg1, arg2);
 }
@@ -50,10 +198,30 @@ export function SaveSession(arg1, arg2) {
   return window['go']['main']['App']['SaveSession'](arg1, arg2);
 }
 
+export function SaveSessionState(arg1, arg2) {
+  return window['go']['main']['App']['SaveSessionState'](arg1, arg2);
+}
+
+export function SaveWorkspaceAs(arg1, arg2) {
+  return window['go']['main']['App']['SaveWorkspaceAs'](arg1, arg2);
+}
+
 export function SetLastOpened(arg1) {
   return window['go']['main']['App']['SetLastOpened'](arg1);
 }
 
+export function SwitchWorkspace(arg1, arg2) {
+  return window['go']['main']['App']['SwitchWorkspace'](arg1, arg2);
+}
+
+export function UndoLastOperation(arg1) {
+  return window['go']['main']['App']['UndoLastOperation'](arg1);
+}
+
+export function WatchProjectConfig(arg1) {
+  return window['go']['main']['App']['WatchProjectConfig'](arg1);
+}
+
 export function WriteFile(arg1, arg2, arg3) {
   return window['go']['main']['App']['WriteFile'](arg1, arg2, arg3);
 }
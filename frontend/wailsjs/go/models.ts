@@ -1,4 +1,250 @@
 export namespace backend {
+  export class BibIssue {
+    path: string;
+    line: number;
+    key: string;
+    message: string;
+
+    static createFrom(source: any = {}) {
+      return new BibIssue(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.line = source['line'];
+      this.key = source['key'];
+      this.message = source['message'];
+    }
+  }
+  export class CatalogEntry {
+    number: number;
+    name: string;
+    path: string;
+    line: number;
+    link: string;
+    tags: string[];
+    conditions: string;
+    statement: string;
+
+    static createFrom(source: any = {}) {
+      return new CatalogEntry(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.number = source['number'];
+      this.name = source['name'];
+      this.path = source['path'];
+      this.line = source['line'];
+      this.link = source['link'];
+      this.tags = source['tags'];
+      this.conditions = source['conditions'];
+      this.statement = source['statement'];
+    }
+  }
+  export class CatalogGroup {
+    name: string;
+    entries: CatalogEntry[];
+
+    static createFrom(source: any = {}) {
+      return new CatalogGroup(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.name = source['name'];
+      this.entries = this.convertValues(source['entries'], CatalogEntry);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class CatalogOptions {
+    group_by: string;
+    format: string;
+    output_path: string;
+
+    static createFrom(source: any = {}) {
+      return new CatalogOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.group_by = source['group_by'];
+      this.format = source['format'];
+      this.output_path = source['output_path'];
+    }
+  }
+  export class Citation {
+    key: string;
+    locator: string;
+    line: number;
+
+    static createFrom(source: any = {}) {
+      return new Citation(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.key = source['key'];
+      this.locator = source['locator'];
+      this.line = source['line'];
+    }
+  }
+  export class CitationCompletion {
+    key: string;
+    type: string;
+    label: string;
+
+    static createFrom(source: any = {}) {
+      return new CitationCompletion(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.key = source['key'];
+      this.type = source['type'];
+      this.label = source['label'];
+    }
+  }
+  export class CursorPosition {
+    line: number;
+    column: number;
+
+    static createFrom(source: any = {}) {
+      return new CursorPosition(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.line = source['line'];
+      this.column = source['column'];
+    }
+  }
+  export class EPUBExportOptions {
+    paths: string[];
+    output_path: string;
+    title: string;
+    author: string;
+    language: string;
+
+    static createFrom(source: any = {}) {
+      return new EPUBExportOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.paths = source['paths'];
+      this.output_path = source['output_path'];
+      this.title = source['title'];
+      this.author = source['author'];
+      this.language = source['language'];
+    }
+  }
+  export class EditorSettings {
+    theme: string;
+    tab_size: number;
+    line_wrapping: boolean;
+    line_numbers: boolean;
+    autosave_interval: number;
+    default_view_mode: string;
+    spellcheck_language: string;
+
+    static createFrom(source: any = {}) {
+      return new EditorSettings(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.theme = source['theme'];
+      this.tab_size = source['tab_size'];
+      this.line_wrapping = source['line_wrapping'];
+      this.line_numbers = source['line_numbers'];
+      this.autosave_interval = source['autosave_interval'];
+      this.default_view_mode = source['default_view_mode'];
+      this.spellcheck_language = source['spellcheck_language'];
+    }
+  }
+  export class EffectiveSettings {
+    config: ProjectConfig;
+    sources: Record<string, string>;
+    warnings: string[];
+
+    static createFrom(source: any = {}) {
+      return new EffectiveSettings(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.config = this.convertValues(source['config'], ProjectConfig);
+      this.sources = source['sources'];
+      this.warnings = source['warnings'];
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class ExportResult {
+    files: string[];
+    warnings: string[];
+
+    static createFrom(source: any = {}) {
+      return new ExportResult(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.files = source['files'];
+      this.warnings = source['warnings'];
+    }
+  }
+  export class FileChange {
+    path: string;
+    before: string;
+    after: string;
+
+    static createFrom(source: any = {}) {
+      return new FileChange(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.before = source['before'];
+      this.after = source['after'];
+    }
+  }
   export class FileItem {
     Name: string;
     Path: string;
@@ -35,6 +281,20 @@ export namespace backend {
       return a;
     }
   }
+  export class FlashcardOptions {
+    format: string;
+    output_path: string;
+
+    static createFrom(source: any = {}) {
+      return new FlashcardOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.format = source['format'];
+      this.output_path = source['output_path'];
+    }
+  }
   export class FontSettings {
     editor_font_family: string;
     editor_font_size: number;
@@ -53,4 +313,782 @@ export namespace backend {
       this.preview_font_size = source['preview_font_size'];
     }
   }
+  export class FormattedReference {
+    number: number;
+    key: string;
+    text: string;
+
+    static createFrom(source: any = {}) {
+      return new FormattedReference(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.number = source['number'];
+      this.key = source['key'];
+      this.text = source['text'];
+    }
+  }
+  export class ImportConflict {
+    kind: string;
+    path: string;
+    theorem: string;
+    existing_path: string;
+
+    static createFrom(source: any = {}) {
+      return new ImportConflict(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.kind = source['kind'];
+      this.path = source['path'];
+      this.theorem = source['theorem'];
+      this.existing_path = source['existing_path'];
+    }
+  }
+  export class ImportOptions {
+    overwrite: boolean;
+
+    static createFrom(source: any = {}) {
+      return new ImportOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.overwrite = source['overwrite'];
+    }
+  }
+  export class ImportResult {
+    files: string[];
+    unchanged: string[];
+    conflicts: ImportConflict[];
+
+    static createFrom(source: any = {}) {
+      return new ImportResult(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.files = source['files'];
+      this.unchanged = source['unchanged'];
+      this.conflicts = this.convertValues(source['conflicts'], ImportConflict);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class LaTeXExportOptions {
+    paths: string[];
+    output_dir: string;
+
+    static createFrom(source: any = {}) {
+      return new LaTeXExportOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.paths = source['paths'];
+      this.output_dir = source['output_dir'];
+    }
+  }
+  export class MacroIssue {
+    source: string;
+    line: number;
+    message: string;
+
+    static createFrom(source: any = {}) {
+      return new MacroIssue(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.source = source['source'];
+      this.line = source['line'];
+      this.message = source['message'];
+    }
+  }
+  export class MathMacro {
+    name: string;
+    body: string;
+    args: number;
+    source: string;
+    line: number;
+
+    static createFrom(source: any = {}) {
+      return new MathMacro(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.name = source['name'];
+      this.body = source['body'];
+      this.args = source['args'];
+      this.source = source['source'];
+      this.line = source['line'];
+    }
+  }
+  export class MathMacros {
+    macros: MathMacro[];
+    issues: MacroIssue[];
+
+    static createFrom(source: any = {}) {
+      return new MathMacros(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.macros = this.convertValues(source['macros'], MathMacro);
+      this.issues = this.convertValues(source['issues'], MacroIssue);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class NoteBibliography {
+    references: FormattedReference[];
+    unknown: Citation[];
+
+    static createFrom(source: any = {}) {
+      return new NoteBibliography(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.references = this.convertValues(source['references'], FormattedReference);
+      this.unknown = this.convertValues(source['unknown'], Citation);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class NoteFile {
+    content: string;
+    metadata: NoteMetadata;
+    metadata_error: string;
+
+    static createFrom(source: any = {}) {
+      return new NoteFile(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.content = source['content'];
+      this.metadata = this.convertValues(source['metadata'], NoteMetadata);
+      this.metadata_error = source['metadata_error'];
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class NoteMetadata {
+    title: string;
+    aliases: string[];
+    authors: string[];
+    status: string;
+    tags: string[];
+
+    static createFrom(source: any = {}) {
+      return new NoteMetadata(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.title = source['title'];
+      this.aliases = source['aliases'];
+      this.authors = source['authors'];
+      this.status = source['status'];
+      this.tags = source['tags'];
+    }
+  }
+  export class ObsidianImportIssue {
+    path: string;
+    line: number;
+    message: string;
+
+    static createFrom(source: any = {}) {
+      return new ObsidianImportIssue(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.line = source['line'];
+      this.message = source['message'];
+    }
+  }
+  export class ObsidianImportReport {
+    notes: string[];
+    attachments: string[];
+    issues: ObsidianImportIssue[];
+
+    static createFrom(source: any = {}) {
+      return new ObsidianImportReport(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.notes = source['notes'];
+      this.attachments = source['attachments'];
+      this.issues = this.convertValues(source['issues'], ObsidianImportIssue);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class Operation {
+    id: string;
+    description: string;
+    timestamp: any;
+    changes: FileChange[];
+
+    static createFrom(source: any = {}) {
+      return new Operation(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.id = source['id'];
+      this.description = source['description'];
+      this.timestamp = this.convertValues(source['timestamp'], null);
+      this.changes = this.convertValues(source['changes'], FileChange);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class OutlineItem {
+    kind: string;
+    title: string;
+    level: number;
+    slug: string;
+    start_line: number;
+    end_line: number;
+    children: OutlineItem[];
+
+    static createFrom(source: any = {}) {
+      return new OutlineItem(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.kind = source['kind'];
+      this.title = source['title'];
+      this.level = source['level'];
+      this.slug = source['slug'];
+      this.start_line = source['start_line'];
+      this.end_line = source['end_line'];
+      this.children = this.convertValues(source['children'], OutlineItem);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class ProjectConfig {
+    font_settings: FontSettings;
+    editor_settings: EditorSettings;
+    theorem_settings: TheoremSettings;
+
+    static createFrom(source: any = {}) {
+      return new ProjectConfig(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.font_settings = this.convertValues(source['font_settings'], FontSettings);
+      this.editor_settings = this.convertValues(source['editor_settings'], EditorSettings);
+      this.theorem_settings = this.convertValues(source['theorem_settings'], TheoremSettings);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class QuickOpenItem {
+    kind: string;
+    label: string;
+    detail: string;
+    path: string;
+    line: number;
+    score: number;
+    positions: number[];
+
+    static createFrom(source: any = {}) {
+      return new QuickOpenItem(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.kind = source['kind'];
+      this.label = source['label'];
+      this.detail = source['detail'];
+      this.path = source['path'];
+      this.line = source['line'];
+      this.score = source['score'];
+      this.positions = source['positions'];
+    }
+  }
+  export class RecentProject {
+    path: string;
+    name: string;
+    last_opened: any;
+    pinned: boolean;
+
+    static createFrom(source: any = {}) {
+      return new RecentProject(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.name = source['name'];
+      this.last_opened = this.convertValues(source['last_opened'], null);
+      this.pinned = source['pinned'];
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class ReplaceMatch {
+    line: number;
+    column: number;
+    line_text: string;
+    match: string;
+    replacement: string;
+
+    static createFrom(source: any = {}) {
+      return new ReplaceMatch(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.line = source['line'];
+      this.column = source['column'];
+      this.line_text = source['line_text'];
+      this.match = source['match'];
+      this.replacement = source['replacement'];
+    }
+  }
+  export class ReplaceOptions {
+    query: string;
+    replacement: string;
+    use_regex: boolean;
+    case_sensitive: boolean;
+
+    static createFrom(source: any = {}) {
+      return new ReplaceOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.query = source['query'];
+      this.replacement = source['replacement'];
+      this.use_regex = source['use_regex'];
+      this.case_sensitive = source['case_sensitive'];
+    }
+  }
+  export class ReplacePreview {
+    path: string;
+    matches: ReplaceMatch[];
+
+    static createFrom(source: any = {}) {
+      return new ReplacePreview(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.matches = this.convertValues(source['matches'], ReplaceMatch);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class ResolvedLink {
+    path: string;
+    heading: string;
+    slug: string;
+    line: number;
+
+    static createFrom(source: any = {}) {
+      return new ResolvedLink(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.heading = source['heading'];
+      this.slug = source['slug'];
+      this.line = source['line'];
+    }
+  }
+  export class SessionState {
+    version: number;
+    active_file: string;
+    view_mode: string;
+    editor_width: number;
+    tabs: SessionTab[];
+
+    static createFrom(source: any = {}) {
+      return new SessionState(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.version = source['version'];
+      this.active_file = source['active_file'];
+      this.view_mode = source['view_mode'];
+      this.editor_width = source['editor_width'];
+      this.tabs = this.convertValues(source['tabs'], SessionTab);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class SessionTab {
+    path: string;
+    cursor: CursorPosition;
+    editor_scroll_top: number;
+    preview_scroll_top: number;
+
+    static createFrom(source: any = {}) {
+      return new SessionTab(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.path = source['path'];
+      this.cursor = this.convertValues(source['cursor'], CursorPosition);
+      this.editor_scroll_top = source['editor_scroll_top'];
+      this.preview_scroll_top = source['preview_scroll_top'];
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class SiteExportOptions {
+    paths: string[];
+    output_dir: string;
+    title: string;
+
+    static createFrom(source: any = {}) {
+      return new SiteExportOptions(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.paths = source['paths'];
+      this.output_dir = source['output_dir'];
+      this.title = source['title'];
+    }
+  }
+  export class TagCount {
+    tag: string;
+    count: number;
+
+    static createFrom(source: any = {}) {
+      return new TagCount(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.tag = source['tag'];
+      this.count = source['count'];
+    }
+  }
+  export class TagSearchResult {
+    files: string[];
+    theorems: TaggedTheorem[];
+
+    static createFrom(source: any = {}) {
+      return new TagSearchResult(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.files = source['files'];
+      this.theorems = this.convertValues(source['theorems'], TaggedTheorem);
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class TaggedTheorem {
+    name: string;
+    path: string;
+
+    static createFrom(source: any = {}) {
+      return new TaggedTheorem(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.name = source['name'];
+      this.path = source['path'];
+    }
+  }
+  export class TheoremEnvironment {
+    tag: string;
+    label: string;
+    counter: string;
+    template: string;
+
+    static createFrom(source: any = {}) {
+      return new TheoremEnvironment(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.tag = source['tag'];
+      this.label = source['label'];
+      this.counter = source['counter'];
+      this.template = source['template'];
+    }
+  }
+  export class TheoremSettings {
+    environments: TheoremEnvironment[];
+    proof_label: string;
+    catalog_title: string;
+    untagged_label: string;
+
+    static createFrom(source: any = {}) {
+      return new TheoremSettings(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.environments = this.convertValues(source['environments'], TheoremEnvironment);
+      this.proof_label = source['proof_label'];
+      this.catalog_title = source['catalog_title'];
+      this.untagged_label = source['untagged_label'];
+    }
+
+    convertValues(a: any, classs: any, asMap: boolean = false): any {
+      if (!a) {
+        return a;
+      }
+      if (a.slice && a.map) {
+        return (a as any[]).map((elem) => this.convertValues(elem, classs));
+      } else if ('object' === typeof a) {
+        if (asMap) {
+          for (const key of Object.keys(a)) {
+            a[key] = new classs(a[key]);
+          }
+          return a;
+        }
+        return new classs(a);
+      }
+      return a;
+    }
+  }
+  export class WorkspaceInfo {
+    name: string;
+    tabs: number;
+    active: boolean;
+
+    static createFrom(source: any = {}) {
+      return new WorkspaceInfo(source);
+    }
+
+    constructor(source: any = {}) {
+      if ('string' === typeof source) source = JSON.parse(source);
+      this.name = source['name'];
+      this.tabs = source['tabs'];
+      this.active = source['active'];
+    }
+  }
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"embed"
+	"io/fs"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -11,12 +13,26 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
+//go:embed frontend/src/assets/styles/katex.css frontend/src/assets/styles/highlight.css
+var previewStyles embed.FS
+
 func main() {
+	styles, err := fs.Sub(previewStyles, "frontend/src/assets/styles")
+	if err != nil {
+		println("Error:", err.Error())
+		return
+	}
+
+	// サブコマンドが指定された場合は、ウィンドウを開かずに実行して終了する
+	if code, ok := runCLI(os.Args[1:], os.Stdout, os.Stderr, styles); ok {
+		os.Exit(code)
+	}
+
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(styles)
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "theorem-note-wails",
 		Width:  1920,
 		Height: 1080,
@@ -25,6 +41,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},